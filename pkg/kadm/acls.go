@@ -0,0 +1,681 @@
+package kadm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// clusterName is the only valid resource name for cluster ACLs.
+const clusterName = "kafka-cluster"
+
+// ACL is a single ACL binding: a principal from a host is allowed or denied an
+// operation on a resource.
+//
+// ACL is comparable and can be used as a map key. ACLs created by ACLBuilder
+// or returned from Kafka are always complete; when building an ACL by hand,
+// an empty Host is "*" (any host), an unknown Pattern is literal, and an empty
+// name for a cluster resource is "kafka-cluster".
+type ACL struct {
+	Type       kmsg.ACLResourceType
+	Name       string
+	Pattern    kmsg.ACLResourcePatternType // literal or prefixed
+	Principal  string                      // such as "User:alice"
+	Host       string                      // "*" for any host
+	Operation  kmsg.ACLOperation
+	Permission kmsg.ACLPermissionType // allow or deny
+}
+
+func (a ACL) normalize() ACL {
+	if a.Host == "" {
+		a.Host = "*"
+	}
+	if a.Pattern == kmsg.ACLResourcePatternTypeUnknown {
+		a.Pattern = kmsg.ACLResourcePatternTypeLiteral
+	}
+	if a.Type == kmsg.ACLResourceTypeCluster && a.Name == "" {
+		a.Name = clusterName
+	}
+	return a
+}
+
+// String returns the ACL in a form similar to Kafka's command line tools.
+func (a ACL) String() string {
+	return fmt.Sprintf("%s %s from host %s %s on %s:%s:%s",
+		a.Principal, a.Permission, a.Host, a.Operation, a.Type, a.Pattern, a.Name)
+}
+
+// exactFilter returns a filter that matches only this ACL.
+func (a ACL) exactFilter() ACLFilter {
+	name, principal, host := a.Name, a.Principal, a.Host
+	return ACLFilter{
+		Type:       a.Type,
+		Name:       &name,
+		Pattern:    a.Pattern,
+		Principal:  &principal,
+		Host:       &host,
+		Operation:  a.Operation,
+		Permission: a.Permission,
+	}
+}
+
+// ACLFilter matches ACLs when describing or deleting. Nil strings match any
+// value, and the Any enum values (such as kmsg.ACLResourceTypeAny) match any
+// type. A pattern of kmsg.ACLResourcePatternTypeMatch matches literal names
+// equal to Name, wildcard literal names, and prefixed names that prefix Name.
+//
+// The zero ACLFilter is invalid; see AnyACL for a filter that matches every
+// ACL.
+type ACLFilter struct {
+	Type       kmsg.ACLResourceType
+	Name       *string
+	Pattern    kmsg.ACLResourcePatternType
+	Principal  *string
+	Host       *string
+	Operation  kmsg.ACLOperation
+	Permission kmsg.ACLPermissionType
+}
+
+// AnyACL returns a filter that matches every ACL.
+func AnyACL() ACLFilter {
+	return ACLFilter{
+		Type:       kmsg.ACLResourceTypeAny,
+		Pattern:    kmsg.ACLResourcePatternTypeAny,
+		Operation:  kmsg.ACLOperationAny,
+		Permission: kmsg.ACLPermissionTypeAny,
+	}
+}
+
+// ACLBuilder builds ACLs to create, or filters to describe or delete ACLs,
+// from the cross product of resources, principals, hosts, and operations.
+//
+// For example, to allow two users to read and describe every topic prefixed
+// with "logs-":
+//
+//	acls, err := kadm.NewACLs().
+//	        Topics("logs-").
+//	        Pattern(kmsg.ACLResourcePatternTypePrefixed).
+//	        Allow("User:alice", "User:bob").
+//	        Operations(kmsg.ACLOperationRead, kmsg.ACLOperationDescribe).
+//	        ACLs()
+type ACLBuilder struct {
+	resources map[kmsg.ACLResourceType][]string // a nil slice is any name, for filters
+
+	pattern    kmsg.ACLResourcePatternType
+	allow      []string
+	allowHosts []string
+	deny       []string
+	denyHosts  []string
+	ops        []kmsg.ACLOperation
+}
+
+// NewACLs returns a new ACL builder.
+func NewACLs() *ACLBuilder {
+	return &ACLBuilder{resources: make(map[kmsg.ACLResourceType][]string)}
+}
+
+func (b *ACLBuilder) addResources(typ kmsg.ACLResourceType, names []string) *ACLBuilder {
+	existing, exists := b.resources[typ]
+	if exists && existing == nil || names == nil && len(existing) > 0 {
+		b.resources[typ] = nil // any name wins when filtering
+		return b
+	}
+	b.resources[typ] = append(existing, names...)
+	return b
+}
+
+// Topics adds topic resources. When building filters, calling Topics with no
+// topics matches ACLs for any topic.
+func (b *ACLBuilder) Topics(topics ...string) *ACLBuilder {
+	return b.addResources(kmsg.ACLResourceTypeTopic, topics)
+}
+
+// Groups adds group resources. When building filters, calling Groups with no
+// groups matches ACLs for any group.
+func (b *ACLBuilder) Groups(groups ...string) *ACLBuilder {
+	return b.addResources(kmsg.ACLResourceTypeGroup, groups)
+}
+
+// TransactionalIDs adds transactional ID resources. When building filters,
+// calling TransactionalIDs with no IDs matches ACLs for any transactional ID.
+func (b *ACLBuilder) TransactionalIDs(txnIDs ...string) *ACLBuilder {
+	return b.addResources(kmsg.ACLResourceTypeTransactionalId, txnIDs)
+}
+
+// Cluster adds the cluster resource.
+func (b *ACLBuilder) Cluster() *ACLBuilder {
+	b.resources[kmsg.ACLResourceTypeCluster] = []string{clusterName}
+	return b
+}
+
+// Pattern sets the resource pattern type. ACLs default to literal and can
+// only be literal or prefixed; filters default to any.
+func (b *ACLBuilder) Pattern(pattern kmsg.ACLResourcePatternType) *ACLBuilder {
+	b.pattern = pattern
+	return b
+}
+
+// Allow adds principals, such as "User:alice", that are allowed the
+// operations.
+func (b *ACLBuilder) Allow(principals ...string) *ACLBuilder {
+	b.allow = append(b.allow, principals...)
+	return b
+}
+
+// AllowHosts sets the hosts that allowed principals are allowed from. ACLs
+// default to "*", any host; filters default to matching any host.
+func (b *ACLBuilder) AllowHosts(hosts ...string) *ACLBuilder {
+	b.allowHosts = append(b.allowHosts, hosts...)
+	return b
+}
+
+// Deny adds principals, such as "User:mallory", that are denied the
+// operations.
+func (b *ACLBuilder) Deny(principals ...string) *ACLBuilder {
+	b.deny = append(b.deny, principals...)
+	return b
+}
+
+// DenyHosts sets the hosts that denied principals are denied from. ACLs
+// default to "*", any host; filters default to matching any host.
+func (b *ACLBuilder) DenyHosts(hosts ...string) *ACLBuilder {
+	b.denyHosts = append(b.denyHosts, hosts...)
+	return b
+}
+
+// Operations adds the operations to allow or deny. Filters with no operations
+// match any operation.
+func (b *ACLBuilder) Operations(ops ...kmsg.ACLOperation) *ACLBuilder {
+	b.ops = append(b.ops, ops...)
+	return b
+}
+
+func (b *ACLBuilder) sortedTypes() []kmsg.ACLResourceType {
+	types := make([]kmsg.ACLResourceType, 0, len(b.resources))
+	for typ := range b.resources {
+		types = append(types, typ)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// ACLs returns the ACLs to create, or an error if the builder is missing
+// resources, principals, or operations, or has an invalid pattern.
+func (b *ACLBuilder) ACLs() ([]ACL, error) {
+	pattern := b.pattern
+	switch pattern {
+	case kmsg.ACLResourcePatternTypeUnknown:
+		pattern = kmsg.ACLResourcePatternTypeLiteral
+	case kmsg.ACLResourcePatternTypeLiteral, kmsg.ACLResourcePatternTypePrefixed:
+	default:
+		return nil, fmt.Errorf("invalid ACL pattern type %s, only LITERAL and PREFIXED can be created", pattern)
+	}
+	if len(b.resources) == 0 {
+		return nil, errors.New("invalid ACLs: no resources")
+	}
+	if len(b.allow)+len(b.deny) == 0 {
+		return nil, errors.New("invalid ACLs: no allowed or denied principals")
+	}
+	if len(b.ops) == 0 {
+		return nil, errors.New("invalid ACLs: no operations")
+	}
+	for _, op := range b.ops {
+		if op <= kmsg.ACLOperationAny {
+			return nil, fmt.Errorf("invalid ACL operation %s", op)
+		}
+	}
+
+	var acls []ACL
+	for _, typ := range b.sortedTypes() {
+		names := b.resources[typ]
+		if len(names) == 0 {
+			return nil, fmt.Errorf("invalid ACLs: no %s names", typ)
+		}
+		for _, perm := range []struct {
+			typ        kmsg.ACLPermissionType
+			principals []string
+			hosts      []string
+		}{
+			{kmsg.ACLPermissionTypeAllow, b.allow, b.allowHosts},
+			{kmsg.ACLPermissionTypeDeny, b.deny, b.denyHosts},
+		} {
+			hosts := perm.hosts
+			if len(hosts) == 0 {
+				hosts = []string{"*"}
+			}
+			for _, name := range names {
+				for _, principal := range perm.principals {
+					for _, host := range hosts {
+						for _, op := range b.ops {
+							acls = append(acls, ACL{
+								Type:       typ,
+								Name:       name,
+								Pattern:    pattern,
+								Principal:  principal,
+								Host:       host,
+								Operation:  op,
+								Permission: perm.typ,
+							})
+						}
+					}
+				}
+			}
+		}
+	}
+	return acls, nil
+}
+
+// Filters returns filters matching the builder's ACLs, for describing or
+// deleting. Anything that was not set in the builder matches any value: no
+// resources match any resource, no principals match any principal with any
+// permission, and so on.
+func (b *ACLBuilder) Filters() []ACLFilter {
+	pattern := b.pattern
+	if pattern == kmsg.ACLResourcePatternTypeUnknown {
+		pattern = kmsg.ACLResourcePatternTypeAny
+	}
+
+	type resource struct {
+		typ  kmsg.ACLResourceType
+		name *string
+	}
+	var resources []resource
+	for _, typ := range b.sortedTypes() {
+		names := b.resources[typ]
+		if names == nil {
+			resources = append(resources, resource{typ, nil})
+		}
+		for i := range names {
+			resources = append(resources, resource{typ, &names[i]})
+		}
+	}
+	if len(resources) == 0 {
+		resources = []resource{{kmsg.ACLResourceTypeAny, nil}}
+	}
+
+	type principal struct {
+		perm      kmsg.ACLPermissionType
+		principal *string
+		hosts     []string
+	}
+	var principals []principal
+	for _, perm := range []struct {
+		typ        kmsg.ACLPermissionType
+		principals []string
+		hosts      []string
+	}{
+		{kmsg.ACLPermissionTypeAllow, b.allow, b.allowHosts},
+		{kmsg.ACLPermissionTypeDeny, b.deny, b.denyHosts},
+	} {
+		for i := range perm.principals {
+			principals = append(principals, principal{perm.typ, &perm.principals[i], perm.hosts})
+		}
+	}
+	if len(principals) == 0 {
+		principals = []principal{{kmsg.ACLPermissionTypeAny, nil, nil}}
+	}
+
+	ops := b.ops
+	if len(ops) == 0 {
+		ops = []kmsg.ACLOperation{kmsg.ACLOperationAny}
+	}
+
+	var filters []ACLFilter
+	for _, r := range resources {
+		for _, p := range principals {
+			hosts := []*string{nil}
+			if len(p.hosts) > 0 {
+				hosts = hosts[:0]
+				for i := range p.hosts {
+					hosts = append(hosts, &p.hosts[i])
+				}
+			}
+			for _, host := range hosts {
+				for _, op := range ops {
+					filters = append(filters, ACLFilter{
+						Type:       r.typ,
+						Name:       r.name,
+						Pattern:    pattern,
+						Principal:  p.principal,
+						Host:       host,
+						Operation:  op,
+						Permission: p.perm,
+					})
+				}
+			}
+		}
+	}
+	return filters
+}
+
+// DescribeACLsResult is the result of describing ACLs for a filter.
+type DescribeACLsResult struct {
+	Filter    ACLFilter
+	Described []ACL // the ACLs matching the filter
+
+	Err        error  // the error describing ACLs, if any
+	ErrMessage string // a message describing the error, if Kafka returned one
+}
+
+// DescribeACLsResults are the results of describing ACLs, in the order the
+// filters were given.
+type DescribeACLsResults []DescribeACLsResult
+
+// ACLs returns every described ACL across all results, deduplicated and
+// sorted.
+func (rs DescribeACLsResults) ACLs() []ACL {
+	seen := make(map[ACL]bool)
+	var acls []ACL
+	for _, r := range rs {
+		for _, a := range r.Described {
+			if !seen[a] {
+				seen[a] = true
+				acls = append(acls, a)
+			}
+		}
+	}
+	sortACLs(acls)
+	return acls
+}
+
+// Error returns the first filter error, if any.
+func (rs DescribeACLsResults) Error() error {
+	for _, r := range rs {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+// DescribeACLs describes the ACLs matching each filter. Kafka can only
+// describe one filter per request, so this issues one request per filter.
+func (cl *Client) DescribeACLs(ctx context.Context, filters ...ACLFilter) (DescribeACLsResults, error) {
+	rs := make(DescribeACLsResults, 0, len(filters))
+	for _, f := range filters {
+		req := kmsg.NewPtrDescribeACLsRequest()
+		req.ResourceType = f.Type
+		req.ResourceName = f.Name
+		req.ResourcePatternType = f.Pattern
+		req.Principal = f.Principal
+		req.Host = f.Host
+		req.Operation = f.Operation
+		req.PermissionType = f.Permission
+
+		resp, err := req.RequestWith(ctx, cl.cl)
+		if err != nil {
+			return nil, err
+		}
+		r := DescribeACLsResult{
+			Filter:     f,
+			Err:        kerr.ErrorForCode(resp.ErrorCode),
+			ErrMessage: errMessage(resp.ErrorCode, resp.ErrorMessage),
+		}
+		for _, res := range resp.Resources {
+			for _, a := range res.ACLs {
+				r.Described = append(r.Described, ACL{
+					Type:       res.ResourceType,
+					Name:       res.ResourceName,
+					Pattern:    res.ResourcePatternType,
+					Principal:  a.Principal,
+					Host:       a.Host,
+					Operation:  a.Operation,
+					Permission: a.PermissionType,
+				}.normalize())
+			}
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+// CreateACLsResult is the result of creating an ACL.
+type CreateACLsResult struct {
+	ACL
+
+	Err        error  // the error creating the ACL, if any
+	ErrMessage string // a message describing the error, if Kafka returned one
+}
+
+// CreateACLsResults are the results of creating ACLs, in the order the ACLs
+// were given.
+type CreateACLsResults []CreateACLsResult
+
+// Error returns the first ACL error, if any.
+func (rs CreateACLsResults) Error() error {
+	for _, r := range rs {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+// CreateACLs creates the given ACLs. Creating an ACL that already exists
+// succeeds.
+func (cl *Client) CreateACLs(ctx context.Context, acls ...ACL) (CreateACLsResults, error) {
+	if len(acls) == 0 {
+		return nil, nil
+	}
+	req := kmsg.NewPtrCreateACLsRequest()
+	normalized := make([]ACL, 0, len(acls))
+	for _, a := range acls {
+		a = a.normalize()
+		normalized = append(normalized, a)
+		c := kmsg.NewCreateACLsRequestCreation()
+		c.ResourceType = a.Type
+		c.ResourceName = a.Name
+		c.ResourcePatternType = a.Pattern
+		c.Principal = a.Principal
+		c.Host = a.Host
+		c.Operation = a.Operation
+		c.PermissionType = a.Permission
+		req.Creations = append(req.Creations, c)
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	rs := make(CreateACLsResults, 0, len(normalized))
+	for i, a := range normalized {
+		r := CreateACLsResult{ACL: a, Err: errMissingResource}
+		if i < len(resp.Results) {
+			res := resp.Results[i]
+			r.Err = kerr.ErrorForCode(res.ErrorCode)
+			r.ErrMessage = errMessage(res.ErrorCode, res.ErrorMessage)
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+// DeletedACL is an ACL that matched a delete filter.
+type DeletedACL struct {
+	ACL
+
+	Err        error  // the error deleting the ACL, if any
+	ErrMessage string // a message describing the error, if Kafka returned one
+}
+
+// DeleteACLsResult is the result of deleting ACLs for a filter.
+type DeleteACLsResult struct {
+	Filter  ACLFilter
+	Deleted []DeletedACL // the ACLs matching the filter
+
+	Err        error  // the error deleting ACLs for the filter, if any
+	ErrMessage string // a message describing the error, if Kafka returned one
+}
+
+// DeleteACLsResults are the results of deleting ACLs, in the order the
+// filters were given.
+type DeleteACLsResults []DeleteACLsResult
+
+// Error returns the first filter or deleted ACL error, if any.
+func (rs DeleteACLsResults) Error() error {
+	for _, r := range rs {
+		if r.Err != nil {
+			return r.Err
+		}
+		for _, d := range r.Deleted {
+			if d.Err != nil {
+				return d.Err
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteACLs deletes every ACL matching each filter, returning the ACLs that
+// each filter matched.
+func (cl *Client) DeleteACLs(ctx context.Context, filters ...ACLFilter) (DeleteACLsResults, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	req := kmsg.NewPtrDeleteACLsRequest()
+	for _, f := range filters {
+		rf := kmsg.NewDeleteACLsRequestFilter()
+		rf.ResourceType = f.Type
+		rf.ResourceName = f.Name
+		rf.ResourcePatternType = f.Pattern
+		rf.Principal = f.Principal
+		rf.Host = f.Host
+		rf.Operation = f.Operation
+		rf.PermissionType = f.Permission
+		req.Filters = append(req.Filters, rf)
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	rs := make(DeleteACLsResults, 0, len(filters))
+	for i, f := range filters {
+		r := DeleteACLsResult{Filter: f, Err: errMissingResource}
+		if i < len(resp.Results) {
+			res := resp.Results[i]
+			r.Err = kerr.ErrorForCode(res.ErrorCode)
+			r.ErrMessage = errMessage(res.ErrorCode, res.ErrorMessage)
+			for _, m := range res.MatchingACLs {
+				r.Deleted = append(r.Deleted, DeletedACL{
+					ACL: ACL{
+						Type:       m.ResourceType,
+						Name:       m.ResourceName,
+						Pattern:    m.ResourcePatternType,
+						Principal:  m.Principal,
+						Host:       m.Host,
+						Operation:  m.Operation,
+						Permission: m.PermissionType,
+					}.normalize(),
+					Err:        kerr.ErrorForCode(m.ErrorCode),
+					ErrMessage: errMessage(m.ErrorCode, m.ErrorMessage),
+				})
+			}
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+func sortACLs(acls []ACL) {
+	sort.Slice(acls, func(i, j int) bool {
+		l, r := acls[i], acls[j]
+		switch {
+		case l.Type != r.Type:
+			return l.Type < r.Type
+		case l.Name != r.Name:
+			return l.Name < r.Name
+		case l.Pattern != r.Pattern:
+			return l.Pattern < r.Pattern
+		case l.Principal != r.Principal:
+			return l.Principal < r.Principal
+		case l.Host != r.Host:
+			return l.Host < r.Host
+		case l.Operation != r.Operation:
+			return l.Operation < r.Operation
+		default:
+			return l.Permission < r.Permission
+		}
+	})
+}
+
+// DiffACLs returns the ACLs that must be created and deleted for current to
+// become desired. Both returned slices are sorted.
+func DiffACLs(desired, current []ACL) (create, remove []ACL) {
+	want := make(map[ACL]bool, len(desired))
+	for _, a := range desired {
+		want[a.normalize()] = true
+	}
+	have := make(map[ACL]bool, len(current))
+	for _, a := range current {
+		have[a.normalize()] = true
+	}
+	for a := range want {
+		if !have[a] {
+			create = append(create, a)
+		}
+	}
+	for a := range have {
+		if !want[a] {
+			remove = append(remove, a)
+		}
+	}
+	sortACLs(create)
+	sortACLs(remove)
+	return create, remove
+}
+
+// ApplyACLsResult is the result of applying a desired set of ACLs.
+type ApplyACLsResult struct {
+	Created CreateACLsResults
+	Deleted DeleteACLsResults
+}
+
+// Error returns the first create or delete error, if any.
+func (r ApplyACLsResult) Error() error {
+	if err := r.Created.Error(); err != nil {
+		return err
+	}
+	return r.Deleted.Error()
+}
+
+// ApplyACLs makes the cluster's ACLs within scope match desired, creating
+// only ACLs that are missing and deleting only ACLs that are not desired.
+//
+// The scope filters select which existing ACLs are managed: ACLs matching any
+// scope filter that are not desired are deleted, and ACLs outside the scope
+// are left alone. If no scope is given, every ACL in the cluster is in scope.
+// Desired ACLs are created even if they are outside the scope.
+//
+// If describing the current ACLs fails, this returns the error and changes
+// nothing. Each extra ACL is deleted with a filter that matches only that
+// ACL, so ACLs created concurrently are never deleted by accident.
+func (cl *Client) ApplyACLs(ctx context.Context, desired []ACL, scope ...ACLFilter) (ApplyACLsResult, error) {
+	if len(scope) == 0 {
+		scope = []ACLFilter{AnyACL()}
+	}
+	described, err := cl.DescribeACLs(ctx, scope...)
+	if err != nil {
+		return ApplyACLsResult{}, err
+	}
+	if err := described.Error(); err != nil {
+		return ApplyACLsResult{}, err
+	}
+
+	create, remove := DiffACLs(desired, described.ACLs())
+
+	var r ApplyACLsResult
+	if r.Created, err = cl.CreateACLs(ctx, create...); err != nil {
+		return r, err
+	}
+	filters := make([]ACLFilter, 0, len(remove))
+	for _, a := range remove {
+		filters = append(filters, a.exactFilter())
+	}
+	r.Deleted, err = cl.DeleteACLs(ctx, filters...)
+	return r, err
+}
@@ -0,0 +1,66 @@
+package kadm
+
+import (
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestACLBuilderAndDiff(t *testing.T) {
+	desired, err := NewACLs().
+		Topics("foo", "bar").
+		Allow("User:alice").
+		Deny("User:mallory").
+		DenyHosts("10.0.0.1").
+		Operations(kmsg.ACLOperationRead).
+		ACLs()
+	if err != nil {
+		t.Fatalf("unexpected build err: %v", err)
+	}
+	if len(desired) != 4 {
+		t.Fatalf("got %d ACLs, expected 4: %v", len(desired), desired)
+	}
+	for _, a := range desired {
+		if a.Pattern != kmsg.ACLResourcePatternTypeLiteral {
+			t.Errorf("%v: got pattern %s, expected LITERAL", a, a.Pattern)
+		}
+		if a.Permission == kmsg.ACLPermissionTypeAllow && a.Host != "*" ||
+			a.Permission == kmsg.ACLPermissionTypeDeny && a.Host != "10.0.0.1" {
+			t.Errorf("%v: unexpected host", a)
+		}
+	}
+
+	if _, err := NewACLs().Topics("foo").Operations(kmsg.ACLOperationRead).ACLs(); err == nil {
+		t.Error("expected error building ACLs with no principals")
+	}
+	if _, err := NewACLs().Topics().Allow("User:a").Operations(kmsg.ACLOperationRead).ACLs(); err == nil {
+		t.Error("expected error building ACLs with no topic names")
+	}
+
+	stale := ACL{
+		Type:       kmsg.ACLResourceTypeTopic,
+		Name:       "baz",
+		Principal:  "User:alice",
+		Operation:  kmsg.ACLOperationWrite,
+		Permission: kmsg.ACLPermissionTypeAllow,
+	}
+	current := []ACL{desired[0], desired[1], stale}
+	create, remove := DiffACLs(desired, current)
+	if len(create) != 2 {
+		t.Errorf("got %d ACLs to create, expected 2: %v", len(create), create)
+	}
+	if len(remove) != 1 || remove[0] != stale.normalize() {
+		t.Errorf("got ACLs to delete %v, expected only %v", remove, stale)
+	}
+
+	filters := NewACLs().Topics().Allow("User:alice").Filters()
+	if len(filters) != 1 {
+		t.Fatalf("got %d filters, expected 1", len(filters))
+	}
+	f := filters[0]
+	if f.Type != kmsg.ACLResourceTypeTopic || f.Name != nil || f.Host != nil ||
+		f.Pattern != kmsg.ACLResourcePatternTypeAny || f.Operation != kmsg.ACLOperationAny ||
+		f.Permission != kmsg.ACLPermissionTypeAllow || *f.Principal != "User:alice" {
+		t.Errorf("got unexpected filter %+v", f)
+	}
+}
@@ -0,0 +1,283 @@
+package kadm
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// ConfigSynonym is a fallback value for a config, in order of precedence.
+type ConfigSynonym struct {
+	Key    string
+	Value  *string
+	Source kmsg.ConfigSource
+}
+
+// Config is a config key and its value.
+type Config struct {
+	Key       string
+	Value     *string // nil if the config is sensitive or has no value
+	Sensitive bool
+	ReadOnly  bool
+	Source    kmsg.ConfigSource
+	Synonyms  []ConfigSynonym
+}
+
+// MaybeValue returns the config's value, or the empty string if the value is
+// nil.
+func (c *Config) MaybeValue() string {
+	if c.Value == nil {
+		return ""
+	}
+	return *c.Value
+}
+
+// ResourceConfig is the configs of a topic or broker.
+type ResourceConfig struct {
+	Name    string // the topic name, or the broker node ID
+	Configs []Config
+
+	Err        error  // the error describing the resource, if any
+	ErrMessage string // a message describing the error, if Kafka returned one
+}
+
+// ResourceConfigs are the configs of topics or brokers, in the order they
+// were requested.
+type ResourceConfigs []ResourceConfig
+
+// On returns the configs for the given topic or broker, and whether it
+// exists.
+func (rs ResourceConfigs) On(name string) (ResourceConfig, bool) {
+	for _, r := range rs {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return ResourceConfig{}, false
+}
+
+// Error returns the first resource error, if any.
+func (rs ResourceConfigs) Error() error {
+	for _, r := range rs {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+// DescribeTopicConfigs returns the configs of the given topics, including
+// config synonyms. Topics that do not exist are returned with
+// kerr.UnknownTopicOrPartition.
+func (cl *Client) DescribeTopicConfigs(ctx context.Context, topics ...string) (ResourceConfigs, error) {
+	return cl.describeConfigs(ctx, kmsg.ConfigResourceTypeTopic, topics)
+}
+
+// DescribeBrokerConfigs returns the configs of the given brokers, including
+// config synonyms. If no brokers are given, this returns the cluster wide
+// dynamic broker defaults, which have an empty name.
+func (cl *Client) DescribeBrokerConfigs(ctx context.Context, brokers ...int32) (ResourceConfigs, error) {
+	return cl.describeConfigs(ctx, kmsg.ConfigResourceTypeBroker, brokerNames(brokers))
+}
+
+func brokerNames(brokers []int32) []string {
+	if len(brokers) == 0 {
+		return []string{""}
+	}
+	names := make([]string, 0, len(brokers))
+	for _, b := range brokers {
+		names = append(names, strconv.Itoa(int(b)))
+	}
+	return names
+}
+
+func (cl *Client) describeConfigs(ctx context.Context, typ kmsg.ConfigResourceType, names []string) (ResourceConfigs, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	req := kmsg.NewPtrDescribeConfigsRequest()
+	req.IncludeSynonyms = true
+	for _, name := range names {
+		r := kmsg.NewDescribeConfigsRequestResource()
+		r.ResourceType = typ
+		r.ResourceName = name
+		req.Resources = append(req.Resources, r)
+	}
+
+	got := make(map[string]ResourceConfig, len(names))
+	errs := cl.shardErrs(ctx, req, func(kresp kmsg.Response) {
+		resp := kresp.(*kmsg.DescribeConfigsResponse)
+		for _, r := range resp.Resources {
+			rc := ResourceConfig{
+				Name:       r.ResourceName,
+				Err:        kerr.ErrorForCode(r.ErrorCode),
+				ErrMessage: errMessage(r.ErrorCode, r.ErrorMessage),
+			}
+			for _, c := range r.Configs {
+				config := Config{
+					Key:       c.Name,
+					Value:     c.Value,
+					Sensitive: c.IsSensitive,
+					ReadOnly:  c.ReadOnly,
+					Source:    c.Source,
+				}
+				for _, syn := range c.ConfigSynonyms {
+					config.Synonyms = append(config.Synonyms, ConfigSynonym{
+						Key:    syn.Name,
+						Value:  syn.Value,
+						Source: syn.Source,
+					})
+				}
+				rc.Configs = append(rc.Configs, config)
+			}
+			got[r.ResourceName] = rc
+		}
+	})
+	for _, se := range errs {
+		for _, r := range se.req.(*kmsg.DescribeConfigsRequest).Resources {
+			got[r.ResourceName] = ResourceConfig{Name: r.ResourceName, Err: se.err}
+		}
+	}
+
+	rs := make(ResourceConfigs, 0, len(names))
+	for _, name := range names {
+		r, exists := got[name]
+		if !exists {
+			r = ResourceConfig{Name: name, Err: errMissingResource}
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+// IncrementalOp is an operation to use when incrementally altering configs.
+type IncrementalOp int8
+
+const (
+	// SetConfig sets a config key to a value.
+	SetConfig IncrementalOp = iota
+	// DeleteConfig deletes a config key, reverting it to its default.
+	DeleteConfig
+	// AppendConfig appends a value to a list config key.
+	AppendConfig
+	// SubtractConfig removes a value from a list config key.
+	SubtractConfig
+)
+
+// AlterConfig is a config key to alter, and how to alter it.
+type AlterConfig struct {
+	Op    IncrementalOp
+	Name  string
+	Value *string // unused for DeleteConfig
+}
+
+// AlterConfigsResponse is the result of altering the configs of a topic or
+// broker.
+type AlterConfigsResponse struct {
+	Name string // the topic name, or the broker node ID
+
+	Err        error  // the error altering configs, if any
+	ErrMessage string // a message describing the error, if Kafka returned one
+}
+
+// AlterConfigsResponses are the results of altering configs, in the order
+// they were requested.
+type AlterConfigsResponses []AlterConfigsResponse
+
+// On returns the response for the given topic or broker, and whether it
+// exists.
+func (rs AlterConfigsResponses) On(name string) (AlterConfigsResponse, bool) {
+	for _, r := range rs {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return AlterConfigsResponse{}, false
+}
+
+// Error returns the first resource error, if any.
+func (rs AlterConfigsResponses) Error() error {
+	for _, r := range rs {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+// AlterTopicConfigs incrementally alters the configs of the given topics.
+// Only the given config keys are changed. This requires Kafka 2.3+.
+func (cl *Client) AlterTopicConfigs(ctx context.Context, configs []AlterConfig, topics ...string) (AlterConfigsResponses, error) {
+	return cl.alterConfigs(ctx, false, configs, kmsg.ConfigResourceTypeTopic, topics)
+}
+
+// ValidateAlterTopicConfigs validates that topic configs can be altered as in
+// AlterTopicConfigs, without altering them.
+func (cl *Client) ValidateAlterTopicConfigs(ctx context.Context, configs []AlterConfig, topics ...string) (AlterConfigsResponses, error) {
+	return cl.alterConfigs(ctx, true, configs, kmsg.ConfigResourceTypeTopic, topics)
+}
+
+// AlterBrokerConfigs incrementally alters the configs of the given brokers,
+// or the cluster wide dynamic broker defaults if no brokers are given. Only
+// the given config keys are changed. This requires Kafka 2.3+.
+func (cl *Client) AlterBrokerConfigs(ctx context.Context, configs []AlterConfig, brokers ...int32) (AlterConfigsResponses, error) {
+	return cl.alterConfigs(ctx, false, configs, kmsg.ConfigResourceTypeBroker, brokerNames(brokers))
+}
+
+func (cl *Client) alterConfigs(ctx context.Context, validate bool, configs []AlterConfig, typ kmsg.ConfigResourceType, names []string) (AlterConfigsResponses, error) {
+	return cl.alterEachConfigs(ctx, validate, typ, names, func(string) []AlterConfig { return configs })
+}
+
+// alterEachConfigs is alterConfigs, but with configs specific to each
+// resource.
+func (cl *Client) alterEachConfigs(ctx context.Context, validate bool, typ kmsg.ConfigResourceType, names []string, configs func(name string) []AlterConfig) (AlterConfigsResponses, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	req := kmsg.NewPtrIncrementalAlterConfigsRequest()
+	req.ValidateOnly = validate
+	for _, name := range names {
+		r := kmsg.NewIncrementalAlterConfigsRequestResource()
+		r.ResourceType = typ
+		r.ResourceName = name
+		for _, c := range configs(name) {
+			rc := kmsg.NewIncrementalAlterConfigsRequestResourceConfig()
+			rc.Name = c.Name
+			rc.Op = int8(c.Op)
+			rc.Value = c.Value
+			r.Configs = append(r.Configs, rc)
+		}
+		req.Resources = append(req.Resources, r)
+	}
+
+	got := make(map[string]AlterConfigsResponse, len(names))
+	errs := cl.shardErrs(ctx, req, func(kresp kmsg.Response) {
+		resp := kresp.(*kmsg.IncrementalAlterConfigsResponse)
+		for _, r := range resp.Resources {
+			got[r.ResourceName] = AlterConfigsResponse{
+				Name:       r.ResourceName,
+				Err:        kerr.ErrorForCode(r.ErrorCode),
+				ErrMessage: errMessage(r.ErrorCode, r.ErrorMessage),
+			}
+		}
+	})
+	for _, se := range errs {
+		for _, r := range se.req.(*kmsg.IncrementalAlterConfigsRequest).Resources {
+			got[r.ResourceName] = AlterConfigsResponse{Name: r.ResourceName, Err: se.err}
+		}
+	}
+
+	rs := make(AlterConfigsResponses, 0, len(names))
+	for _, name := range names {
+		r, exists := got[name]
+		if !exists {
+			r = AlterConfigsResponse{Name: name, Err: errMissingResource}
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
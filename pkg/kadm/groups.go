@@ -0,0 +1,538 @@
+package kadm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// ListedGroup is a group from a list groups response.
+type ListedGroup struct {
+	Coordinator  int32 // the broker that replied with this group
+	Group        string
+	ProtocolType string // "consumer" for consumer groups
+	State        string // the group state, if the broker supports it (Kafka 2.6+)
+}
+
+// ListedGroups are listed groups, by group name.
+type ListedGroups map[string]ListedGroup
+
+// Sorted returns the groups sorted by name.
+func (ls ListedGroups) Sorted() []ListedGroup {
+	s := make([]ListedGroup, 0, len(ls))
+	for _, l := range ls {
+		s = append(s, l)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Group < s[j].Group })
+	return s
+}
+
+// Groups returns the sorted names of every group.
+func (ls ListedGroups) Groups() []string {
+	groups := make([]string, 0, len(ls))
+	for group := range ls {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// ListGroups lists every group in the cluster, optionally filtered to groups
+// in the given states (such as "Empty" or "Stable"), which requires Kafka
+// 2.6+.
+//
+// Groups are listed from every broker. If any broker cannot be listed, this
+// returns the groups that could be listed along with the first error.
+func (cl *Client) ListGroups(ctx context.Context, filterStates ...string) (ListedGroups, error) {
+	req := kmsg.NewPtrListGroupsRequest()
+	req.StatesFilter = filterStates
+
+	ls := make(ListedGroups)
+	var firstErr error
+	for _, shard := range cl.cl.RequestSharded(ctx, req) {
+		if shard.Err != nil {
+			if firstErr == nil {
+				firstErr = shard.Err
+			}
+			continue
+		}
+		resp := shard.Resp.(*kmsg.ListGroupsResponse)
+		if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("broker %d: %w", shard.Meta.NodeID, err)
+			}
+			continue
+		}
+		for _, g := range resp.Groups {
+			ls[g.Group] = ListedGroup{
+				Coordinator:  shard.Meta.NodeID,
+				Group:        g.Group,
+				ProtocolType: g.ProtocolType,
+				State:        g.GroupState,
+			}
+		}
+	}
+	return ls, firstErr
+}
+
+// DescribedGroupMember is a member of a described group.
+type DescribedGroupMember struct {
+	MemberID   string
+	InstanceID *string // the instance ID, if the member uses static membership
+	ClientID   string
+	ClientHost string
+
+	// Assigned is the member's assignment decoded with
+	// kgo.ParseConsumerSyncAssignment, if the group is a consumer group
+	// that is stable.
+	Assigned map[string][]int32
+
+	// Metadata and Assignment are the raw member metadata and assignment.
+	Metadata   []byte
+	Assignment []byte
+}
+
+// DescribedGroup is a group from a describe groups response.
+type DescribedGroup struct {
+	Coordinator  int32 // the group's coordinator broker
+	Group        string
+	State        string // Empty, PreparingRebalance, CompletingRebalance, Stable, or Dead
+	ProtocolType string // "consumer" for consumer groups
+	Protocol     string // the balancer used, such as "cooperative-sticky"
+	Members      []DescribedGroupMember
+
+	Err error // the error describing the group, if any
+}
+
+// AssignedPartitions returns the partitions assigned to any member, by topic.
+func (d *DescribedGroup) AssignedPartitions() map[string][]int32 {
+	assigned := make(map[string][]int32)
+	for _, m := range d.Members {
+		for topic, partitions := range m.Assigned {
+			assigned[topic] = append(assigned[topic], partitions...)
+		}
+	}
+	for _, partitions := range assigned {
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	}
+	return assigned
+}
+
+// DescribedGroups are described groups, by group name.
+type DescribedGroups map[string]DescribedGroup
+
+// Sorted returns the groups sorted by name.
+func (ds DescribedGroups) Sorted() []DescribedGroup {
+	s := make([]DescribedGroup, 0, len(ds))
+	for _, d := range ds {
+		s = append(s, d)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Group < s[j].Group })
+	return s
+}
+
+// Error returns the first group error, in sorted order, if any.
+func (ds DescribedGroups) Error() error {
+	for _, d := range ds.Sorted() {
+		if d.Err != nil {
+			return d.Err
+		}
+	}
+	return nil
+}
+
+// DescribeGroups describes the given groups, or every group in the cluster if
+// no groups are given. Groups that do not exist are described with the Dead
+// state and no error.
+func (cl *Client) DescribeGroups(ctx context.Context, groups ...string) (DescribedGroups, error) {
+	if len(groups) == 0 {
+		listed, err := cl.ListGroups(ctx)
+		if err != nil {
+			return nil, err
+		}
+		groups = listed.Groups()
+		if len(groups) == 0 {
+			return make(DescribedGroups), nil
+		}
+	}
+
+	req := kmsg.NewPtrDescribeGroupsRequest()
+	req.Groups = groups
+
+	ds := make(DescribedGroups, len(groups))
+	for _, shard := range cl.cl.RequestSharded(ctx, req) {
+		if shard.Err != nil {
+			for _, group := range shard.Req.(*kmsg.DescribeGroupsRequest).Groups {
+				ds[group] = DescribedGroup{Group: group, Coordinator: -1, Err: shard.Err}
+			}
+			continue
+		}
+		resp := shard.Resp.(*kmsg.DescribeGroupsResponse)
+		for _, g := range resp.Groups {
+			ds[g.Group] = newDescribedGroup(shard.Meta.NodeID, &g)
+		}
+	}
+	for _, group := range groups {
+		if _, exists := ds[group]; !exists {
+			ds[group] = DescribedGroup{Group: group, Coordinator: -1, Err: errMissingResource}
+		}
+	}
+	return ds, nil
+}
+
+func newDescribedGroup(coordinator int32, g *kmsg.DescribeGroupsResponseGroup) DescribedGroup {
+	d := DescribedGroup{
+		Coordinator:  coordinator,
+		Group:        g.Group,
+		State:        g.State,
+		ProtocolType: g.ProtocolType,
+		Protocol:     g.Protocol,
+		Err:          kerr.ErrorForCode(g.ErrorCode),
+	}
+	for _, m := range g.Members {
+		dm := DescribedGroupMember{
+			MemberID:   m.MemberID,
+			InstanceID: m.InstanceID,
+			ClientID:   m.ClientID,
+			ClientHost: m.ClientHost,
+			Metadata:   m.ProtocolMetadata,
+			Assignment: m.MemberAssignment,
+		}
+		if g.ProtocolType == "consumer" && len(m.MemberAssignment) > 0 {
+			// A member without a parseable assignment is still a
+			// member; we just cannot say what it is assigned.
+			dm.Assigned, _ = kgo.ParseConsumerSyncAssignment(m.MemberAssignment)
+		}
+		d.Members = append(d.Members, dm)
+	}
+	return d
+}
+
+// GroupMemberLag is the lag of a partition that a group has committed or is
+// assigned.
+type GroupMemberLag struct {
+	Topic     string
+	Partition int32
+
+	// Member is the member that is assigned this partition, if any.
+	Member *DescribedGroupMember
+
+	// Commit is the group's committed offset, which has an offset of -1
+	// if the group has not committed for the partition.
+	Commit OffsetResponse
+	// End is the partition's end offset.
+	End ListedOffset
+
+	// Lag is End minus the committed offset. If the group has not
+	// committed, the lag is the entire partition from its start offset,
+	// if known, or -1 otherwise.
+	Lag int64
+
+	// Err is the error fetching the commit or the end offset, if any. If
+	// this is non-nil, Lag is -1.
+	Err error
+}
+
+// GroupLag is the lag of every partition a group has committed or is
+// assigned, by topic and partition.
+type GroupLag map[string]map[int32]GroupMemberLag
+
+// Sorted returns the lag sorted by topic and partition.
+func (l GroupLag) Sorted() []GroupMemberLag {
+	var s []GroupMemberLag
+	for _, ps := range l {
+		for _, pl := range ps {
+			s = append(s, pl)
+		}
+	}
+	sort.Slice(s, func(i, j int) bool {
+		return s[i].Topic < s[j].Topic || s[i].Topic == s[j].Topic && s[i].Partition < s[j].Partition
+	})
+	return s
+}
+
+// Total returns the total lag across every partition, skipping partitions
+// with errors or unknown lag.
+func (l GroupLag) Total() int64 {
+	var total int64
+	for _, ps := range l {
+		for _, pl := range ps {
+			if pl.Lag > 0 {
+				total += pl.Lag
+			}
+		}
+	}
+	return total
+}
+
+// CalculateGroupLag returns the lag of every partition that a group has
+// committed or is assigned, given the described group, its committed
+// offsets, and the end offsets of its topics. Start offsets are optional and
+// are only used for the lag of partitions the group has not committed.
+func CalculateGroupLag(group DescribedGroup, commits OffsetResponses, starts, ends ListedOffsets) GroupLag {
+	l := make(GroupLag)
+	add := func(topic string, partition int32) *GroupMemberLag {
+		ps := l[topic]
+		if ps == nil {
+			ps = make(map[int32]GroupMemberLag)
+			l[topic] = ps
+		}
+		pl := ps[partition]
+		pl.Topic, pl.Partition = topic, partition
+		ps[partition] = pl
+		return &pl
+	}
+	set := func(pl *GroupMemberLag) { l[pl.Topic][pl.Partition] = *pl }
+
+	for _, ps := range commits {
+		for _, c := range ps {
+			pl := add(c.Topic, c.Partition)
+			pl.Commit = c
+			set(pl)
+		}
+	}
+	for i := range group.Members {
+		m := &group.Members[i]
+		for topic, partitions := range m.Assigned {
+			for _, partition := range partitions {
+				pl := add(topic, partition)
+				pl.Member = m
+				set(pl)
+			}
+		}
+	}
+
+	for _, ps := range l {
+		for partition, pl := range ps {
+			if _, committed := commits.Lookup(pl.Topic, partition); !committed {
+				pl.Commit = OffsetResponse{Offset: Offset{
+					Topic:       pl.Topic,
+					Partition:   partition,
+					At:          -1,
+					LeaderEpoch: -1,
+				}}
+			}
+			end, endExists := ends.Lookup(pl.Topic, partition)
+			pl.End = end
+			switch {
+			case pl.Commit.Err != nil:
+				pl.Err = pl.Commit.Err
+			case !endExists:
+				pl.Err = errMissingResource
+			case end.Err != nil:
+				pl.Err = end.Err
+			}
+
+			pl.Lag = -1
+			if pl.Err == nil {
+				at := pl.Commit.At
+				if at < 0 {
+					if start, ok := starts.Lookup(pl.Topic, partition); ok && start.Err == nil {
+						at = start.Offset
+					}
+				}
+				if at >= 0 {
+					pl.Lag = end.Offset - at
+					if pl.Lag < 0 {
+						pl.Lag = 0
+					}
+				}
+			}
+			ps[partition] = pl
+		}
+	}
+	return l
+}
+
+// Lag returns the lag of every partition that a group has committed or is
+// assigned, computed from the group's committed offsets and the end offsets
+// of its topics (see ListEndOffsets).
+func (cl *Client) Lag(ctx context.Context, group string) (GroupLag, error) {
+	described, err := cl.DescribeGroups(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	d := described[group]
+	if d.Err != nil {
+		return nil, d.Err
+	}
+	commits, err := cl.FetchOffsets(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	topicSet := make(map[string]struct{})
+	for topic := range commits {
+		topicSet[topic] = struct{}{}
+	}
+	for topic := range d.AssignedPartitions() {
+		topicSet[topic] = struct{}{}
+	}
+	if len(topicSet) == 0 {
+		return make(GroupLag), nil
+	}
+	topics := make([]string, 0, len(topicSet))
+	for topic := range topicSet {
+		topics = append(topics, topic)
+	}
+
+	ends, err := cl.ListEndOffsets(ctx, topics...)
+	if err != nil {
+		return nil, err
+	}
+	starts, err := cl.ListStartOffsets(ctx, topics...)
+	if err != nil {
+		return nil, err
+	}
+	return CalculateGroupLag(d, commits, starts, ends), nil
+}
+
+// ErrGroupNotEmpty is returned from ResetOffsets if the group has active
+// members; offsets can only be reset for groups with no members.
+var ErrGroupNotEmpty = errors.New("group has active members")
+
+// ResetTo is where to reset group offsets to; see ResetOffsets.
+type ResetTo struct {
+	millis int64 // -2 for start, -1 for end
+}
+
+// ResetToStart resets offsets to the start of partitions.
+func ResetToStart() ResetTo { return ResetTo{-2} }
+
+// ResetToEnd resets offsets to the end of partitions.
+func ResetToEnd() ResetTo { return ResetTo{-1} }
+
+// ResetToTime resets offsets to the first record at or after t, or to the end
+// of partitions with no such record.
+func ResetToTime(t time.Time) ResetTo {
+	millis := t.UnixNano() / 1e6
+	if millis < 0 {
+		millis = 0
+	}
+	return ResetTo{millis}
+}
+
+// ResetOffsets resets the committed offsets of a group for every partition of
+// the given topics, or of every topic the group has committed if no topics
+// are given. The group must have no active members, otherwise this returns
+// ErrGroupNotEmpty. To commit explicit offsets, use CommitOffsets.
+//
+// Partitions whose new offsets cannot be listed are returned with the list
+// error and are not committed.
+func (cl *Client) ResetOffsets(ctx context.Context, group string, to ResetTo, topics ...string) (OffsetResponses, error) {
+	described, err := cl.DescribeGroups(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	d := described[group]
+	if d.Err != nil {
+		return nil, d.Err
+	}
+	if d.State != "Empty" && d.State != "Dead" || len(d.Members) > 0 {
+		return nil, fmt.Errorf("%w: group %q is %s with %d members", ErrGroupNotEmpty, group, d.State, len(d.Members))
+	}
+
+	if len(topics) == 0 {
+		commits, err := cl.FetchOffsets(ctx, group)
+		if err != nil {
+			return nil, err
+		}
+		for topic := range commits {
+			topics = append(topics, topic)
+		}
+		if len(topics) == 0 {
+			return make(OffsetResponses), nil
+		}
+	}
+
+	var listed ListedOffsets
+	switch to.millis {
+	case -2:
+		listed, err = cl.ListStartOffsets(ctx, topics...)
+	case -1:
+		listed, err = cl.ListEndOffsets(ctx, topics...)
+	default:
+		listed, err = cl.ListOffsetsAfterMilli(ctx, to.millis, topics...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := cl.CommitOffsets(ctx, group, OffsetsFromListed(listed))
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range listed.Sorted() {
+		if o.Err != nil {
+			rs.add(OffsetResponse{
+				Offset: Offset{Topic: o.Topic, Partition: o.Partition, At: -1, LeaderEpoch: -1},
+				Err:    o.Err,
+			})
+		}
+	}
+	return rs, nil
+}
+
+// DeleteGroupResponse is the result of deleting a group.
+type DeleteGroupResponse struct {
+	Group string
+	Err   error // the error deleting the group, if any
+}
+
+// DeleteGroupResponses are the results of deleting groups, by group name.
+type DeleteGroupResponses map[string]DeleteGroupResponse
+
+// Sorted returns the responses sorted by group.
+func (rs DeleteGroupResponses) Sorted() []DeleteGroupResponse {
+	s := make([]DeleteGroupResponse, 0, len(rs))
+	for _, r := range rs {
+		s = append(s, r)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Group < s[j].Group })
+	return s
+}
+
+// Error returns the first group error, in sorted order, if any.
+func (rs DeleteGroupResponses) Error() error {
+	for _, r := range rs.Sorted() {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+// DeleteGroups deletes the given groups, including their committed offsets.
+// Groups with active members cannot be deleted and are returned with
+// kerr.NonEmptyGroup.
+func (cl *Client) DeleteGroups(ctx context.Context, groups ...string) (DeleteGroupResponses, error) {
+	rs := make(DeleteGroupResponses, len(groups))
+	if len(groups) == 0 {
+		return rs, nil
+	}
+
+	req := kmsg.NewPtrDeleteGroupsRequest()
+	req.Groups = groups
+	errs := cl.shardErrs(ctx, req, func(kresp kmsg.Response) {
+		for _, g := range kresp.(*kmsg.DeleteGroupsResponse).Groups {
+			rs[g.Group] = DeleteGroupResponse{Group: g.Group, Err: kerr.ErrorForCode(g.ErrorCode)}
+		}
+	})
+	for _, se := range errs {
+		for _, group := range se.req.(*kmsg.DeleteGroupsRequest).Groups {
+			rs[group] = DeleteGroupResponse{Group: group, Err: se.err}
+		}
+	}
+	for _, group := range groups {
+		if _, exists := rs[group]; !exists {
+			rs[group] = DeleteGroupResponse{Group: group, Err: errMissingResource}
+		}
+	}
+	return rs, nil
+}
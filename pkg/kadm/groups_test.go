@@ -0,0 +1,72 @@
+package kadm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+)
+
+func TestCalculateGroupLag(t *testing.T) {
+	group := DescribedGroup{
+		Group: "g",
+		State: "Stable",
+		Members: []DescribedGroupMember{{
+			MemberID: "m",
+			Assigned: map[string][]int32{"foo": {0, 1, 2}},
+		}},
+	}
+
+	commits := make(OffsetResponses)
+	commits.add(OffsetResponse{Offset: Offset{Topic: "foo", Partition: 0, At: 5}})
+	commits.add(OffsetResponse{Offset: Offset{Topic: "foo", Partition: 1, At: 12}})
+	commits.add(OffsetResponse{Offset: Offset{Topic: "bar", Partition: 0, At: 3}})
+
+	starts := ListedOffsets{"foo": {2: {Topic: "foo", Partition: 2, Offset: 4}}}
+	ends := ListedOffsets{
+		"foo": {
+			0: {Topic: "foo", Partition: 0, Offset: 10},
+			1: {Topic: "foo", Partition: 1, Offset: 10}, // commit is past the end after truncation
+			2: {Topic: "foo", Partition: 2, Offset: 7},
+		},
+		"bar": {0: {Topic: "bar", Partition: 0, Err: kerr.NotLeaderForPartition}},
+	}
+
+	l := CalculateGroupLag(group, commits, starts, ends)
+	for _, exp := range []struct {
+		topic     string
+		partition int32
+		lag       int64
+		member    bool
+		err       error
+	}{
+		{"bar", 0, -1, false, kerr.NotLeaderForPartition},
+		{"foo", 0, 5, true, nil},
+		{"foo", 1, 0, true, nil},
+		{"foo", 2, 3, true, nil}, // uncommitted: lag from the start offset
+	} {
+		got, exists := l[exp.topic][exp.partition]
+		if !exists {
+			t.Errorf("%s[%d]: missing", exp.topic, exp.partition)
+			continue
+		}
+		if got.Lag != exp.lag {
+			t.Errorf("%s[%d]: got lag %d, expected %d", exp.topic, exp.partition, got.Lag, exp.lag)
+		}
+		if (got.Member != nil) != exp.member {
+			t.Errorf("%s[%d]: got member %v, expected assigned %v", exp.topic, exp.partition, got.Member, exp.member)
+		}
+		if !errors.Is(got.Err, exp.err) && got.Err != exp.err {
+			t.Errorf("%s[%d]: got err %v, expected %v", exp.topic, exp.partition, got.Err, exp.err)
+		}
+	}
+	if got := len(l.Sorted()); got != 4 {
+		t.Errorf("got %d lag entries, expected 4", got)
+	}
+	if total := l.Total(); total != 8 {
+		t.Errorf("got total lag %d, expected 8", total)
+	}
+	if c := l["foo"][2].Commit; c.At != -1 {
+		t.Errorf("got uncommitted offset %d, expected -1", c.At)
+	}
+}
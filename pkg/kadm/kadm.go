@@ -0,0 +1,87 @@
+// Package kadm provides a typed admin client built on a kgo.Client.
+//
+// Every admin request in Kafka can be issued with kgo.Client.Request, but
+// doing so requires building raw kmsg requests, and picking apart top level
+// errors, per resource errors, and shard errors from the responses. This
+// package wraps the common admin requests with typed inputs and results.
+//
+// Functions in this package return an error only if a request could not be
+// issued or a response could not be received at all. Errors for individual
+// resources (topics, configs, and so on) are returned in each resource's
+// result as a kerr error, with any message Kafka returned alongside. Every
+// result type has an Error method that returns the first resource error, for
+// when only success or failure matters.
+package kadm
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// Client is an admin client.
+//
+// This is a thin wrapper around a kgo.Client; the kgo.Client's configuration
+// (seed brokers, SASL, TLS, retries, etc.) is used for every request. The
+// admin client does not need to be closed, but the wrapped client does.
+type Client struct {
+	cl *kgo.Client
+
+	timeoutMillis int32
+}
+
+// NewClient returns an admin client that issues requests with cl.
+func NewClient(cl *kgo.Client) *Client {
+	return &Client{
+		cl:            cl,
+		timeoutMillis: 15000,
+	}
+}
+
+// SetTimeout sets the timeout that is sent in requests that support a
+// timeout (creating and deleting topics, creating partitions, etc.),
+// overriding the default of 15s. Kafka uses the timeout to bound how long it
+// waits for the request to complete on the cluster before replying.
+func (cl *Client) SetTimeout(timeout time.Duration) {
+	cl.timeoutMillis = int32(timeout.Milliseconds())
+}
+
+// Client returns the kgo.Client that this admin client wraps.
+func (cl *Client) Client() *kgo.Client {
+	return cl.cl
+}
+
+// errMessage returns the error message Kafka returned alongside an error
+// code, if any.
+func errMessage(code int16, msg *string) string {
+	if code == 0 || msg == nil {
+		return ""
+	}
+	return *msg
+}
+
+// shardErrs issues a request that may be split across brokers, calling fn
+// with every successful response and returning every shard's request that
+// failed with its error.
+func (cl *Client) shardErrs(ctx context.Context, req kmsg.Request, fn func(kmsg.Response)) []shardErr {
+	var errs []shardErr
+	for _, shard := range cl.cl.RequestSharded(ctx, req) {
+		if shard.Err != nil {
+			errs = append(errs, shardErr{shard.Req, shard.Err})
+			continue
+		}
+		fn(shard.Resp)
+	}
+	return errs
+}
+
+type shardErr struct {
+	req kmsg.Request
+	err error
+}
+
+// errMissingResource is returned for resources that Kafka did not reply to.
+var errMissingResource = errors.New("resource was missing in the response")
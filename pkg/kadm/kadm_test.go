@@ -0,0 +1,57 @@
+package kadm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestNewMetadata(t *testing.T) {
+	resp := kmsg.NewPtrMetadataResponse()
+	resp.ClusterID = kmsg.StringPtr("cluster")
+	resp.ControllerID = 2
+	for _, id := range []int32{3, 1, 2} {
+		b := kmsg.NewMetadataResponseBroker()
+		b.NodeID = id
+		resp.Brokers = append(resp.Brokers, b)
+	}
+
+	foo := kmsg.NewMetadataResponseTopic()
+	foo.Topic = "foo"
+	for p := int32(0); p < 2; p++ {
+		part := kmsg.NewMetadataResponseTopicPartition()
+		part.Partition = p
+		part.Leader = p + 1
+		part.Replicas = []int32{p + 1, 3}
+		foo.Partitions = append(foo.Partitions, part)
+	}
+	foo.Partitions[1].ErrorCode = kerr.LeaderNotAvailable.Code
+	missing := kmsg.NewMetadataResponseTopic()
+	missing.Topic = "missing"
+	missing.ErrorCode = kerr.UnknownTopicOrPartition.Code
+	resp.Topics = []kmsg.MetadataResponseTopic{foo, missing}
+
+	m := newMetadata(resp)
+	if m.Cluster != "cluster" || m.Controller != 2 {
+		t.Errorf("got cluster %q controller %d, expected cluster and 2", m.Cluster, m.Controller)
+	}
+	if ids := m.Brokers.NodeIDs(); len(ids) != 3 || ids[0] != 1 || ids[1] != 2 || ids[2] != 3 {
+		t.Errorf("got broker ids %v, expected sorted 1 2 3", ids)
+	}
+
+	if names := m.Topics.Names(); len(names) != 2 || names[0] != "foo" || names[1] != "missing" {
+		t.Errorf("got topics %v, expected foo and missing", names)
+	}
+	parts := m.Topics["foo"].Partitions.Sorted()
+	if len(parts) != 2 || parts[1].Leader != 2 || parts[1].Replicas[0] != 2 {
+		t.Errorf("got partitions %v, unexpected", parts)
+	}
+	if !errors.Is(parts[1].Err, kerr.LeaderNotAvailable) || parts[0].Err != nil {
+		t.Errorf("got partition errors %v and %v, expected only partition 1 to have an error", parts[0].Err, parts[1].Err)
+	}
+	if err := m.Topics.Error(); !errors.Is(err, kerr.UnknownTopicOrPartition) {
+		t.Errorf("got topics error %v, expected UnknownTopicOrPartition", err)
+	}
+}
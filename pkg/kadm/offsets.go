@@ -0,0 +1,357 @@
+package kadm
+
+import (
+	"context"
+	"sort"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// ListedOffset is the offset of a partition from a list offsets response.
+type ListedOffset struct {
+	Topic     string
+	Partition int32
+
+	Timestamp   int64 // the timestamp of the record at Offset, if listing by timestamp, otherwise -1
+	Offset      int64
+	LeaderEpoch int32 // the leader epoch of the partition, or -1 if unknown (Kafka <2.1)
+
+	Err error // the error listing this partition's offset, if any
+}
+
+// ListedOffsets are listed partition offsets, by topic and partition.
+type ListedOffsets map[string]map[int32]ListedOffset
+
+// Lookup returns the listed offset for a partition, and whether it exists.
+func (os ListedOffsets) Lookup(topic string, partition int32) (ListedOffset, bool) {
+	o, exists := os[topic][partition]
+	return o, exists
+}
+
+// Sorted returns the listed offsets sorted by topic and partition.
+func (os ListedOffsets) Sorted() []ListedOffset {
+	var s []ListedOffset
+	for _, ps := range os {
+		for _, o := range ps {
+			s = append(s, o)
+		}
+	}
+	sort.Slice(s, func(i, j int) bool {
+		return s[i].Topic < s[j].Topic || s[i].Topic == s[j].Topic && s[i].Partition < s[j].Partition
+	})
+	return s
+}
+
+// Error returns the first partition error, in sorted order, if any.
+func (os ListedOffsets) Error() error {
+	for _, o := range os.Sorted() {
+		if o.Err != nil {
+			return o.Err
+		}
+	}
+	return nil
+}
+
+// ListStartOffsets returns the start (log start) offsets of every partition
+// of the given topics, or of all topics if no topics are given.
+func (cl *Client) ListStartOffsets(ctx context.Context, topics ...string) (ListedOffsets, error) {
+	return cl.listOffsets(ctx, -2, topics)
+}
+
+// ListEndOffsets returns the end offsets of every partition of the given
+// topics, or of all topics if no topics are given. End offsets are listed
+// with the read committed isolation level: for partitions with open
+// transactions, this is the last stable offset, and otherwise it is the high
+// watermark.
+func (cl *Client) ListEndOffsets(ctx context.Context, topics ...string) (ListedOffsets, error) {
+	return cl.listOffsets(ctx, -1, topics)
+}
+
+// ListOffsetsAfterMilli returns the first offset of every partition of the
+// given topics, or of all topics if no topics are given, whose record has a
+// timestamp at or after millis. Partitions that have no such record are
+// returned with their end offset and a timestamp of -1.
+func (cl *Client) ListOffsetsAfterMilli(ctx context.Context, millis int64, topics ...string) (ListedOffsets, error) {
+	if millis < 0 {
+		millis = 0 // -1 and -2 are the end and start sentinels
+	}
+	listed, err := cl.listOffsets(ctx, millis, topics)
+	if err != nil {
+		return nil, err
+	}
+
+	// Kafka replies with offset -1 if no record is at or after millis.
+	var past []string
+	for topic, ps := range listed {
+		for _, o := range ps {
+			if o.Err == nil && o.Offset == -1 {
+				past = append(past, topic)
+				break
+			}
+		}
+	}
+	if len(past) == 0 {
+		return listed, nil
+	}
+	ends, err := cl.listOffsets(ctx, -1, past)
+	if err != nil {
+		return nil, err
+	}
+	for topic, ps := range listed {
+		for partition, o := range ps {
+			if o.Err != nil || o.Offset != -1 {
+				continue
+			}
+			if end, exists := ends.Lookup(topic, partition); exists {
+				end.Timestamp = -1
+				ps[partition] = end
+			}
+		}
+	}
+	return listed, nil
+}
+
+// listOffsets lists offsets for every partition of the given topics at the
+// given timestamp, which can be -1 for end offsets or -2 for start offsets.
+func (cl *Client) listOffsets(ctx context.Context, timestamp int64, topics []string) (ListedOffsets, error) {
+	topicDetails, err := cl.ListTopics(ctx, topics...)
+	if err != nil {
+		return nil, err
+	}
+
+	listed := make(ListedOffsets)
+	req := kmsg.NewPtrListOffsetsRequest()
+	req.ReplicaID = -1
+	req.IsolationLevel = 1
+	for _, td := range topicDetails {
+		if td.Err != nil {
+			listed[td.Topic] = map[int32]ListedOffset{-1: {Topic: td.Topic, Partition: -1, Err: td.Err}}
+			continue
+		}
+		t := kmsg.NewListOffsetsRequestTopic()
+		t.Topic = td.Topic
+		for partition := range td.Partitions {
+			p := kmsg.NewListOffsetsRequestTopicPartition()
+			p.Partition = partition
+			p.Timestamp = timestamp
+			t.Partitions = append(t.Partitions, p)
+		}
+		req.Topics = append(req.Topics, t)
+	}
+	if len(req.Topics) == 0 {
+		return listed, nil
+	}
+
+	set := func(o ListedOffset) {
+		ps := listed[o.Topic]
+		if ps == nil {
+			ps = make(map[int32]ListedOffset)
+			listed[o.Topic] = ps
+		}
+		ps[o.Partition] = o
+	}
+	errs := cl.shardErrs(ctx, req, func(kresp kmsg.Response) {
+		resp := kresp.(*kmsg.ListOffsetsResponse)
+		for _, t := range resp.Topics {
+			for _, p := range t.Partitions {
+				o := ListedOffset{
+					Topic:       t.Topic,
+					Partition:   p.Partition,
+					Timestamp:   p.Timestamp,
+					Offset:      p.Offset,
+					LeaderEpoch: p.LeaderEpoch,
+					Err:         kerr.ErrorForCode(p.ErrorCode),
+				}
+				if resp.Version == 0 {
+					o.Offset, o.LeaderEpoch = -1, -1
+					if len(p.OldStyleOffsets) > 0 {
+						o.Offset = p.OldStyleOffsets[0]
+					}
+				}
+				set(o)
+			}
+		}
+	})
+	for _, se := range errs {
+		for _, t := range se.req.(*kmsg.ListOffsetsRequest).Topics {
+			for _, p := range t.Partitions {
+				set(ListedOffset{Topic: t.Topic, Partition: p.Partition, Err: se.err})
+			}
+		}
+	}
+	return listed, nil
+}
+
+// Offset is an offset to commit for a partition.
+type Offset struct {
+	Topic       string
+	Partition   int32
+	At          int64
+	LeaderEpoch int32  // the leader epoch of the record before At, or -1 if unknown
+	Metadata    string // optional metadata to commit with the offset
+}
+
+// Offsets are offsets to commit, by topic and partition.
+type Offsets map[string]map[int32]Offset
+
+// Add adds an offset to commit.
+func (os Offsets) Add(o Offset) {
+	ps := os[o.Topic]
+	if ps == nil {
+		ps = make(map[int32]Offset)
+		os[o.Topic] = ps
+	}
+	ps[o.Partition] = o
+}
+
+// OffsetsFromListed returns offsets to commit from listed offsets, skipping
+// any partitions that were listed with an error. The offsets have an unknown
+// leader epoch, since the listed epoch is the partition's current epoch, not
+// the epoch of the record before each offset.
+func OffsetsFromListed(listed ListedOffsets) Offsets {
+	os := make(Offsets)
+	for _, ps := range listed {
+		for _, o := range ps {
+			if o.Err != nil {
+				continue
+			}
+			os.Add(Offset{
+				Topic:       o.Topic,
+				Partition:   o.Partition,
+				At:          o.Offset,
+				LeaderEpoch: -1,
+			})
+		}
+	}
+	return os
+}
+
+// OffsetResponse is a committed offset for a partition, or the result of
+// committing an offset.
+type OffsetResponse struct {
+	Offset
+	Err error // the error fetching or committing the offset, if any
+}
+
+// OffsetResponses are committed offsets, or the results of committing
+// offsets, by topic and partition.
+type OffsetResponses map[string]map[int32]OffsetResponse
+
+// Lookup returns the response for a partition, and whether it exists.
+func (os OffsetResponses) Lookup(topic string, partition int32) (OffsetResponse, bool) {
+	o, exists := os[topic][partition]
+	return o, exists
+}
+
+// Sorted returns the responses sorted by topic and partition.
+func (os OffsetResponses) Sorted() []OffsetResponse {
+	var s []OffsetResponse
+	for _, ps := range os {
+		for _, o := range ps {
+			s = append(s, o)
+		}
+	}
+	sort.Slice(s, func(i, j int) bool {
+		return s[i].Topic < s[j].Topic || s[i].Topic == s[j].Topic && s[i].Partition < s[j].Partition
+	})
+	return s
+}
+
+// Error returns the first partition error, in sorted order, if any.
+func (os OffsetResponses) Error() error {
+	for _, o := range os.Sorted() {
+		if o.Err != nil {
+			return o.Err
+		}
+	}
+	return nil
+}
+
+func (os OffsetResponses) add(o OffsetResponse) {
+	ps := os[o.Topic]
+	if ps == nil {
+		ps = make(map[int32]OffsetResponse)
+		os[o.Topic] = ps
+	}
+	ps[o.Partition] = o
+}
+
+// FetchOffsets returns the committed offsets of a group. The returned error
+// is non-nil if the request failed or if Kafka returned an error for the
+// group as a whole, such as kerr.GroupAuthorizationFailed.
+func (cl *Client) FetchOffsets(ctx context.Context, group string) (OffsetResponses, error) {
+	req := kmsg.NewPtrOffsetFetchRequest()
+	req.Group = group
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+		return nil, err
+	}
+
+	os := make(OffsetResponses)
+	for _, t := range resp.Topics {
+		for _, p := range t.Partitions {
+			o := OffsetResponse{
+				Offset: Offset{
+					Topic:       t.Topic,
+					Partition:   p.Partition,
+					At:          p.Offset,
+					LeaderEpoch: p.LeaderEpoch,
+				},
+				Err: kerr.ErrorForCode(p.ErrorCode),
+			}
+			if p.Metadata != nil {
+				o.Metadata = *p.Metadata
+			}
+			os.add(o)
+		}
+	}
+	return os, nil
+}
+
+// CommitOffsets commits offsets for a group that has no active members,
+// returning the result of committing each offset. Kafka rejects commits
+// from outside a group that has active members, which is returned as
+// kerr.UnknownMemberID for every partition.
+//
+// See ResetOffsets for resetting offsets to the start or end of partitions or
+// to a timestamp.
+func (cl *Client) CommitOffsets(ctx context.Context, group string, os Offsets) (OffsetResponses, error) {
+	req := kmsg.NewPtrOffsetCommitRequest()
+	req.Group = group
+	for topic, ps := range os {
+		t := kmsg.NewOffsetCommitRequestTopic()
+		t.Topic = topic
+		for _, o := range ps {
+			p := kmsg.NewOffsetCommitRequestTopicPartition()
+			p.Partition = o.Partition
+			p.Offset = o.At
+			p.LeaderEpoch = o.LeaderEpoch
+			if o.Metadata != "" {
+				p.Metadata = kmsg.StringPtr(o.Metadata)
+			}
+			t.Partitions = append(t.Partitions, p)
+		}
+		req.Topics = append(req.Topics, t)
+	}
+	rs := make(OffsetResponses)
+	if len(req.Topics) == 0 {
+		return rs, nil
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range resp.Topics {
+		for _, p := range t.Partitions {
+			o := os[t.Topic][p.Partition]
+			o.Topic, o.Partition = t.Topic, p.Partition
+			rs.add(OffsetResponse{Offset: o, Err: kerr.ErrorForCode(p.ErrorCode)})
+		}
+	}
+	return rs, nil
+}
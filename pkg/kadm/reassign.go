@@ -0,0 +1,847 @@
+package kadm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// PartitionSizes are the on disk sizes of partitions in bytes, by topic and
+// partition.
+type PartitionSizes map[string]map[int32]int64
+
+// DescribePartitionSizes returns the size of every partition of the given
+// topics, or of all topics if no topics are given, as described by each
+// partition's replicas with DescribeLogDirs. The size of a partition is the
+// size of its largest replica.
+//
+// Sizes are described from every broker that has a replica. If any broker
+// cannot be described, this returns the sizes that could be described along
+// with the first error.
+func (cl *Client) DescribePartitionSizes(ctx context.Context, topics ...string) (PartitionSizes, error) {
+	tds, err := cl.ListTopicsWithInternal(ctx, topics...)
+	if err != nil {
+		return nil, err
+	}
+	return cl.describePartitionSizes(ctx, tds)
+}
+
+func (cl *Client) describePartitionSizes(ctx context.Context, tds TopicDetails) (PartitionSizes, error) {
+	sizes := make(PartitionSizes)
+	req := kmsg.NewPtrDescribeLogDirsRequest()
+	for _, td := range tds.Sorted() {
+		if td.Err != nil || len(td.Partitions) == 0 {
+			continue
+		}
+		t := kmsg.NewDescribeLogDirsRequestTopic()
+		t.Topic = td.Topic
+		for _, pd := range td.Partitions.Sorted() {
+			t.Partitions = append(t.Partitions, pd.Partition)
+		}
+		req.Topics = append(req.Topics, t)
+	}
+	if len(req.Topics) == 0 {
+		return sizes, nil
+	}
+
+	errs := cl.shardErrs(ctx, req, func(kresp kmsg.Response) {
+		resp := kresp.(*kmsg.DescribeLogDirsResponse)
+		for _, d := range resp.Dirs {
+			if d.ErrorCode != 0 {
+				continue // an offline log dir has no sizes
+			}
+			for _, t := range d.Topics {
+				ps := sizes[t.Topic]
+				if ps == nil {
+					ps = make(map[int32]int64)
+					sizes[t.Topic] = ps
+				}
+				for _, p := range t.Partitions {
+					if !p.IsFuture && p.Size > ps[p.Partition] {
+						ps[p.Partition] = p.Size
+					}
+				}
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return sizes, errs[0].err
+	}
+	return sizes, nil
+}
+
+// PlannedReassignment is a partition whose replicas are to be reassigned.
+type PlannedReassignment struct {
+	Topic     string
+	Partition int32
+
+	From []int32 // the current replicas
+	To   []int32 // the new replicas; the first replica is the preferred leader
+
+	Size int64 // the size of the partition, if known
+}
+
+// Adding returns the replicas in To that are not in From.
+func (r *PlannedReassignment) Adding() []int32 { return int32sMissing(r.To, r.From) }
+
+// Removing returns the replicas in From that are not in To.
+func (r *PlannedReassignment) Removing() []int32 { return int32sMissing(r.From, r.To) }
+
+func int32sMissing(l, r []int32) []int32 {
+	var missing []int32
+outer:
+	for _, li := range l {
+		for _, ri := range r {
+			if li == ri {
+				continue outer
+			}
+		}
+		missing = append(missing, li)
+	}
+	return missing
+}
+
+func int32sEqual(l, r []int32) bool {
+	if len(l) != len(r) {
+		return false
+	}
+	for i := range l {
+		if l[i] != r[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ReassignmentPlan is a set of partition reassignments, sorted by topic and
+// partition.
+type ReassignmentPlan []PlannedReassignment
+
+// MovedBytes returns the number of bytes that must be copied to new replicas
+// to execute the plan.
+func (p ReassignmentPlan) MovedBytes() int64 {
+	var moved int64
+	for i := range p {
+		moved += p[i].Size * int64(len(p[i].Adding()))
+	}
+	return moved
+}
+
+// Waves splits the plan into waves of at most maxPartitions partitions and at
+// most maxBytes moved bytes each. A limit of zero is unlimited. A partition
+// that alone moves more than maxBytes is placed in its own wave.
+func (p ReassignmentPlan) Waves(maxPartitions int, maxBytes int64) []ReassignmentPlan {
+	var (
+		waves []ReassignmentPlan
+		wave  ReassignmentPlan
+		bytes int64
+	)
+	for _, r := range p {
+		moved := r.Size * int64(len(r.Adding()))
+		if len(wave) > 0 && (maxPartitions > 0 && len(wave) >= maxPartitions ||
+			maxBytes > 0 && bytes+moved > maxBytes) {
+			waves = append(waves, wave)
+			wave, bytes = nil, 0
+		}
+		wave = append(wave, r)
+		bytes += moved
+	}
+	if len(wave) > 0 {
+		waves = append(waves, wave)
+	}
+	return waves
+}
+
+func (p ReassignmentPlan) topics() []string {
+	var topics []string
+	for _, r := range p {
+		if len(topics) == 0 || topics[len(topics)-1] != r.Topic {
+			topics = append(topics, r.Topic)
+		}
+	}
+	return topics
+}
+
+// PlanReassignment plans moving the replicas of the given topics, or of all
+// topics if no topics are given, so that they are only on the given brokers.
+// See CalculateReassignment for how replicas are placed.
+//
+// Partition sizes are described with DescribePartitionSizes. Brokers that
+// cannot be described (such as offline brokers being removed) are ignored, so
+// sizes may be missing for some partitions.
+func (cl *Client) PlanReassignment(ctx context.Context, brokers []int32, topics ...string) (ReassignmentPlan, error) {
+	m, err := cl.Metadata(ctx, topics...)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Topics.Error(); err != nil {
+		return nil, err
+	}
+	sizes, _ := cl.describePartitionSizes(ctx, m.Topics)
+	return CalculateReassignment(m, sizes, brokers)
+}
+
+// CalculateReassignment returns a plan that moves every replica of every
+// topic in the metadata onto the given brokers, keeping the replication factor
+// of each partition.
+//
+// The plan moves as little as possible: replicas already on a given broker
+// stay where they are, unless their broker has more than its share of
+// replicas or, if every given broker has a rack, they share a rack with
+// another replica of the same partition when enough racks exist to avoid it.
+// Replicas that must move are placed largest partition first, preferring
+// brokers in racks the partition is not yet in, then brokers with the fewest
+// replicas, then brokers with the fewest bytes.
+//
+// Kept replicas keep their relative order, so the preferred leader only
+// changes if it is moved. Partitions whose replicas do not change are not in
+// the plan.
+func CalculateReassignment(m Metadata, sizes PartitionSizes, brokers []int32) (ReassignmentPlan, error) {
+	if len(brokers) == 0 {
+		return nil, errors.New("no brokers to reassign onto")
+	}
+	known := make(map[int32]BrokerDetail, len(m.Brokers))
+	for _, b := range m.Brokers {
+		known[b.NodeID] = b
+	}
+	racks := make(map[int32]string, len(brokers))
+	rackSet := make(map[string]bool)
+	rackAware := true
+	var targets []int32
+	for _, id := range brokers {
+		b, exists := known[id]
+		if !exists {
+			return nil, fmt.Errorf("broker %d is not in the cluster", id)
+		}
+		if _, dup := racks[id]; dup {
+			continue
+		}
+		targets = append(targets, id)
+		if b.Rack == nil || *b.Rack == "" {
+			rackAware = false
+			racks[id] = ""
+			continue
+		}
+		racks[id] = *b.Rack
+		rackSet[*b.Rack] = true
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i] < targets[j] })
+	isTarget := func(id int32) bool { _, ok := racks[id]; return ok }
+
+	type part struct {
+		topic     string
+		partition int32
+		size      int64
+		rf        int
+		from      []int32
+		to        []int32
+	}
+	var (
+		parts []*part
+		total int
+	)
+	count := make(map[int32]int, len(targets))
+	bytes := make(map[int32]int64, len(targets))
+	for _, td := range m.Topics.Sorted() {
+		if td.Err != nil {
+			return nil, fmt.Errorf("topic %s: %w", td.Topic, td.Err)
+		}
+		for _, pd := range td.Partitions.Sorted() {
+			p := &part{
+				topic:     td.Topic,
+				partition: pd.Partition,
+				size:      sizes[td.Topic][pd.Partition],
+				rf:        len(pd.Replicas),
+				from:      pd.Replicas,
+			}
+			if p.rf > len(targets) {
+				return nil, fmt.Errorf("%s[%d] has %d replicas but only %d brokers were given", p.topic, p.partition, p.rf, len(targets))
+			}
+			spread := rackAware && len(rackSet) >= p.rf
+			used := make(map[string]bool)
+			for _, id := range p.from {
+				if !isTarget(id) || spread && used[racks[id]] {
+					continue
+				}
+				used[racks[id]] = true
+				p.to = append(p.to, id)
+				count[id]++
+				bytes[id] += p.size
+			}
+			parts = append(parts, p)
+			total += p.rf
+		}
+	}
+
+	// Shed replicas from brokers over their share, smallest partitions
+	// first and preferred leaders last, so that the least data moves and
+	// leadership is kept where possible.
+	share := (total + len(targets) - 1) / len(targets)
+	for _, id := range targets {
+		if count[id] <= share {
+			continue
+		}
+		type held struct {
+			p      *part
+			leader bool
+		}
+		var on []held
+		for _, p := range parts {
+			for i, r := range p.to {
+				if r == id {
+					on = append(on, held{p, i == 0})
+				}
+			}
+		}
+		sort.SliceStable(on, func(i, j int) bool {
+			if on[i].leader != on[j].leader {
+				return !on[i].leader
+			}
+			return on[i].p.size < on[j].p.size
+		})
+		for _, h := range on[:count[id]-share] {
+			h.p.to = int32sMissing(h.p.to, []int32{id})
+			count[id]--
+			bytes[id] -= h.p.size
+		}
+	}
+
+	// Fill missing replicas, largest partitions first to balance bytes.
+	fill := make([]*part, len(parts))
+	copy(fill, parts)
+	sort.SliceStable(fill, func(i, j int) bool { return fill[i].size > fill[j].size })
+	for _, p := range fill {
+		for len(p.to) < p.rf {
+			used := make(map[string]bool)
+			for _, id := range p.to {
+				used[racks[id]] = true
+			}
+			best := int32(-1)
+			var bestRackUsed bool
+			for _, id := range targets {
+				if len(int32sMissing([]int32{id}, p.to)) == 0 {
+					continue // already a replica
+				}
+				rackUsed := rackAware && used[racks[id]]
+				if best == -1 ||
+					rackUsed != bestRackUsed && !rackUsed ||
+					rackUsed == bestRackUsed && (count[id] < count[best] ||
+						count[id] == count[best] && bytes[id] < bytes[best]) {
+					best, bestRackUsed = id, rackUsed
+				}
+			}
+			p.to = append(p.to, best)
+			count[best]++
+			bytes[best] += p.size
+		}
+	}
+
+	var plan ReassignmentPlan
+	for _, p := range parts {
+		if int32sEqual(p.from, p.to) {
+			continue
+		}
+		plan = append(plan, PlannedReassignment{
+			Topic:     p.topic,
+			Partition: p.partition,
+			From:      p.from,
+			To:        p.to,
+			Size:      p.size,
+		})
+	}
+	return plan, nil
+}
+
+// AlterPartitionAssignmentsResponse is the result of reassigning a partition,
+// or of cancelling its reassignment.
+type AlterPartitionAssignmentsResponse struct {
+	Topic     string
+	Partition int32
+
+	Err        error  // the error reassigning the partition, if any
+	ErrMessage string // a message describing the error, if Kafka returned one
+}
+
+// AlterPartitionAssignmentsResponses are the results of reassigning
+// partitions, by topic and partition.
+type AlterPartitionAssignmentsResponses map[string]map[int32]AlterPartitionAssignmentsResponse
+
+// Sorted returns the responses sorted by topic and partition.
+func (rs AlterPartitionAssignmentsResponses) Sorted() []AlterPartitionAssignmentsResponse {
+	var s []AlterPartitionAssignmentsResponse
+	for _, ps := range rs {
+		for _, r := range ps {
+			s = append(s, r)
+		}
+	}
+	sort.Slice(s, func(i, j int) bool {
+		return s[i].Topic < s[j].Topic || s[i].Topic == s[j].Topic && s[i].Partition < s[j].Partition
+	})
+	return s
+}
+
+// Error returns the first partition error, in sorted order, if any.
+func (rs AlterPartitionAssignmentsResponses) Error() error {
+	for _, r := range rs.Sorted() {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+func (rs AlterPartitionAssignmentsResponses) add(r AlterPartitionAssignmentsResponse) {
+	ps := rs[r.Topic]
+	if ps == nil {
+		ps = make(map[int32]AlterPartitionAssignmentsResponse)
+		rs[r.Topic] = ps
+	}
+	ps[r.Partition] = r
+}
+
+// AlterPartitionAssignments starts reassigning every partition in the plan to
+// its new replicas. Kafka replies once the reassignments have started; see
+// ListPartitionReassignments for tracking them and ExecuteReassignment for
+// executing a plan to completion. This requires Kafka 2.4+.
+func (cl *Client) AlterPartitionAssignments(ctx context.Context, plan ReassignmentPlan) (AlterPartitionAssignmentsResponses, error) {
+	return cl.alterPartitionAssignments(ctx, plan, false)
+}
+
+// CancelPartitionReassignments cancels the in progress reassignments of every
+// partition in the plan, or of every partition in the cluster if the plan is
+// empty, reverting them to their replicas from before the reassignment.
+// Partitions that are not being reassigned are returned with
+// kerr.NoReassignmentInProgress.
+func (cl *Client) CancelPartitionReassignments(ctx context.Context, plan ReassignmentPlan) (AlterPartitionAssignmentsResponses, error) {
+	if len(plan) == 0 {
+		listed, err := cl.ListPartitionReassignments(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range listed.Sorted() {
+			plan = append(plan, PlannedReassignment{Topic: l.Topic, Partition: l.Partition})
+		}
+	}
+	return cl.alterPartitionAssignments(ctx, plan, true)
+}
+
+func (cl *Client) alterPartitionAssignments(ctx context.Context, plan ReassignmentPlan, cancel bool) (AlterPartitionAssignmentsResponses, error) {
+	rs := make(AlterPartitionAssignmentsResponses)
+	if len(plan) == 0 {
+		return rs, nil
+	}
+
+	req := kmsg.NewPtrAlterPartitionAssignmentsRequest()
+	req.TimeoutMillis = cl.timeoutMillis
+	byTopic := make(map[string]int)
+	for _, r := range plan {
+		i, exists := byTopic[r.Topic]
+		if !exists {
+			i = len(req.Topics)
+			byTopic[r.Topic] = i
+			t := kmsg.NewAlterPartitionAssignmentsRequestTopic()
+			t.Topic = r.Topic
+			req.Topics = append(req.Topics, t)
+		}
+		p := kmsg.NewAlterPartitionAssignmentsRequestTopicPartition()
+		p.Partition = r.Partition
+		if !cancel {
+			p.Replicas = r.To // nil replicas cancels
+		}
+		req.Topics[i].Partitions = append(req.Topics[i].Partitions, p)
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+		msg := errMessage(resp.ErrorCode, resp.ErrorMessage)
+		for _, r := range plan {
+			rs.add(AlterPartitionAssignmentsResponse{Topic: r.Topic, Partition: r.Partition, Err: err, ErrMessage: msg})
+		}
+		return rs, nil
+	}
+	for _, t := range resp.Topics {
+		for _, p := range t.Partitions {
+			rs.add(AlterPartitionAssignmentsResponse{
+				Topic:      t.Topic,
+				Partition:  p.Partition,
+				Err:        kerr.ErrorForCode(p.ErrorCode),
+				ErrMessage: errMessage(p.ErrorCode, p.ErrorMessage),
+			})
+		}
+	}
+	for _, r := range plan {
+		if _, exists := rs[r.Topic][r.Partition]; !exists {
+			rs.add(AlterPartitionAssignmentsResponse{Topic: r.Topic, Partition: r.Partition, Err: errMissingResource})
+		}
+	}
+	return rs, nil
+}
+
+// ListedReassignment is a partition that is being reassigned.
+type ListedReassignment struct {
+	Topic     string
+	Partition int32
+
+	Replicas []int32 // the current replica set, including adding and removing replicas
+	Adding   []int32 // replicas being added
+	Removing []int32 // replicas being removed
+}
+
+// ListedReassignments are partitions that are being reassigned, by topic and
+// partition.
+type ListedReassignments map[string]map[int32]ListedReassignment
+
+// Lookup returns the reassignment of a partition, and whether it is being
+// reassigned.
+func (ls ListedReassignments) Lookup(topic string, partition int32) (ListedReassignment, bool) {
+	l, exists := ls[topic][partition]
+	return l, exists
+}
+
+// Sorted returns the reassignments sorted by topic and partition.
+func (ls ListedReassignments) Sorted() []ListedReassignment {
+	var s []ListedReassignment
+	for _, ps := range ls {
+		for _, l := range ps {
+			s = append(s, l)
+		}
+	}
+	sort.Slice(s, func(i, j int) bool {
+		return s[i].Topic < s[j].Topic || s[i].Topic == s[j].Topic && s[i].Partition < s[j].Partition
+	})
+	return s
+}
+
+// ListPartitionReassignments returns the in progress reassignments of the
+// partitions in the plan, or of every partition in the cluster if the plan is
+// empty. Partitions that are not being reassigned are not returned. This
+// requires Kafka 2.4+.
+func (cl *Client) ListPartitionReassignments(ctx context.Context, plan ReassignmentPlan) (ListedReassignments, error) {
+	req := kmsg.NewPtrListPartitionReassignmentsRequest()
+	req.TimeoutMillis = cl.timeoutMillis
+	byTopic := make(map[string]int)
+	for _, r := range plan {
+		i, exists := byTopic[r.Topic]
+		if !exists {
+			i = len(req.Topics)
+			byTopic[r.Topic] = i
+			t := kmsg.NewListPartitionReassignmentsRequestTopic()
+			t.Topic = r.Topic
+			req.Topics = append(req.Topics, t)
+		}
+		req.Topics[i].Partitions = append(req.Topics[i].Partitions, r.Partition)
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+		return nil, err
+	}
+	ls := make(ListedReassignments)
+	for _, t := range resp.Topics {
+		ps := make(map[int32]ListedReassignment, len(t.Partitions))
+		for _, p := range t.Partitions {
+			ps[p.Partition] = ListedReassignment{
+				Topic:     t.Topic,
+				Partition: p.Partition,
+				Replicas:  p.Replicas,
+				Adding:    p.AddingReplicas,
+				Removing:  p.RemovingReplicas,
+			}
+		}
+		if len(ps) > 0 {
+			ls[t.Topic] = ps
+		}
+	}
+	return ls, nil
+}
+
+// ReassignmentThrottleResponses are the results of throttling a reassignment
+// plan, or of removing the throttle.
+type ReassignmentThrottleResponses struct {
+	// Brokers are the results of altering the replication rate configs of
+	// every broker in the plan, sorted by node ID. Brokers that are not
+	// live are not altered, and have kerr.BrokerNotAvailable.
+	Brokers AlterConfigsResponses
+
+	// Topics are the results of altering the throttled replicas configs of
+	// every topic in the plan, sorted by topic.
+	Topics AlterConfigsResponses
+}
+
+// Error returns the first broker or topic error, if any.
+func (rs ReassignmentThrottleResponses) Error() error {
+	if err := rs.Brokers.Error(); err != nil {
+		return err
+	}
+	return rs.Topics.Error()
+}
+
+// ThrottleReassignment limits the replication rate of the brokers and
+// partitions in the plan to bytesPerSec, as kafka-reassign-partitions.sh
+// does.
+//
+// This sets the leader.replication.throttled.rate and
+// follower.replication.throttled.rate configs on every live broker in the
+// plan. For every topic in the plan, this appends the current replicas of
+// the moving partitions to the leader.replication.throttled.replicas config,
+// and the replicas being added to the follower.replication.throttled.replicas
+// config, as partition:broker pairs. Other entries in these lists are kept,
+// but any existing rates are overwritten. See RemoveReassignmentThrottle.
+//
+// Every broker and topic is altered even if some fail; this returns an error
+// only if the live brokers could not be loaded.
+func (cl *Client) ThrottleReassignment(ctx context.Context, plan ReassignmentPlan, bytesPerSec int64) (ReassignmentThrottleResponses, error) {
+	rate := strconv.FormatInt(bytesPerSec, 10)
+	return cl.alterReassignmentThrottle(ctx, plan, SetConfig, AppendConfig, &rate)
+}
+
+// RemoveReassignmentThrottle removes the throttle set by ThrottleReassignment
+// for the brokers and partitions in the plan. The replication rate configs are
+// deleted from every live broker in the plan, and the plan's partition:broker
+// pairs are removed from the throttled replicas configs of every topic in the
+// plan, keeping any other entries.
+func (cl *Client) RemoveReassignmentThrottle(ctx context.Context, plan ReassignmentPlan) (ReassignmentThrottleResponses, error) {
+	return cl.alterReassignmentThrottle(ctx, plan, DeleteConfig, SubtractConfig, nil)
+}
+
+func (cl *Client) alterReassignmentThrottle(ctx context.Context, plan ReassignmentPlan, rateOp, replicasOp IncrementalOp, rate *string) (ReassignmentThrottleResponses, error) {
+	var rs ReassignmentThrottleResponses
+	if len(plan) == 0 {
+		return rs, nil
+	}
+
+	m, err := cl.Metadata(ctx, plan.topics()...)
+	if err != nil {
+		return rs, err
+	}
+	live := make(map[int32]bool, len(m.Brokers))
+	for _, b := range m.Brokers {
+		live[b.NodeID] = true
+	}
+
+	seen := make(map[int32]bool)
+	var brokers, dead []int32
+	for _, r := range plan {
+		for _, ids := range [][]int32{r.From, r.To} {
+			for _, id := range ids {
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+				if live[id] {
+					brokers = append(brokers, id)
+				} else {
+					dead = append(dead, id)
+				}
+			}
+		}
+	}
+
+	// AlterBrokerConfigs with no brokers alters the cluster defaults, which
+	// we never want here.
+	if len(brokers) > 0 {
+		if rs.Brokers, err = cl.AlterBrokerConfigs(ctx, []AlterConfig{
+			{Op: rateOp, Name: "leader.replication.throttled.rate", Value: rate},
+			{Op: rateOp, Name: "follower.replication.throttled.rate", Value: rate},
+		}, brokers...); err != nil {
+			return rs, err
+		}
+	}
+	for _, id := range dead {
+		rs.Brokers = append(rs.Brokers, AlterConfigsResponse{
+			Name: strconv.Itoa(int(id)),
+			Err:  kerr.BrokerNotAvailable,
+		})
+	}
+	sort.Slice(rs.Brokers, func(i, j int) bool {
+		l, _ := strconv.Atoi(rs.Brokers[i].Name)
+		r, _ := strconv.Atoi(rs.Brokers[j].Name)
+		return l < r
+	})
+
+	leaders, followers := plan.throttledReplicas()
+	rs.Topics, err = cl.alterEachConfigs(ctx, false, kmsg.ConfigResourceTypeTopic, plan.topics(), func(topic string) []AlterConfig {
+		var configs []AlterConfig
+		if v, ok := leaders[topic]; ok {
+			configs = append(configs, AlterConfig{Op: replicasOp, Name: "leader.replication.throttled.replicas", Value: &v})
+		}
+		if v, ok := followers[topic]; ok {
+			configs = append(configs, AlterConfig{Op: replicasOp, Name: "follower.replication.throttled.replicas", Value: &v})
+		}
+		return configs
+	})
+	return rs, err
+}
+
+// throttledReplicas returns, per topic, the partition:broker lists to throttle
+// for leaders (the current replicas of moving partitions) and for followers
+// (the replicas being added), as kafka-reassign-partitions.sh does.
+func (p ReassignmentPlan) throttledReplicas() (leaders, followers map[string]string) {
+	leaders = make(map[string]string)
+	followers = make(map[string]string)
+	add := func(lists map[string]string, topic string, partition int32, brokers []int32) {
+		for _, b := range brokers {
+			pair := fmt.Sprintf("%d:%d", partition, b)
+			if list, ok := lists[topic]; ok {
+				lists[topic] = list + "," + pair
+			} else {
+				lists[topic] = pair
+			}
+		}
+	}
+	for _, r := range p {
+		adding := r.Adding()
+		if len(adding) == 0 {
+			continue // only reordering replicas moves no data
+		}
+		add(leaders, r.Topic, r.Partition, r.From)
+		add(followers, r.Topic, r.Partition, adding)
+	}
+	return leaders, followers
+}
+
+// ReassignmentOptions configure ExecuteReassignment.
+type ReassignmentOptions struct {
+	// WavePartitions and WaveBytes limit the partitions and moved bytes
+	// of each wave; see ReassignmentPlan.Waves. Zero is unlimited.
+	WavePartitions int
+	WaveBytes      int64
+
+	// ThrottleBytesPerSec, if positive, throttles replication while the
+	// plan executes; see ThrottleReassignment. The throttle is removed
+	// once the plan completes, but is left in place if execution stops
+	// early. Brokers or topics that cannot be throttled do not stop
+	// execution; see ReassignmentProgress.Throttle.
+	ThrottleBytesPerSec int64
+
+	// PollInterval is how often to list in progress reassignments, with a
+	// default of 5s.
+	PollInterval time.Duration
+
+	// OnProgress, if non-nil, is called after every poll.
+	OnProgress func(ReassignmentProgress)
+}
+
+// ReassignmentProgress is the progress of an executing reassignment plan.
+type ReassignmentProgress struct {
+	Wave  int // the current wave, starting at 1
+	Waves int
+
+	DonePartitions   int // partitions that finished or failed to start
+	FailedPartitions int // partitions that failed to start
+	TotalPartitions  int
+
+	DoneBytes  int64 // bytes moved by finished partitions
+	TotalBytes int64
+
+	// InProgress are the partitions of the current wave that are still
+	// being reassigned.
+	InProgress ListedReassignments
+
+	// Throttle is the result of throttling the plan, if
+	// ThrottleBytesPerSec is positive.
+	Throttle ReassignmentThrottleResponses
+}
+
+// ExecuteReassignment executes a plan in waves, starting each wave once every
+// reassignment of the previous wave finishes, and returns the result of
+// starting each partition's reassignment.
+//
+// Partitions that fail to start are skipped. If ctx is canceled, this stops
+// waiting and returns ctx.Err(); reassignments that already started continue
+// on the cluster, and can be cancelled with CancelPartitionReassignments. If
+// the throttle cannot be fully removed once the plan completes, this returns
+// the first broker or topic error from removing it.
+func (cl *Client) ExecuteReassignment(ctx context.Context, plan ReassignmentPlan, opts ReassignmentOptions) (AlterPartitionAssignmentsResponses, error) {
+	poll := opts.PollInterval
+	if poll <= 0 {
+		poll = 5 * time.Second
+	}
+	waves := plan.Waves(opts.WavePartitions, opts.WaveBytes)
+	progress := ReassignmentProgress{
+		Waves:           len(waves),
+		TotalPartitions: len(plan),
+		TotalBytes:      plan.MovedBytes(),
+	}
+
+	rs := make(AlterPartitionAssignmentsResponses)
+	if opts.ThrottleBytesPerSec > 0 {
+		throttled, err := cl.ThrottleReassignment(ctx, plan, opts.ThrottleBytesPerSec)
+		if err != nil {
+			return rs, err
+		}
+		progress.Throttle = throttled
+	}
+
+	for i, wave := range waves {
+		progress.Wave = i + 1
+		started, err := cl.AlterPartitionAssignments(ctx, wave)
+		if err != nil {
+			return rs, err
+		}
+		var running ReassignmentPlan
+		for _, r := range wave {
+			sr := started[r.Topic][r.Partition]
+			rs.add(sr)
+			if sr.Err != nil {
+				progress.DonePartitions++
+				progress.FailedPartitions++
+				continue
+			}
+			running = append(running, r)
+		}
+
+		for len(running) > 0 {
+			listed, err := cl.ListPartitionReassignments(ctx, running)
+			if err != nil {
+				return rs, err
+			}
+			still := running[:0]
+			for _, r := range running {
+				if _, inProgress := listed.Lookup(r.Topic, r.Partition); inProgress {
+					still = append(still, r)
+					continue
+				}
+				progress.DonePartitions++
+				progress.DoneBytes += r.Size * int64(len(r.Adding()))
+			}
+			running = still
+			progress.InProgress = listed
+			if opts.OnProgress != nil {
+				opts.OnProgress(progress)
+			}
+			if len(running) == 0 {
+				break
+			}
+
+			timer := time.NewTimer(poll)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return rs, ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+
+	if opts.ThrottleBytesPerSec > 0 {
+		removed, err := cl.RemoveReassignmentThrottle(ctx, plan)
+		if err != nil {
+			return rs, err
+		}
+		return rs, removed.Error()
+	}
+	return rs, nil
+}
@@ -0,0 +1,111 @@
+package kadm
+
+import (
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestCalculateReassignment(t *testing.T) {
+	meta := func(racks map[int32]string, replicas ...[]int32) Metadata {
+		m := Metadata{Topics: TopicDetails{"t": {Topic: "t", Partitions: make(PartitionDetails)}}}
+		for id := int32(1); id <= int32(len(racks)); id++ {
+			b := BrokerDetail{NodeID: id}
+			if rack := racks[id]; rack != "" {
+				b.Rack = kmsg.StringPtr(rack)
+			}
+			m.Brokers = append(m.Brokers, b)
+		}
+		for p, rs := range replicas {
+			m.Topics["t"].Partitions[int32(p)] = PartitionDetail{Topic: "t", Partition: int32(p), Replicas: rs}
+		}
+		return m
+	}
+	counts := func(m Metadata, plan ReassignmentPlan) map[int32]int {
+		final := make(map[int32][]int32)
+		for p, pd := range m.Topics["t"].Partitions {
+			final[p] = pd.Replicas
+		}
+		for _, r := range plan {
+			final[r.Partition] = r.To
+		}
+		c := make(map[int32]int)
+		for _, rs := range final {
+			for _, r := range rs {
+				c[r]++
+			}
+		}
+		return c
+	}
+
+	// Adding broker 4 sheds one replica from each existing broker, and
+	// keeps the preferred leader of every partition that moves.
+	noRacks := map[int32]string{1: "", 2: "", 3: "", 4: ""}
+	m := meta(noRacks, []int32{1, 2}, []int32{2, 3}, []int32{3, 1}, []int32{1, 2}, []int32{2, 3}, []int32{3, 1})
+	sizes := PartitionSizes{"t": {0: 10, 1: 20, 2: 30, 3: 40, 4: 50, 5: 60}}
+	plan, err := CalculateReassignment(m, sizes, []int32{1, 2, 3, 4})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(plan) != 3 {
+		t.Errorf("got %d moves, expected 3: %v", len(plan), plan)
+	}
+	for _, r := range plan {
+		if r.To[0] != r.From[0] || len(r.Adding()) != 1 || r.Adding()[0] != 4 {
+			t.Errorf("%s[%d]: got %v => %v, expected one replica moved to 4 with the same leader", r.Topic, r.Partition, r.From, r.To)
+		}
+	}
+	for id, c := range counts(m, plan) {
+		if c < 1 || c > 3 {
+			t.Errorf("broker %d has %d replicas, expected between 1 and 3", id, c)
+		}
+	}
+	if moved := plan.MovedBytes(); moved != 10+20+30 {
+		t.Errorf("got %d moved bytes, expected the smallest partitions to move (60)", moved)
+	}
+	if waves := plan.Waves(0, 35); len(waves) != 2 || len(waves[0]) != 2 {
+		t.Errorf("got waves %v, expected two waves of 2 and 1", waves)
+	}
+
+	// Removing broker 3 moves only its replicas.
+	m = meta(map[int32]string{1: "", 2: "", 3: ""}, []int32{1, 2}, []int32{3, 1})
+	plan, err = CalculateReassignment(m, nil, []int32{1, 2})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(plan) != 1 || plan[0].Partition != 1 || plan[0].To[0] != 1 || plan[0].To[1] != 2 {
+		t.Errorf("got plan %v, expected only partition 1 to move to [1 2]", plan)
+	}
+
+	// Replicas sharing a rack are spread when enough racks exist.
+	m = meta(map[int32]string{1: "a", 2: "a", 3: "b"}, []int32{1, 2})
+	plan, err = CalculateReassignment(m, nil, []int32{1, 2, 3})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(plan) != 1 || plan[0].To[0] != 1 || plan[0].To[1] != 3 {
+		t.Errorf("got plan %v, expected [1 2] => [1 3]", plan)
+	}
+
+	if _, err := CalculateReassignment(m, nil, []int32{1}); err == nil {
+		t.Error("expected error reassigning two replicas onto one broker")
+	}
+	if _, err := CalculateReassignment(m, nil, []int32{1, 9}); err == nil {
+		t.Error("expected error reassigning onto an unknown broker")
+	}
+}
+
+func TestReassignmentThrottledReplicas(t *testing.T) {
+	plan := ReassignmentPlan{
+		{Topic: "a", Partition: 0, From: []int32{1, 2}, To: []int32{1, 3}},
+		{Topic: "a", Partition: 1, From: []int32{2, 1}, To: []int32{1, 2}}, // reordered only
+		{Topic: "a", Partition: 2, From: []int32{3}, To: []int32{4}},
+	}
+	leaders, followers := plan.throttledReplicas()
+	if got, exp := leaders["a"], "0:1,0:2,2:3"; got != exp {
+		t.Errorf("got leader throttled replicas %q, expected %q", got, exp)
+	}
+	if got, exp := followers["a"], "0:3,2:4"; got != exp {
+		t.Errorf("got follower throttled replicas %q, expected %q", got, exp)
+	}
+}
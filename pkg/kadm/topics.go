@@ -0,0 +1,471 @@
+package kadm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// BrokerDetail is a broker from a metadata response.
+type BrokerDetail struct {
+	NodeID int32
+	Host   string
+	Port   int32
+	Rack   *string
+}
+
+// BrokerDetails are brokers from a metadata response, sorted by node ID.
+type BrokerDetails []BrokerDetail
+
+// NodeIDs returns the node ID of every broker.
+func (ds BrokerDetails) NodeIDs() []int32 {
+	ids := make([]int32, 0, len(ds))
+	for _, d := range ds {
+		ids = append(ids, d.NodeID)
+	}
+	return ids
+}
+
+// PartitionDetail is a partition from a metadata response.
+type PartitionDetail struct {
+	Topic     string
+	Partition int32
+
+	Leader          int32
+	LeaderEpoch     int32
+	Replicas        []int32
+	ISR             []int32
+	OfflineReplicas []int32
+
+	// Err is the error for this partition, if any. Leader not available
+	// errors are common while a partition is electing a leader.
+	Err error
+}
+
+// PartitionDetails are the partitions of a topic, by partition number.
+type PartitionDetails map[int32]PartitionDetail
+
+// Sorted returns the partitions sorted by partition number.
+func (ds PartitionDetails) Sorted() []PartitionDetail {
+	s := make([]PartitionDetail, 0, len(ds))
+	for _, d := range ds {
+		s = append(s, d)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Partition < s[j].Partition })
+	return s
+}
+
+// TopicDetail is a topic from a metadata response.
+type TopicDetail struct {
+	Topic      string
+	ID         [16]byte // the topic ID, if the broker supports topic IDs (Kafka 2.8+)
+	IsInternal bool
+	Partitions PartitionDetails
+
+	// Err is the error for this topic, if any. Unknown topic or partition
+	// is returned for topics that do not exist.
+	Err error
+}
+
+// TopicDetails are topics from a metadata response, by topic name.
+type TopicDetails map[string]TopicDetail
+
+// Sorted returns the topics sorted by name.
+func (ds TopicDetails) Sorted() []TopicDetail {
+	s := make([]TopicDetail, 0, len(ds))
+	for _, d := range ds {
+		s = append(s, d)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Topic < s[j].Topic })
+	return s
+}
+
+// Names returns the sorted names of every topic.
+func (ds TopicDetails) Names() []string {
+	names := make([]string, 0, len(ds))
+	for topic := range ds {
+		names = append(names, topic)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Error returns the first topic error, in sorted topic order, if any.
+func (ds TopicDetails) Error() error {
+	for _, d := range ds.Sorted() {
+		if d.Err != nil {
+			return d.Err
+		}
+	}
+	return nil
+}
+
+// Metadata is the cluster metadata from a metadata response.
+type Metadata struct {
+	Cluster    string // the cluster ID, if the broker supports it
+	Controller int32
+	Brokers    BrokerDetails
+	Topics     TopicDetails
+}
+
+// Metadata issues a metadata request for the given topics, or for all topics
+// if no topics are given. This does not create topics, even if the broker
+// allows auto topic creation.
+func (cl *Client) Metadata(ctx context.Context, topics ...string) (Metadata, error) {
+	req := kmsg.NewPtrMetadataRequest()
+	for _, topic := range topics {
+		t := kmsg.NewMetadataRequestTopic()
+		t.Topic = kmsg.StringPtr(topic)
+		req.Topics = append(req.Topics, t)
+	}
+	if len(topics) == 0 {
+		req.Topics = nil // nil means all topics
+	}
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return Metadata{}, err
+	}
+	return newMetadata(resp), nil
+}
+
+func newMetadata(resp *kmsg.MetadataResponse) Metadata {
+	m := Metadata{
+		Controller: resp.ControllerID,
+		Topics:     make(TopicDetails, len(resp.Topics)),
+	}
+	if resp.ClusterID != nil {
+		m.Cluster = *resp.ClusterID
+	}
+	for _, b := range resp.Brokers {
+		m.Brokers = append(m.Brokers, BrokerDetail{
+			NodeID: b.NodeID,
+			Host:   b.Host,
+			Port:   b.Port,
+			Rack:   b.Rack,
+		})
+	}
+	sort.Slice(m.Brokers, func(i, j int) bool { return m.Brokers[i].NodeID < m.Brokers[j].NodeID })
+
+	for _, t := range resp.Topics {
+		td := TopicDetail{
+			Topic:      t.Topic,
+			ID:         t.TopicID,
+			IsInternal: t.IsInternal,
+			Partitions: make(PartitionDetails, len(t.Partitions)),
+			Err:        kerr.ErrorForCode(t.ErrorCode),
+		}
+		for _, p := range t.Partitions {
+			td.Partitions[p.Partition] = PartitionDetail{
+				Topic:           t.Topic,
+				Partition:       p.Partition,
+				Leader:          p.Leader,
+				LeaderEpoch:     p.LeaderEpoch,
+				Replicas:        p.Replicas,
+				ISR:             p.ISR,
+				OfflineReplicas: p.OfflineReplicas,
+				Err:             kerr.ErrorForCode(p.ErrorCode),
+			}
+		}
+		m.Topics[t.Topic] = td
+	}
+	return m
+}
+
+// ListTopics returns the details of the given topics, or of all topics if no
+// topics are given. Internal topics are only returned if requested by name;
+// see ListTopicsWithInternal.
+//
+// Topics that do not exist are returned with kerr.UnknownTopicOrPartition.
+func (cl *Client) ListTopics(ctx context.Context, topics ...string) (TopicDetails, error) {
+	m, err := cl.Metadata(ctx, topics...)
+	if err != nil {
+		return nil, err
+	}
+	if len(topics) == 0 {
+		for topic, d := range m.Topics {
+			if d.IsInternal {
+				delete(m.Topics, topic)
+			}
+		}
+	}
+	return m.Topics, nil
+}
+
+// ListTopicsWithInternal is the same as ListTopics, but also returns internal
+// topics when listing all topics.
+func (cl *Client) ListTopicsWithInternal(ctx context.Context, topics ...string) (TopicDetails, error) {
+	m, err := cl.Metadata(ctx, topics...)
+	if err != nil {
+		return nil, err
+	}
+	return m.Topics, nil
+}
+
+// CreateTopicResponse is the result of creating a topic.
+type CreateTopicResponse struct {
+	Topic             string
+	ID                [16]byte // the topic ID, if the broker supports topic IDs (Kafka 2.8+)
+	NumPartitions     int32    // the number of partitions the topic was created with, if known (Kafka 2.4+)
+	ReplicationFactor int16    // the replication factor the topic was created with, if known (Kafka 2.4+)
+
+	Err        error  // the error creating the topic, if any
+	ErrMessage string // a message describing the error, if Kafka returned one
+}
+
+// CreateTopicResponses are the results of creating topics, by topic name.
+type CreateTopicResponses map[string]CreateTopicResponse
+
+// Sorted returns the responses sorted by topic.
+func (rs CreateTopicResponses) Sorted() []CreateTopicResponse {
+	s := make([]CreateTopicResponse, 0, len(rs))
+	for _, r := range rs {
+		s = append(s, r)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Topic < s[j].Topic })
+	return s
+}
+
+// Error returns the first topic error, in sorted topic order, if any.
+func (rs CreateTopicResponses) Error() error {
+	for _, r := range rs.Sorted() {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+// CreateTopics creates topics with the given number of partitions,
+// replication factor, and topic configs. A partitions or replication factor
+// of -1 uses the broker's default (num.partitions and
+// default.replication.factor), which requires Kafka 2.4+.
+//
+// Configs can be nil. Topics that already exist are returned with
+// kerr.TopicAlreadyExists.
+func (cl *Client) CreateTopics(ctx context.Context, partitions int32, replicationFactor int16, configs map[string]*string, topics ...string) (CreateTopicResponses, error) {
+	return cl.createTopics(ctx, false, partitions, replicationFactor, configs, topics)
+}
+
+// ValidateCreateTopics validates that topics can be created as in
+// CreateTopics, without creating them.
+func (cl *Client) ValidateCreateTopics(ctx context.Context, partitions int32, replicationFactor int16, configs map[string]*string, topics ...string) (CreateTopicResponses, error) {
+	return cl.createTopics(ctx, true, partitions, replicationFactor, configs, topics)
+}
+
+func (cl *Client) createTopics(ctx context.Context, validate bool, partitions int32, replicationFactor int16, configs map[string]*string, topics []string) (CreateTopicResponses, error) {
+	if len(topics) == 0 {
+		return make(CreateTopicResponses), nil
+	}
+
+	req := kmsg.NewPtrCreateTopicsRequest()
+	req.TimeoutMillis = cl.timeoutMillis
+	req.ValidateOnly = validate
+	for _, topic := range topics {
+		t := kmsg.NewCreateTopicsRequestTopic()
+		t.Topic = topic
+		t.NumPartitions = partitions
+		t.ReplicationFactor = replicationFactor
+		for name, value := range configs {
+			c := kmsg.NewCreateTopicsRequestTopicConfig()
+			c.Name = name
+			c.Value = value
+			t.Configs = append(t.Configs, c)
+		}
+		req.Topics = append(req.Topics, t)
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := make(CreateTopicResponses, len(resp.Topics))
+	for _, t := range resp.Topics {
+		rs[t.Topic] = CreateTopicResponse{
+			Topic:             t.Topic,
+			ID:                t.TopicID,
+			NumPartitions:     t.NumPartitions,
+			ReplicationFactor: t.ReplicationFactor,
+			Err:               kerr.ErrorForCode(t.ErrorCode),
+			ErrMessage:        errMessage(t.ErrorCode, t.ErrorMessage),
+		}
+	}
+	return rs, nil
+}
+
+// DeleteTopicResponse is the result of deleting a topic.
+type DeleteTopicResponse struct {
+	Topic string
+	ID    [16]byte // the topic ID, if the broker supports topic IDs (Kafka 2.8+)
+
+	Err        error  // the error deleting the topic, if any
+	ErrMessage string // a message describing the error, if Kafka returned one
+}
+
+// DeleteTopicResponses are the results of deleting topics, by topic name.
+type DeleteTopicResponses map[string]DeleteTopicResponse
+
+// Sorted returns the responses sorted by topic.
+func (rs DeleteTopicResponses) Sorted() []DeleteTopicResponse {
+	s := make([]DeleteTopicResponse, 0, len(rs))
+	for _, r := range rs {
+		s = append(s, r)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Topic < s[j].Topic })
+	return s
+}
+
+// Error returns the first topic error, in sorted topic order, if any.
+func (rs DeleteTopicResponses) Error() error {
+	for _, r := range rs.Sorted() {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+// DeleteTopics deletes the given topics. Topics that do not exist are
+// returned with kerr.UnknownTopicOrPartition.
+func (cl *Client) DeleteTopics(ctx context.Context, topics ...string) (DeleteTopicResponses, error) {
+	if len(topics) == 0 {
+		return make(DeleteTopicResponses), nil
+	}
+
+	req := kmsg.NewPtrDeleteTopicsRequest()
+	req.TimeoutMillis = cl.timeoutMillis
+	req.TopicNames = topics // v0-v5
+	for _, topic := range topics {
+		t := kmsg.NewDeleteTopicsRequestTopic()
+		t.Topic = kmsg.StringPtr(topic)
+		req.Topics = append(req.Topics, t) // v6+
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := make(DeleteTopicResponses, len(resp.Topics))
+	for _, t := range resp.Topics {
+		if t.Topic == nil {
+			continue // we delete by name, so Kafka should always reply with names
+		}
+		rs[*t.Topic] = DeleteTopicResponse{
+			Topic:      *t.Topic,
+			ID:         t.TopicID,
+			Err:        kerr.ErrorForCode(t.ErrorCode),
+			ErrMessage: errMessage(t.ErrorCode, t.ErrorMessage),
+		}
+	}
+	return rs, nil
+}
+
+// CreatePartitionsResponse is the result of adding partitions to a topic.
+type CreatePartitionsResponse struct {
+	Topic string
+
+	Err        error  // the error adding partitions, if any
+	ErrMessage string // a message describing the error, if Kafka returned one
+}
+
+// CreatePartitionsResponses are the results of adding partitions, by topic
+// name.
+type CreatePartitionsResponses map[string]CreatePartitionsResponse
+
+// Sorted returns the responses sorted by topic.
+func (rs CreatePartitionsResponses) Sorted() []CreatePartitionsResponse {
+	s := make([]CreatePartitionsResponse, 0, len(rs))
+	for _, r := range rs {
+		s = append(s, r)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Topic < s[j].Topic })
+	return s
+}
+
+// Error returns the first topic error, in sorted topic order, if any.
+func (rs CreatePartitionsResponses) Error() error {
+	for _, r := range rs.Sorted() {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+// CreatePartitions adds add partitions to each of the given topics. The
+// current partition counts are loaded with a metadata request first; topics
+// whose metadata cannot be loaded are returned with the metadata error. Kafka
+// chooses the replicas of new partitions.
+func (cl *Client) CreatePartitions(ctx context.Context, add int, topics ...string) (CreatePartitionsResponses, error) {
+	if add <= 0 {
+		return nil, fmt.Errorf("invalid number of partitions to add %d, must be positive", add)
+	}
+	return cl.createPartitions(ctx, add, -1, topics)
+}
+
+// UpdatePartitions sets the partition count of each of the given topics to
+// set, which must be more than the current partition count, since partitions
+// cannot be removed. Kafka chooses the replicas of new partitions.
+func (cl *Client) UpdatePartitions(ctx context.Context, set int, topics ...string) (CreatePartitionsResponses, error) {
+	if set <= 0 {
+		return nil, fmt.Errorf("invalid partition count %d, must be positive", set)
+	}
+	return cl.createPartitions(ctx, -1, set, topics)
+}
+
+func (cl *Client) createPartitions(ctx context.Context, add, set int, topics []string) (CreatePartitionsResponses, error) {
+	rs := make(CreatePartitionsResponses)
+	if len(topics) == 0 {
+		return rs, nil
+	}
+
+	var details TopicDetails
+	if add > 0 {
+		var err error
+		if details, err = cl.ListTopics(ctx, topics...); err != nil {
+			return nil, err
+		}
+	}
+
+	req := kmsg.NewPtrCreatePartitionsRequest()
+	req.TimeoutMillis = cl.timeoutMillis
+	for _, topic := range topics {
+		count := set
+		if add > 0 {
+			d, exists := details[topic]
+			if !exists {
+				rs[topic] = CreatePartitionsResponse{Topic: topic, Err: kerr.UnknownTopicOrPartition}
+				continue
+			}
+			if d.Err != nil {
+				rs[topic] = CreatePartitionsResponse{Topic: topic, Err: d.Err}
+				continue
+			}
+			count = len(d.Partitions) + add
+		}
+		t := kmsg.NewCreatePartitionsRequestTopic()
+		t.Topic = topic
+		t.Count = int32(count)
+		req.Topics = append(req.Topics, t)
+	}
+	if len(req.Topics) == 0 {
+		return rs, nil
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range resp.Topics {
+		rs[t.Topic] = CreatePartitionsResponse{
+			Topic:      t.Topic,
+			Err:        kerr.ErrorForCode(t.ErrorCode),
+			ErrMessage: errMessage(t.ErrorCode, t.ErrorMessage),
+		}
+	}
+	return rs, nil
+}
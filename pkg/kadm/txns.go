@@ -0,0 +1,553 @@
+package kadm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// DescribedProducer is an active producer on a partition, from a describe
+// producers response.
+type DescribedProducer struct {
+	Topic     string
+	Partition int32
+	Leader    int32 // the partition leader that described this producer
+
+	ProducerID       int64
+	ProducerEpoch    int16
+	LastSequence     int32
+	LastTimestamp    int64 // the timestamp, in millis, of the producer's last write
+	CoordinatorEpoch int32 // the epoch of the transaction coordinator of the producer's last transaction marker
+
+	// CurrentTxnStartOffset is the offset of the first record of the
+	// producer's open transaction on this partition, or -1 if the producer
+	// has no open transaction.
+	CurrentTxnStartOffset int64
+}
+
+// DescribedProducersPartition is the active producers of a partition.
+type DescribedProducersPartition struct {
+	Topic     string
+	Partition int32
+	Leader    int32
+	Producers []DescribedProducer
+
+	Err        error  // the error describing producers, if any
+	ErrMessage string // a message describing the error, if Kafka returned one
+}
+
+// DescribedProducers are the active producers of partitions, by topic and
+// partition.
+type DescribedProducers map[string]map[int32]DescribedProducersPartition
+
+// Sorted returns the partitions sorted by topic and partition.
+func (ds DescribedProducers) Sorted() []DescribedProducersPartition {
+	var s []DescribedProducersPartition
+	for _, ps := range ds {
+		for _, d := range ps {
+			s = append(s, d)
+		}
+	}
+	sort.Slice(s, func(i, j int) bool {
+		return s[i].Topic < s[j].Topic || s[i].Topic == s[j].Topic && s[i].Partition < s[j].Partition
+	})
+	return s
+}
+
+// Error returns the first partition error, in sorted order, if any.
+func (ds DescribedProducers) Error() error {
+	for _, d := range ds.Sorted() {
+		if d.Err != nil {
+			return d.Err
+		}
+	}
+	return nil
+}
+
+func (ds DescribedProducers) add(d DescribedProducersPartition) {
+	ps := ds[d.Topic]
+	if ps == nil {
+		ps = make(map[int32]DescribedProducersPartition)
+		ds[d.Topic] = ps
+	}
+	ps[d.Partition] = d
+}
+
+// DescribeProducers describes the active producers of every partition of the
+// given topics, or of all topics if no topics are given, from each partition's
+// leader. This requires Kafka 3.0+.
+func (cl *Client) DescribeProducers(ctx context.Context, topics ...string) (DescribedProducers, error) {
+	tds, err := cl.ListTopics(ctx, topics...)
+	if err != nil {
+		return nil, err
+	}
+
+	ds := make(DescribedProducers)
+	req := kmsg.NewPtrDescribeProducersRequest()
+	for _, td := range tds.Sorted() {
+		if td.Err != nil {
+			ds.add(DescribedProducersPartition{Topic: td.Topic, Partition: -1, Leader: -1, Err: td.Err})
+			continue
+		}
+		t := kmsg.NewDescribeProducersRequestTopic()
+		t.Topic = td.Topic
+		for _, pd := range td.Partitions.Sorted() {
+			t.Partitions = append(t.Partitions, pd.Partition)
+		}
+		req.Topics = append(req.Topics, t)
+	}
+	if len(req.Topics) == 0 {
+		return ds, nil
+	}
+
+	for _, shard := range cl.cl.RequestSharded(ctx, req) {
+		if shard.Err != nil {
+			for _, t := range shard.Req.(*kmsg.DescribeProducersRequest).Topics {
+				for _, p := range t.Partitions {
+					ds.add(DescribedProducersPartition{Topic: t.Topic, Partition: p, Leader: -1, Err: shard.Err})
+				}
+			}
+			continue
+		}
+		leader := shard.Meta.NodeID
+		for _, t := range shard.Resp.(*kmsg.DescribeProducersResponse).Topics {
+			for _, p := range t.Partitions {
+				d := DescribedProducersPartition{
+					Topic:      t.Topic,
+					Partition:  p.Partition,
+					Leader:     leader,
+					Err:        kerr.ErrorForCode(p.ErrorCode),
+					ErrMessage: errMessage(p.ErrorCode, p.ErrorMessage),
+				}
+				for _, a := range p.ActiveProducers {
+					d.Producers = append(d.Producers, DescribedProducer{
+						Topic:                 t.Topic,
+						Partition:             p.Partition,
+						Leader:                leader,
+						ProducerID:            a.ProducerID,
+						ProducerEpoch:         int16(a.ProducerEpoch),
+						LastSequence:          a.LastSequence,
+						LastTimestamp:         a.LastTimestamp,
+						CoordinatorEpoch:      a.CoordinatorEpoch,
+						CurrentTxnStartOffset: a.CurrentTxnStartOffset,
+					})
+				}
+				ds.add(d)
+			}
+		}
+	}
+	return ds, nil
+}
+
+// ListedTransaction is a transaction from a list transactions response.
+type ListedTransaction struct {
+	Coordinator     int32 // the transaction coordinator that replied with this transaction
+	TransactionalID string
+	ProducerID      int64
+	State           string // such as Ongoing, PrepareCommit, CompleteAbort, or Empty
+}
+
+// ListedTransactions are listed transactions, by transactional ID.
+type ListedTransactions map[string]ListedTransaction
+
+// Sorted returns the transactions sorted by transactional ID.
+func (ls ListedTransactions) Sorted() []ListedTransaction {
+	s := make([]ListedTransaction, 0, len(ls))
+	for _, l := range ls {
+		s = append(s, l)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].TransactionalID < s[j].TransactionalID })
+	return s
+}
+
+// ListTransactions lists every transaction in the cluster, optionally
+// filtered to transactions of the given producer IDs and in the given
+// states. This requires Kafka 3.0+.
+//
+// Transactions are listed from every broker. If any broker cannot be listed,
+// this returns the transactions that could be listed along with the first
+// error.
+func (cl *Client) ListTransactions(ctx context.Context, producerIDs []int64, filterStates ...string) (ListedTransactions, error) {
+	req := kmsg.NewPtrListTransactionsRequest()
+	req.ProducerIDFilters = producerIDs
+	req.StateFilters = filterStates
+
+	ls := make(ListedTransactions)
+	var firstErr error
+	for _, shard := range cl.cl.RequestSharded(ctx, req) {
+		if shard.Err != nil {
+			if firstErr == nil {
+				firstErr = shard.Err
+			}
+			continue
+		}
+		resp := shard.Resp.(*kmsg.ListTransactionsResponse)
+		if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("broker %d: %w", shard.Meta.NodeID, err)
+			}
+			continue
+		}
+		for _, t := range resp.TransactionStates {
+			ls[t.TransactionalID] = ListedTransaction{
+				Coordinator:     shard.Meta.NodeID,
+				TransactionalID: t.TransactionalID,
+				ProducerID:      t.ProducerID,
+				State:           t.TransactionState,
+			}
+		}
+	}
+	return ls, firstErr
+}
+
+// DescribedTransaction is a transaction from a describe transactions
+// response.
+type DescribedTransaction struct {
+	Coordinator     int32 // the transaction's coordinator
+	TransactionalID string
+	State           string        // such as Ongoing, PrepareCommit, CompleteAbort, or Empty
+	Timeout         time.Duration // the transaction timeout the producer initialized with
+	StartTimestamp  int64         // the timestamp, in millis, that the current transaction started, or -1
+	ProducerID      int64
+	ProducerEpoch   int16
+
+	// Partitions are the partitions in the current transaction, by topic.
+	Partitions map[string][]int32
+
+	Err error // the error describing the transaction, if any
+}
+
+// HasPartition returns whether the transaction includes the given partition.
+func (d *DescribedTransaction) HasPartition(topic string, partition int32) bool {
+	for _, p := range d.Partitions[topic] {
+		if p == partition {
+			return true
+		}
+	}
+	return false
+}
+
+// DescribedTransactions are described transactions, by transactional ID.
+type DescribedTransactions map[string]DescribedTransaction
+
+// Sorted returns the transactions sorted by transactional ID.
+func (ds DescribedTransactions) Sorted() []DescribedTransaction {
+	s := make([]DescribedTransaction, 0, len(ds))
+	for _, d := range ds {
+		s = append(s, d)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].TransactionalID < s[j].TransactionalID })
+	return s
+}
+
+// Error returns the first transaction error, in sorted order, if any.
+func (ds DescribedTransactions) Error() error {
+	for _, d := range ds.Sorted() {
+		if d.Err != nil {
+			return d.Err
+		}
+	}
+	return nil
+}
+
+// DescribeTransactions describes the given transactional IDs from their
+// transaction coordinators. This requires Kafka 3.0+.
+func (cl *Client) DescribeTransactions(ctx context.Context, txnIDs ...string) (DescribedTransactions, error) {
+	ds := make(DescribedTransactions, len(txnIDs))
+	if len(txnIDs) == 0 {
+		return ds, nil
+	}
+
+	req := kmsg.NewPtrDescribeTransactionsRequest()
+	req.TransactionalIDs = txnIDs
+	for _, shard := range cl.cl.RequestSharded(ctx, req) {
+		if shard.Err != nil {
+			for _, txnID := range shard.Req.(*kmsg.DescribeTransactionsRequest).TransactionalIDs {
+				ds[txnID] = DescribedTransaction{TransactionalID: txnID, Coordinator: -1, Err: shard.Err}
+			}
+			continue
+		}
+		for _, t := range shard.Resp.(*kmsg.DescribeTransactionsResponse).TransactionStates {
+			d := DescribedTransaction{
+				Coordinator:     shard.Meta.NodeID,
+				TransactionalID: t.TransactionalID,
+				State:           t.State,
+				Timeout:         time.Duration(t.TimeoutMillis) * time.Millisecond,
+				StartTimestamp:  t.StartTimestamp,
+				ProducerID:      t.ProducerID,
+				ProducerEpoch:   t.ProducerEpoch,
+				Partitions:      make(map[string][]int32, len(t.Topics)),
+				Err:             kerr.ErrorForCode(t.ErrorCode),
+			}
+			for _, tt := range t.Topics {
+				d.Partitions[tt.Topic] = append(d.Partitions[tt.Topic], tt.Partitions...)
+			}
+			ds[t.TransactionalID] = d
+		}
+	}
+	for _, txnID := range txnIDs {
+		if _, exists := ds[txnID]; !exists {
+			ds[txnID] = DescribedTransaction{TransactionalID: txnID, Coordinator: -1, Err: errMissingResource}
+		}
+	}
+	return ds, nil
+}
+
+// HangingTransaction is an open transaction on a partition that is older than
+// the max transaction timeout and that its coordinator is no longer tracking.
+// Until the transaction is committed or aborted, the partition's last stable
+// offset cannot advance past StartOffset, and read committed consumers cannot
+// consume past it.
+type HangingTransaction struct {
+	Topic     string
+	Partition int32
+	Leader    int32 // the partition leader, which markers must be written to
+
+	ProducerID       int64
+	ProducerEpoch    int16
+	CoordinatorEpoch int32
+	StartOffset      int64 // the offset of the first record of the open transaction
+	LastTimestamp    int64 // the timestamp, in millis, of the producer's last write to the partition
+
+	// TransactionalID is the transactional ID that currently owns the
+	// producer ID, if any. TransactionState is that transaction's state.
+	// A transaction that is hanging because its coordinator lost track
+	// of it usually has no transactional ID, or one that has since moved
+	// on to a new producer ID or a different set of partitions.
+	TransactionalID  string
+	TransactionState string
+}
+
+// HangingTransactions are hanging transactions, sorted by topic, partition,
+// and producer ID.
+type HangingTransactions []HangingTransaction
+
+// FoundHangingTransactions is the result of FindHangingTransactions.
+type FoundHangingTransactions struct {
+	// Hanging are the hanging transactions on every partition that could
+	// be searched.
+	Hanging HangingTransactions
+
+	// Unsearched are the partitions whose producers could not be
+	// described, sorted by topic and partition. These partitions were not
+	// searched and may still have hanging transactions. A partition of -1
+	// is for a topic level error.
+	Unsearched []DescribedProducersPartition
+}
+
+// Error returns the first error of any unsearched partition, if any.
+func (f FoundHangingTransactions) Error() error {
+	for _, d := range f.Unsearched {
+		if d.Err != nil {
+			return d.Err
+		}
+	}
+	return nil
+}
+
+// FindHangingTransactions finds open transactions on every partition of the
+// given topics, or of all topics if no topics are given, that are hanging.
+// This is similar to Kafka's kafka-transactions.sh find-hanging command, and
+// requires Kafka 3.0+.
+//
+// Active producers with an open transaction are found with DescribeProducers,
+// and the transactional ID owning each producer is found with
+// ListTransactions and DescribeTransactions. A transaction hangs if it is
+// older than maxTimeout, which should be the cluster's
+// transaction.max.timeout.ms (15 minutes by default), and if its coordinator
+// is not tracking it as an ongoing transaction on the partition. A tracked
+// transaction is left for its coordinator to time out and abort.
+//
+// Partitions whose producers could not be described are skipped and returned
+// in Unsearched, rather than failing the entire search.
+func (cl *Client) FindHangingTransactions(ctx context.Context, maxTimeout time.Duration, topics ...string) (FoundHangingTransactions, error) {
+	var found FoundHangingTransactions
+	producers, err := cl.DescribeProducers(ctx, topics...)
+	if err != nil {
+		return found, err
+	}
+	searched := make(DescribedProducers, len(producers))
+	for _, d := range producers.Sorted() {
+		if d.Err != nil {
+			found.Unsearched = append(found.Unsearched, d)
+			continue
+		}
+		searched.add(d)
+	}
+
+	seen := make(map[int64]bool)
+	var pids []int64
+	for _, d := range searched.Sorted() {
+		for _, p := range d.Producers {
+			if p.CurrentTxnStartOffset >= 0 && !seen[p.ProducerID] {
+				seen[p.ProducerID] = true
+				pids = append(pids, p.ProducerID)
+			}
+		}
+	}
+	if len(pids) == 0 {
+		return found, nil
+	}
+
+	listed, err := cl.ListTransactions(ctx, pids)
+	if err != nil {
+		return found, err
+	}
+	txnIDs := make([]string, 0, len(listed))
+	for txnID := range listed {
+		txnIDs = append(txnIDs, txnID)
+	}
+	sort.Strings(txnIDs)
+	described, err := cl.DescribeTransactions(ctx, txnIDs...)
+	if err != nil {
+		return found, err
+	}
+
+	found.Hanging = findHanging(searched, listed, described, time.Now().UnixNano()/1e6, maxTimeout)
+	return found, nil
+}
+
+func findHanging(producers DescribedProducers, listed ListedTransactions, described DescribedTransactions, nowMillis int64, maxTimeout time.Duration) HangingTransactions {
+	owners := make(map[int64]string, len(listed))
+	for txnID, l := range listed {
+		owners[l.ProducerID] = txnID
+	}
+
+	var hung HangingTransactions
+	for _, d := range producers.Sorted() {
+		for _, p := range d.Producers {
+			if p.CurrentTxnStartOffset < 0 || nowMillis-p.LastTimestamp <= maxTimeout.Milliseconds() {
+				continue
+			}
+			h := HangingTransaction{
+				Topic:            p.Topic,
+				Partition:        p.Partition,
+				Leader:           p.Leader,
+				ProducerID:       p.ProducerID,
+				ProducerEpoch:    p.ProducerEpoch,
+				CoordinatorEpoch: p.CoordinatorEpoch,
+				StartOffset:      p.CurrentTxnStartOffset,
+				LastTimestamp:    p.LastTimestamp,
+			}
+			if txnID, exists := owners[p.ProducerID]; exists {
+				h.TransactionalID = txnID
+				h.TransactionState = listed[txnID].State
+				if t, exists := described[txnID]; exists && t.Err == nil {
+					h.TransactionState = t.State
+					if t.ProducerID == p.ProducerID && t.State == "Ongoing" && t.HasPartition(p.Topic, p.Partition) {
+						continue // the coordinator will time out and abort this itself
+					}
+				}
+			}
+			hung = append(hung, h)
+		}
+	}
+	sort.SliceStable(hung, func(i, j int) bool {
+		l, r := &hung[i], &hung[j]
+		switch {
+		case l.Topic != r.Topic:
+			return l.Topic < r.Topic
+		case l.Partition != r.Partition:
+			return l.Partition < r.Partition
+		default:
+			return l.ProducerID < r.ProducerID
+		}
+	})
+	return hung
+}
+
+// AbortTransactionResponse is the result of aborting a hanging transaction on
+// a partition.
+type AbortTransactionResponse struct {
+	HangingTransaction
+	Err error // the error aborting the transaction, if any
+}
+
+// AbortTransactionResponses are the results of aborting hanging transactions,
+// in the order the transactions were given.
+type AbortTransactionResponses []AbortTransactionResponse
+
+// Error returns the first abort error, if any.
+func (rs AbortTransactionResponses) Error() error {
+	for _, r := range rs {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+// AbortHangingTransactions aborts the given hanging transactions by writing
+// abort markers directly to each partition's leader with WriteTxnMarkers,
+// similar to Kafka's kafka-transactions.sh abort command. This requires
+// cluster action permission.
+//
+// Aborting a transaction that is not actually hanging aborts the producer's
+// in flight transaction on the partition, so transactions should be found
+// with FindHangingTransactions immediately before aborting them. Markers
+// written with a stale producer or coordinator epoch are rejected by the
+// leader.
+func (cl *Client) AbortHangingTransactions(ctx context.Context, hung ...HangingTransaction) (AbortTransactionResponses, error) {
+	rs := make(AbortTransactionResponses, 0, len(hung))
+	byLeader := make(map[int32]*kmsg.WriteTxnMarkersRequest)
+	var leaders []int32
+	for _, h := range hung {
+		req := byLeader[h.Leader]
+		if req == nil {
+			req = kmsg.NewPtrWriteTxnMarkersRequest()
+			byLeader[h.Leader] = req
+			leaders = append(leaders, h.Leader)
+		}
+		m := kmsg.NewWriteTxnMarkersRequestMarker()
+		m.ProducerID = h.ProducerID
+		m.ProducerEpoch = h.ProducerEpoch
+		m.Committed = false
+		m.CoordinatorEpoch = h.CoordinatorEpoch
+		t := kmsg.NewWriteTxnMarkersRequestMarkerTopic()
+		t.Topic = h.Topic
+		t.Partitions = []int32{h.Partition}
+		m.Topics = append(m.Topics, t)
+		req.Markers = append(req.Markers, m)
+	}
+
+	type key struct {
+		topic     string
+		partition int32
+		pid       int64
+	}
+	errs := make(map[key]error, len(hung))
+	for _, leader := range leaders {
+		req := byLeader[leader]
+		kresp, err := cl.cl.Broker(int(leader)).RetriableRequest(ctx, req)
+		if err != nil {
+			for _, m := range req.Markers {
+				for _, t := range m.Topics {
+					for _, p := range t.Partitions {
+						errs[key{t.Topic, p, m.ProducerID}] = err
+					}
+				}
+			}
+			continue
+		}
+		for _, m := range kresp.(*kmsg.WriteTxnMarkersResponse).Markers {
+			for _, t := range m.Topics {
+				for _, p := range t.Partitions {
+					errs[key{t.Topic, p.Partition, m.ProducerID}] = kerr.ErrorForCode(p.ErrorCode)
+				}
+			}
+		}
+	}
+
+	for _, h := range hung {
+		err, exists := errs[key{h.Topic, h.Partition, h.ProducerID}]
+		if !exists {
+			err = errMissingResource
+		}
+		rs = append(rs, AbortTransactionResponse{HangingTransaction: h, Err: err})
+	}
+	return rs, nil
+}
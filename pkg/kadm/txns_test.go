@@ -0,0 +1,88 @@
+package kadm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFindHanging(t *testing.T) {
+	const now = int64(1000000000)
+	producer := func(partition int32, pid int64, lastTs, start int64) DescribedProducer {
+		return DescribedProducer{
+			Topic:                 "foo",
+			Partition:             partition,
+			Leader:                partition + 1,
+			ProducerID:            pid,
+			ProducerEpoch:         3,
+			LastTimestamp:         lastTs,
+			CurrentTxnStartOffset: start,
+		}
+	}
+	producers := make(DescribedProducers)
+	producers.add(DescribedProducersPartition{Topic: "foo", Partition: 0, Producers: []DescribedProducer{
+		producer(0, 1, now-60000, 10),    // untracked, older than max timeout: hanging
+		producer(0, 2, now-60000, -1),    // no open transaction
+		producer(0, 3, now-1000, 20),     // untracked, but recent
+		producer(0, 4, now-20000, 30),    // tracked, older than its own timeout, but within max timeout
+		producer(0, 5, now-20000, 40),    // tracked, within its own timeout
+		producer(0, 6, now-60000, 50),    // owned, but the coordinator does not have this partition: hanging
+		producer(0, 7, now-60000, 60),    // owned by a txn that moved on to a new producer ID: hanging
+		producer(0, 8, now-20000, -1),    // no open transaction
+		producer(0, 9, now-30001, 70),    // tracked, older than max timeout, left to the coordinator
+		producer(0, 10, now-10000, 80),   // tracked, within its own 30s timeout
+		producer(0, 11, now-29999, 90),   // untracked, within max timeout
+		producer(0, 12, now-30001, 100),  // untracked, older than max timeout: hanging
+		producer(0, 13, now-100000, 110), // owned by a completed transaction: hanging
+	}})
+
+	listed := ListedTransactions{
+		"t4":  {TransactionalID: "t4", ProducerID: 4, State: "Ongoing"},
+		"t5":  {TransactionalID: "t5", ProducerID: 5, State: "Ongoing"},
+		"t6":  {TransactionalID: "t6", ProducerID: 6, State: "Ongoing"},
+		"t7":  {TransactionalID: "t7", ProducerID: 7, State: "Ongoing"},
+		"t9":  {TransactionalID: "t9", ProducerID: 9, State: "Ongoing"},
+		"t10": {TransactionalID: "t10", ProducerID: 10, State: "Ongoing"},
+		"t13": {TransactionalID: "t13", ProducerID: 13, State: "CompleteAbort"},
+	}
+	ongoing := func(txnID string, pid int64, timeout time.Duration, partitions ...int32) DescribedTransaction {
+		return DescribedTransaction{
+			TransactionalID: txnID,
+			State:           "Ongoing",
+			Timeout:         timeout,
+			ProducerID:      pid,
+			Partitions:      map[string][]int32{"foo": partitions},
+		}
+	}
+	described := DescribedTransactions{
+		"t4":  ongoing("t4", 4, 10*time.Second, 0),
+		"t5":  ongoing("t5", 5, time.Minute, 0),
+		"t6":  ongoing("t6", 6, time.Minute, 1),
+		"t7":  ongoing("t7", 70, time.Minute, 0),
+		"t9":  ongoing("t9", 9, 30*time.Second, 0),
+		"t10": ongoing("t10", 10, 30*time.Second, 0),
+		"t13": {TransactionalID: "t13", State: "CompleteAbort", ProducerID: 13},
+	}
+
+	hung := findHanging(producers, listed, described, now, 30*time.Second)
+	exp := []int64{1, 6, 7, 12, 13}
+	if len(hung) != len(exp) {
+		t.Fatalf("got %d hanging transactions %v, expected producers %v", len(hung), hung, exp)
+	}
+	for i, h := range hung {
+		if h.ProducerID != exp[i] {
+			t.Errorf("#%d: got producer %d, expected %d", i, h.ProducerID, exp[i])
+		}
+		if h.Leader != 1 || h.ProducerEpoch != 3 {
+			t.Errorf("#%d: got leader %d epoch %d, expected leader 1 epoch 3", i, h.Leader, h.ProducerEpoch)
+		}
+	}
+	if h := hung[1]; h.TransactionalID != "t6" || h.TransactionState != "Ongoing" {
+		t.Errorf("got %+v, expected t6 Ongoing", h)
+	}
+	if h := hung[0]; h.TransactionalID != "" || h.StartOffset != 10 {
+		t.Errorf("got %+v, expected no transactional ID and start offset 10", h)
+	}
+	if h := hung[4]; h.TransactionalID != "t13" || h.TransactionState != "CompleteAbort" {
+		t.Errorf("got %+v, expected t13 CompleteAbort", h)
+	}
+}
@@ -0,0 +1,401 @@
+package kgo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Headers added to every chunk of a record that was split with
+// ProducerChunkBytes. The id is unique per split record; the index is the
+// chunk's position in the split record, from zero; the count is the number of
+// chunks; and the size is the length of the original record's value.
+//
+// Only the first chunk contains the original record's headers.
+const (
+	ChunkHeaderID    = "kgo-chunk-id"
+	ChunkHeaderIndex = "kgo-chunk-index"
+	ChunkHeaderCount = "kgo-chunk-count"
+	ChunkHeaderSize  = "kgo-chunk-size"
+)
+
+/////////////
+// PRODUCE //
+/////////////
+
+// chunkedProduce tracks the chunks of a split record, pinning every chunk to
+// the partition of the first and calling the record's promise once every
+// chunk is done.
+type chunkedProduce struct {
+	// partition, accessed under the topic's partsMu, is the partition
+	// the first chunk was partitioned to, or -1.
+	partition int32
+
+	r       *Record
+	promise func(*Record, error)
+
+	mu        sync.Mutex
+	remaining int
+	err       error
+	last      *Record
+}
+
+// produceChunks splits a record into chunks and produces each one, calling
+// the promise once all chunks are finished with the first error encountered.
+//
+// The original record's Partition, Offset, LeaderEpoch, ProducerID,
+// ProducerEpoch, and Timestamp are set from the final chunk.
+func (cl *Client) produceChunks(ctx context.Context, r *Record, promise func(*Record, error)) {
+	size := int(cl.cfg.produceChunkBytes)
+	count := (len(r.Value) + size - 1) / size
+
+	var idRaw [16]byte
+	if _, err := rand.Read(idRaw[:]); err != nil {
+		go promise(r, err)
+		return
+	}
+	var (
+		id     = []byte(hex.EncodeToString(idRaw[:]))
+		countH = []byte(strconv.Itoa(count))
+		sizeH  = []byte(strconv.Itoa(len(r.Value)))
+	)
+
+	c := &chunkedProduce{
+		partition: -1,
+		r:         r,
+		promise:   promise,
+		remaining: count,
+	}
+
+	for i := 0; i < count; i++ {
+		value := r.Value[i*size:]
+		if len(value) > size {
+			value = value[:size]
+		}
+		var headers []RecordHeader
+		if i == 0 {
+			headers = append(headers, r.Headers...)
+		}
+		headers = append(headers,
+			RecordHeader{Key: ChunkHeaderID, Value: id},
+			RecordHeader{Key: ChunkHeaderIndex, Value: []byte(strconv.Itoa(i))},
+			RecordHeader{Key: ChunkHeaderCount, Value: countH},
+			RecordHeader{Key: ChunkHeaderSize, Value: sizeH},
+		)
+		cl.produce(promisedRec{ctx, c.finishChunk, &Record{
+			Key:     r.Key,
+			Value:   value,
+			Headers: headers,
+			Topic:   r.Topic,
+		}, c, 0})
+	}
+}
+
+func (c *chunkedProduce) finishChunk(chunk *Record, err error) {
+	c.mu.Lock()
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	if err == nil && (c.last == nil || chunk.Offset > c.last.Offset) {
+		c.last = chunk
+	}
+	c.remaining--
+	done := c.remaining == 0
+	c.mu.Unlock()
+
+	if !done {
+		return
+	}
+	if c.last != nil {
+		c.r.Partition = c.last.Partition
+		c.r.Offset = c.last.Offset
+		c.r.LeaderEpoch = c.last.LeaderEpoch
+		c.r.ProducerID = c.last.ProducerID
+		c.r.ProducerEpoch = c.last.ProducerEpoch
+		c.r.Timestamp = c.last.Timestamp
+	}
+	c.promise(c.r, c.err)
+}
+
+// failChunk records a chunk's error before its promise is called
+// asynchronously, so that later chunks of the split record see the failure
+// immediately. This is a no-op if the record is not a chunk.
+func (pr promisedRec) failChunk(err error) {
+	if c := pr.chunked; c != nil {
+		c.mu.Lock()
+		if c.err == nil {
+			c.err = err
+		}
+		c.mu.Unlock()
+	}
+}
+
+// failed returns the first error of any chunk. This is called under the
+// topic's partsMu when no chunk has been partitioned, in which case any error
+// is from a chunk that failed before being partitioned.
+func (c *chunkedProduce) failed() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+/////////////
+// CONSUME //
+/////////////
+
+// chunkReassembler buffers chunks for a single partition until every chunk of
+// a split record has been consumed.
+type chunkReassembler struct {
+	cl *Client
+
+	mu      sync.Mutex
+	pending map[string]*chunkSet // chunk id => set
+	skip    map[string]time.Time // chunk ids we dropped, and when to forget them
+}
+
+// chunkSet is a partially consumed split record.
+type chunkSet struct {
+	first   EpochOffset // the offset of the earliest chunk we consumed
+	started time.Time
+	chunks  []*Record
+	have    int
+	bytes   int64
+}
+
+// newChunkReassembler returns a reassembler for a cursor, or nil if the
+// client does not reassemble chunks.
+func newChunkReassembler(cl *Client) *chunkReassembler {
+	if cl.cfg.chunkMaxBytes <= 0 {
+		return nil
+	}
+	return &chunkReassembler{cl: cl}
+}
+
+// chunkInfo returns the chunk id, index, and count of a record, and whether
+// the record is a valid chunk.
+func chunkInfo(r *Record) (id string, index, count, size int, ok bool) {
+	var found int
+	for _, h := range r.Headers {
+		var err error
+		switch h.Key {
+		case ChunkHeaderID:
+			id = string(h.Value)
+		case ChunkHeaderIndex:
+			index, err = strconv.Atoi(string(h.Value))
+		case ChunkHeaderCount:
+			count, err = strconv.Atoi(string(h.Value))
+		case ChunkHeaderSize:
+			size, err = strconv.Atoi(string(h.Value))
+		default:
+			continue
+		}
+		if err != nil {
+			return "", 0, 0, 0, false
+		}
+		found++
+	}
+	return id, index, count, size, found == 4 && count > 0 && index >= 0 && index < count && size >= 0
+}
+
+// add adds a consumed record, returning the record to keep. Records that are
+// not chunks are returned as is. If the chunk completes a split record, the
+// reassembled record is returned, otherwise this returns nil.
+func (c *chunkReassembler) add(r *Record) *Record {
+	id, index, count, size, ok := chunkInfo(r)
+	if !ok {
+		return r
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.expire(now)
+
+	if _, skipped := c.skip[id]; skipped {
+		return nil
+	}
+
+	set := c.pending[id]
+	if set == nil {
+		if int64(size) > c.cl.cfg.chunkMaxBytes {
+			c.drop(id, nil, now, "chunked record is larger than the max reassembly bytes", r)
+			return nil
+		}
+		if c.pending == nil {
+			c.pending = make(map[string]*chunkSet)
+		}
+		set = &chunkSet{
+			first:   EpochOffset{r.LeaderEpoch, r.Offset},
+			started: now,
+			chunks:  make([]*Record, count),
+		}
+		c.pending[id] = set
+	}
+	if len(set.chunks) != count {
+		c.drop(id, set, now, "chunk count does not match earlier chunks", r)
+		return nil
+	}
+
+	if set.chunks[index] == nil {
+		n := int64(len(r.Value))
+		if atomic.AddInt64(&c.cl.consumer.chunkBytes, n) > c.cl.cfg.chunkMaxBytes {
+			atomic.AddInt64(&c.cl.consumer.chunkBytes, -n)
+			c.drop(id, set, now, "buffered chunks exceed the max reassembly bytes", r)
+			return nil
+		}
+		set.bytes += n
+		set.have++
+	}
+	set.chunks[index] = r // we could be re-consuming a chunk after a seek
+	if r.Offset < set.first.Offset {
+		set.first = EpochOffset{r.LeaderEpoch, r.Offset}
+	}
+	if set.have < count {
+		return nil
+	}
+
+	delete(c.pending, id)
+	atomic.AddInt64(&c.cl.consumer.chunkBytes, -set.bytes)
+
+	value := make([]byte, 0, size)
+	for _, chunk := range set.chunks {
+		value = append(value, chunk.Value...)
+	}
+	first := set.chunks[0]
+	var headers []RecordHeader
+	for _, h := range first.Headers {
+		switch h.Key {
+		case ChunkHeaderID, ChunkHeaderIndex, ChunkHeaderCount, ChunkHeaderSize:
+		default:
+			headers = append(headers, h)
+		}
+	}
+
+	// The reassembled record takes the position of the final chunk, so
+	// that committing the record commits every chunk.
+	return &Record{
+		Key:           r.Key,
+		Value:         value,
+		Headers:       headers,
+		Timestamp:     first.Timestamp,
+		Topic:         r.Topic,
+		Partition:     r.Partition,
+		Attrs:         r.Attrs,
+		ProducerEpoch: r.ProducerEpoch,
+		ProducerID:    r.ProducerID,
+		LeaderEpoch:   r.LeaderEpoch,
+		Offset:        r.Offset,
+	}
+}
+
+// drop, called under the mu, discards a chunk set and skips any further
+// chunks for it until the reassembly timeout passes.
+func (c *chunkReassembler) drop(id string, set *chunkSet, now time.Time, why string, r *Record) {
+	c.cl.cfg.logger.Log(LogLevelWarn, "dropping chunked record",
+		"why", why,
+		"topic", r.Topic,
+		"partition", r.Partition,
+		"offset", r.Offset,
+		"chunk_id", id,
+	)
+	if set != nil {
+		atomic.AddInt64(&c.cl.consumer.chunkBytes, -set.bytes)
+		delete(c.pending, id)
+	}
+	if c.skip == nil {
+		c.skip = make(map[string]time.Time)
+	}
+	c.skip[id] = now.Add(c.cl.cfg.chunkTimeout)
+}
+
+// expire, called under the mu, discards chunk sets that have not completed
+// within the reassembly timeout.
+func (c *chunkReassembler) expire(now time.Time) {
+	for id, set := range c.pending {
+		if now.Sub(set.started) < c.cl.cfg.chunkTimeout {
+			continue
+		}
+		c.cl.cfg.logger.Log(LogLevelWarn, "dropping incomplete chunked record after reassembly timeout",
+			"topic", set.firstChunk().Topic,
+			"partition", set.firstChunk().Partition,
+			"offset", set.first.Offset,
+			"chunk_id", id,
+			"have", set.have,
+			"count", len(set.chunks),
+		)
+		atomic.AddInt64(&c.cl.consumer.chunkBytes, -set.bytes)
+		delete(c.pending, id)
+	}
+	for id, until := range c.skip {
+		if now.After(until) {
+			delete(c.skip, id)
+		}
+	}
+}
+
+func (s *chunkSet) firstChunk() *Record {
+	for _, chunk := range s.chunks {
+		if chunk != nil {
+			return chunk
+		}
+	}
+	return nil // unreachable; sets are created with a chunk
+}
+
+// hold returns the offset of the earliest chunk in any incomplete chunk set,
+// and whether there is an incomplete set at all. Commits must not advance
+// past this offset, otherwise restarting would lose the consumed chunks.
+func (c *chunkReassembler) hold() (EpochOffset, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expire(time.Now())
+
+	var (
+		hold EpochOffset
+		held bool
+	)
+	for _, set := range c.pending {
+		if !held || set.first.Offset < hold.Offset {
+			hold = set.first
+			held = true
+		}
+	}
+	return hold, held
+}
+
+// reassembleFetch, called once a fetch response is kept to be buffered,
+// replaces chunks in the fetch with their reassembled records and tracks any
+// incomplete chunked record per partition.
+//
+// We do not reassemble while handling a response: a response can be
+// discarded, in which case its chunks are fetched again, and a reassembler
+// that already saw the final chunk of a split record would lose the record.
+func reassembleFetch(f *Fetch, used usedOffsets) {
+	for i := range f.Topics {
+		t := &f.Topics[i]
+		for j := range t.Partitions {
+			p := &t.Partitions[j]
+			o := used[t.Topic][p.Partition]
+			if o == nil || o.from.chunks == nil || o.offset == o.from.offset {
+				continue // not reassembling, or nothing was consumed
+			}
+			kept := p.Records[:0]
+			for _, r := range p.Records {
+				if r = o.from.chunks.add(r); r != nil {
+					kept = append(kept, r)
+				}
+			}
+			p.Records = kept
+
+			// This also ensures a fetch of only incomplete chunks
+			// is buffered, so that our cursor advances past them.
+			p.chunkHold, p.chunkHeld = o.from.chunks.hold()
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package kgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestChunkReassembly(t *testing.T) {
+	cl, _ := NewClient(ConsumeChunkedRecords(1<<20, time.Minute))
+	defer cl.Close()
+
+	value := bytes.Repeat([]byte("abcdefg"), 10)
+	chunks := func(id string, offset int64, size int) []*Record {
+		var rs []*Record
+		count := (len(value) + size - 1) / size
+		for i := 0; i < count; i++ {
+			v := value[i*size:]
+			if len(v) > size {
+				v = v[:size]
+			}
+			var headers []RecordHeader
+			if i == 0 {
+				headers = append(headers, RecordHeader{Key: "user", Value: []byte("h")})
+			}
+			headers = append(headers,
+				RecordHeader{Key: ChunkHeaderID, Value: []byte(id)},
+				RecordHeader{Key: ChunkHeaderIndex, Value: []byte(strconv.Itoa(i))},
+				RecordHeader{Key: ChunkHeaderCount, Value: []byte(strconv.Itoa(count))},
+				RecordHeader{Key: ChunkHeaderSize, Value: []byte(strconv.Itoa(len(value)))},
+			)
+			rs = append(rs, &Record{Key: []byte("k"), Value: v, Headers: headers, Offset: offset + int64(i)})
+		}
+		return rs
+	}
+
+	c := newChunkReassembler(cl)
+
+	// Interleave two split records: a at offsets 0-3 and b at 10-13,
+	// consuming a0 b0 a1 b1 ... in order.
+	a, b := chunks("a", 0, 20), chunks("b", 10, 20)
+	var got []*Record
+	for i := range a {
+		for _, r := range []*Record{a[i], b[i]} {
+			if kept := c.add(r); kept != nil {
+				got = append(got, kept)
+			}
+		}
+		hold, held := c.hold()
+		switch {
+		case i < len(a)-1:
+			if !held || hold.Offset != 0 {
+				t.Errorf("#%d: got hold %v %v, expected held at 0", i, hold, held)
+			}
+		default:
+			if held {
+				t.Errorf("got hold %v after completing every chunk", hold)
+			}
+		}
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("got %d reassembled records, expected 2", len(got))
+	}
+	for i, r := range got {
+		if !bytes.Equal(r.Value, value) {
+			t.Errorf("#%d: got value %q != exp %q", i, r.Value, value)
+		}
+		if len(r.Headers) != 1 || r.Headers[0].Key != "user" {
+			t.Errorf("#%d: got headers %v, expected only the user header", i, r.Headers)
+		}
+	}
+	if got[0].Offset != 3 || got[1].Offset != 13 {
+		t.Errorf("got offsets %d and %d, expected the final chunk offsets 3 and 13", got[0].Offset, got[1].Offset)
+	}
+	if cl.consumer.chunkBytes != 0 {
+		t.Errorf("got %d chunk bytes buffered after reassembly, expected 0", cl.consumer.chunkBytes)
+	}
+
+	// Non-chunks are returned as is.
+	plain := &Record{Value: []byte("v")}
+	if kept := c.add(plain); kept != plain {
+		t.Error("non-chunk record was not returned as is")
+	}
+}
+
+func TestChunkReassemblyBounds(t *testing.T) {
+	cl, _ := NewClient(ConsumeChunkedRecords(50, 10*time.Millisecond))
+	defer cl.Close()
+
+	c := newChunkReassembler(cl)
+	chunk := func(id string, index, count, size int, offset int64) *Record {
+		return &Record{
+			Value:  make([]byte, 20),
+			Offset: offset,
+			Headers: []RecordHeader{
+				{Key: ChunkHeaderID, Value: []byte(id)},
+				{Key: ChunkHeaderIndex, Value: []byte(strconv.Itoa(index))},
+				{Key: ChunkHeaderCount, Value: []byte(strconv.Itoa(count))},
+				{Key: ChunkHeaderSize, Value: []byte(strconv.Itoa(size))},
+			},
+		}
+	}
+
+	// Too large from the start.
+	c.add(chunk("big", 0, 4, 80, 0))
+	if _, held := c.hold(); held {
+		t.Error("oversized record was held")
+	}
+	if kept := c.add(chunk("big", 1, 4, 80, 1)); kept != nil {
+		t.Error("later chunk of dropped record was kept")
+	}
+
+	// Two records that together exceed the buffer bound: the second is
+	// dropped once it would push us past our max.
+	c.add(chunk("x", 0, 2, 40, 2))
+	c.add(chunk("y", 0, 3, 60, 3))
+	c.add(chunk("y", 1, 3, 60, 4))
+	if hold, held := c.hold(); !held || hold.Offset != 2 {
+		t.Errorf("got hold %v %v, expected held at 2", hold, held)
+	}
+	if _, exists := c.pending["y"]; exists {
+		t.Error("record exceeding the buffer bound was not dropped")
+	}
+	if kept := c.add(chunk("x", 1, 2, 40, 5)); kept == nil || len(kept.Value) != 40 {
+		t.Error("record within bounds was not reassembled")
+	}
+
+	// Incomplete records expire.
+	c.add(chunk("z", 0, 2, 40, 6))
+	time.Sleep(20 * time.Millisecond)
+	if _, held := c.hold(); held {
+		t.Error("incomplete record did not expire")
+	}
+	if cl.consumer.chunkBytes != 0 {
+		t.Errorf("got %d chunk bytes buffered after expiring, expected 0", cl.consumer.chunkBytes)
+	}
+}
+
+func TestChunkReassemblyOnlyOnKeep(t *testing.T) {
+	cl, _ := NewClient(ConsumeChunkedRecords(1<<20, time.Minute))
+	defer cl.Close()
+
+	chunk := func(index int, offset int64) *Record {
+		return &Record{
+			Topic:  "t",
+			Value:  []byte{byte(index)},
+			Offset: offset,
+			Headers: []RecordHeader{
+				{Key: ChunkHeaderID, Value: []byte("id")},
+				{Key: ChunkHeaderIndex, Value: []byte(strconv.Itoa(index))},
+				{Key: ChunkHeaderCount, Value: []byte("2")},
+				{Key: ChunkHeaderSize, Value: []byte("2")},
+			},
+		}
+	}
+	c := &cursor{topic: "t", chunks: newChunkReassembler(cl)}
+	fetch := func(offset int64, rs ...*Record) Fetch {
+		used := usedOffsets{"t": {0: c.use()}}
+		used["t"][0].offset = offset
+		f := Fetch{Topics: []FetchTopic{{Topic: "t", Partitions: []FetchPartition{{Records: rs}}}}}
+		reassembleFetch(&f, used)
+		c.setOffset(cursorOffset{offset: offset})
+		return f
+	}
+
+	// The first chunk is held, and the fetch must still be buffered so
+	// that our cursor advances.
+	f := fetch(1, chunk(0, 0))
+	if p := f.Topics[0].Partitions[0]; len(p.Records) != 0 || !p.chunkHeld || p.chunkHold.Offset != 0 {
+		t.Fatalf("got %d records, held %v at %d; expected nothing kept, held at 0", len(p.Records), p.chunkHeld, p.chunkHold.Offset)
+	}
+	if !f.hasErrorsOrRecords() {
+		t.Error("fetch of only an incomplete chunk would not be buffered")
+	}
+
+	// A fetch with the final chunk that is discarded is never passed to
+	// reassembleFetch; the reassembler must still be waiting for the
+	// final chunk when it is fetched again and kept.
+	if _, held := c.chunks.hold(); !held {
+		t.Fatal("reassembler lost the incomplete record")
+	}
+	f = fetch(2, chunk(1, 1))
+	p := f.Topics[0].Partitions[0]
+	if len(p.Records) != 1 || !bytes.Equal(p.Records[0].Value, []byte{0, 1}) || p.Records[0].Offset != 1 {
+		t.Fatalf("got records %v, expected the reassembled record at offset 1", p.Records)
+	}
+	if p.chunkHeld {
+		t.Error("complete record is still held")
+	}
+}
+
+func TestChunkFailedBeforePartitioning(t *testing.T) {
+	cl, _ := NewClient()
+	defer cl.Close()
+
+	c := &chunkedProduce{partition: -1}
+	first := errors.New("first chunk failed")
+	promisedRec{chunked: c}.failChunk(first)
+
+	var got error
+	cl.doPartitionRecord(new(topicPartitions), new(topicPartitionsData), promisedRec{
+		ctx:     context.Background(),
+		promise: func(_ *Record, err error) { got = err },
+		Record:  &Record{Topic: "t"},
+		chunked: c,
+	})
+	if !errors.Is(got, first) {
+		t.Errorf("got %v, expected the later chunk to fail with the first chunk's error", got)
+	}
+}
+
+func TestChunkTimeoutValidated(t *testing.T) {
+	if _, err := NewClient(ConsumeChunkedRecords(1<<20, 0)); err == nil {
+		t.Error("expected a zero chunk reassembly timeout to be rejected")
+	}
+}
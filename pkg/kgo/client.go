@@ -85,6 +85,11 @@ type Client struct {
 	compressor   *compressor
 	decompressor *decompressor
 
+	// Producer settings per topic; see TopicProducerOverrides.
+	defaultTopicCfg  *producerTopicCfg
+	topicCfgs        map[string]*producerTopicCfg // only topics with overrides
+	topicCompressors map[string]*compressor       // only topics with compression overrides
+
 	coordinatorsMu sync.Mutex
 	coordinators   map[coordinatorKey]*coordinatorLoad
 
@@ -174,6 +179,15 @@ func NewClient(opts ...Opt) (*Client, error) {
 		return nil, err
 	}
 	cl.compressor = compressor
+	if err := cl.initProducerTopicCfgs(); err != nil {
+		return nil, err
+	}
+	var spill *spillBuffer
+	if cfg.spillDir != "" {
+		if spill, err = cl.openSpill(); err != nil {
+			return nil, err
+		}
+	}
 
 	// Before we start any goroutines below, we must notify any interested
 	// hooks of our existence.
@@ -185,6 +199,10 @@ func NewClient(opts ...Opt) (*Client, error) {
 
 	cl.producer.init(cl)
 	cl.consumer.init(cl)
+	if spill != nil {
+		cl.producer.spill = spill
+		go spill.loop()
+	}
 	cl.metawait.init()
 
 	if cfg.id != nil {
@@ -497,6 +515,9 @@ func (cl *Client) Close() {
 	}
 
 	cl.failBufferedRecords(ErrClientClosed)
+	if spill := cl.producer.spill; spill != nil {
+		spill.close()
+	}
 }
 
 // Request issues a request to Kafka, waiting for and returning the response.
@@ -109,6 +109,7 @@ type cfg struct {
 	defaultProduceTopic string
 	maxRecordBatchBytes int32
 	maxBufferedRecords  int64
+	maxBufferedBytes    int64
 	produceTimeout      time.Duration
 	recordRetries       int64
 	linger              time.Duration
@@ -117,6 +118,19 @@ type cfg struct {
 
 	partitioner Partitioner
 
+	produceInterceptors []ProduceInterceptor
+
+	produceChunkBytes int32
+
+	topicProducerOverrides map[string]*topicProducerOverrides
+
+	produceRecordsPerSec float64
+	produceBytesPerSec   float64
+
+	spillDir              string
+	spillMaxBytes         int64
+	spillRecoveredPromise func(*Record, error)
+
 	stopOnDataLoss bool
 	onDataLoss     func(string, int32)
 
@@ -133,10 +147,24 @@ type cfg struct {
 	keepControl    bool
 	rack           string
 
+	consumeInterceptors []ConsumeInterceptor
+
+	chunkMaxBytes int64 // if positive, chunked records are reassembled
+	chunkTimeout  time.Duration
+
+	consumeRecordsPerSec   float64
+	consumeBytesPerSec     float64
+	consumeTopicRateLimits map[string][2]float64 // topic => records/sec, bytes/sec
+
+	offsetStore OffsetStore // if non-nil, offsets are loaded from and committed to this rather than Kafka
+
 	allowedConcurrentFetches int
+	maxBufferedFetchBytes    int64
+
+	bounded bool // if true, consume only up to a snapshot of end offsets
 
-	topics     map[string]*regexp.Regexp   // topics to consume; if regex is true, values are compiled regular expressions
-	partitions map[string]map[int32]Offset // partitions to directly consume from
+	topics     map[string]*regexp.Regexp   // topics to consume; if regex is true, values are compiled regular expressions; replaced at runtime under the consumer mu
+	partitions map[string]map[int32]Offset // partitions to directly consume from; replaced at runtime under the consumer mu
 	regex      bool
 
 	////////////////////////////
@@ -163,6 +191,8 @@ type cfg struct {
 	setCommitCallback bool
 
 	autocommitDisable  bool // true if autocommit was disabled or we are transactional
+	autocommitAcked    bool // true if uncommitted offsets only advance through acked records
+	autocommitMarks    bool // true if uncommitted offsets only advance through marked records
 	autocommitInterval time.Duration
 	commitCallback     func(*Client, *kmsg.OffsetCommitRequest, *kmsg.OffsetCommitResponse, error)
 }
@@ -194,6 +224,18 @@ func (cfg *cfg) validate() error {
 	if !cfg.disableIdempotency && cfg.acks.val != -1 {
 		return errors.New("idempotency requires acks=all")
 	}
+	if err := cfg.validateTopicOverrides(); err != nil {
+		return err
+	}
+	if err := cfg.validateRateLimits(); err != nil {
+		return err
+	}
+	if cfg.spillDir != "" && cfg.txnID != nil {
+		return errors.New("cannot spill records to disk with a transactional producer")
+	}
+	if cfg.autocommitAcked && cfg.autocommitMarks {
+		return errors.New("cannot both autocommit acked records and autocommit marked records")
+	}
 
 	for _, limit := range []struct {
 		name    string
@@ -261,6 +303,19 @@ func (cfg *cfg) validate() error {
 		// 0 <= allowed concurrency
 		{name: "allowed concurrency", v: int64(cfg.allowedConcurrentFetches), allowed: 0, badcmp: i64lt},
 
+		// 0 <= max buffered fetch bytes
+		{name: "max buffered fetch bytes", v: cfg.maxBufferedFetchBytes, allowed: 0, badcmp: i64lt},
+
+		// 0 <= chunked records max bytes, and 0 < chunked records
+		// timeout if reassembling chunks
+		{name: "chunked records max bytes", v: cfg.chunkMaxBytes, allowed: 0, badcmp: i64lt},
+		{name: "chunked records timeout", v: int64(cfg.chunkTimeout), allowed: 1, badcmp: func(l, r int64) (bool, string) {
+			if cfg.chunkMaxBytes <= 0 {
+				return false, "" // the timeout is unused
+			}
+			return l < r, "less"
+		}, durs: true},
+
 		// 1s <= conn timeout overhead <= 15m
 		{name: "conn timeout max overhead", v: int64(cfg.connTimeoutOverhead), allowed: int64(15 * time.Minute), badcmp: i64gt, durs: true},
 		{name: "conn timeout min overhead", v: int64(cfg.connTimeoutOverhead), allowed: int64(time.Second), badcmp: i64lt, durs: true},
@@ -276,6 +331,12 @@ func (cfg *cfg) validate() error {
 
 		// Some random producer settings.
 		{name: "max buffered records", v: int64(cfg.maxBufferedRecords), allowed: 1, badcmp: i64lt},
+
+		// 0 <= max buffered bytes
+		{name: "max buffered bytes", v: cfg.maxBufferedBytes, allowed: 0, badcmp: i64lt},
+		{name: "producer chunk bytes", v: int64(cfg.produceChunkBytes), allowed: 0, badcmp: i64lt},
+		{name: "producer spill max bytes", v: cfg.spillMaxBytes, allowed: 0, badcmp: i64lt},
+		{v: int64(cfg.produceChunkBytes), allowed: int64(cfg.maxRecordBatchBytes), badcmp: i64gt, fmt: "producer chunk bytes %v is erroneously larger than max record batch bytes %v"},
 		{name: "linger", v: int64(cfg.linger), allowed: int64(time.Minute), badcmp: i64gt, durs: true},
 		{name: "produce timeout", v: int64(cfg.produceTimeout), allowed: int64(time.Second), badcmp: i64lt, durs: true},
 		{name: "record timeout", v: int64(cfg.recordTimeout), allowed: int64(time.Second), badcmp: func(l, r int64) (bool, string) {
@@ -316,9 +377,6 @@ func (cfg *cfg) validate() error {
 	}
 
 	if len(cfg.group) > 0 {
-		if len(cfg.topics) == 0 {
-			return errors.New("unable to consume from a group when no topics are specified")
-		}
 		if len(cfg.partitions) != 0 {
 			return errors.New("invalid direct-partition consuming option when consuming as a group")
 		}
@@ -331,7 +389,7 @@ func (cfg *cfg) validate() error {
 		for re := range cfg.topics {
 			compiled, err := regexp.Compile(re)
 			if err != nil {
-				return fmt.Errorf("invalid regular expression %q", re)
+				return fmt.Errorf("invalid regular expression %q: %w", re, err)
 			}
 			cfg.topics[re] = compiled
 		}
@@ -737,6 +795,135 @@ func DefaultProduceTopic(t string) ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.defaultProduceTopic = t }}
 }
 
+// ProduceInterceptors sets interceptors to run on every produced record before
+// the record is buffered and partitioned, overriding the default of no
+// interceptors.
+//
+// Interceptors run in order and can modify the record or reject it. See the
+// ProduceInterceptor documentation for more details.
+func ProduceInterceptors(interceptors ...ProduceInterceptor) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.produceInterceptors = append(cfg.produceInterceptors, interceptors...) }}
+}
+
+// TopicProducerOverrides overrides producer settings for a single topic,
+// allowing one client to produce to some topics with, for example, acks=1,
+// zstd compression, and a long linger, and to other topics with acks=all and
+// no linger. This option can be used multiple times; overrides for the same
+// topic are merged, with later overrides winning.
+//
+// RequiredAcks, BatchCompression, Linger, BatchMaxBytes, and RecordRetries can
+// be overridden. Any setting that is not overridden uses the client's setting.
+//
+// Overrides are validated when the client is created. If idempotency is
+// enabled (as it is by default), acks cannot be overridden to anything but
+// acks=all. Because produce connections are set up to either read or discard
+// responses based on the client's acks, no acks cannot be mixed with other
+// acks.
+//
+// Topics with different acks are produced in separate produce requests, since
+// acks is a field of the request itself.
+func TopicProducerOverrides(topic string, opts ...TopicProducerOpt) ProducerOpt {
+	return producerOpt{func(cfg *cfg) {
+		if cfg.topicProducerOverrides == nil {
+			cfg.topicProducerOverrides = make(map[string]*topicProducerOverrides)
+		}
+		o := cfg.topicProducerOverrides[topic]
+		if o == nil {
+			o = new(topicProducerOverrides)
+			cfg.topicProducerOverrides[topic] = o
+		}
+		for _, opt := range opts {
+			opt.apply(o)
+		}
+	}}
+}
+
+// ProducerChunkBytes splits records with values larger than n bytes into
+// ordered chunk records of at most n value bytes each, overriding the default
+// of not splitting records.
+//
+// Every chunk of a split record is produced to the same partition, which is
+// chosen for the first chunk by the partitioner. Chunks carry the
+// ChunkHeader headers, and only the first chunk carries the original record's
+// headers. The record's promise is called once every chunk is finished, with
+// the first error encountered, if any. Consumers must use
+// ConsumeChunkedRecords to reassemble split records.
+//
+// Chunks are kept in order in their partition by the idempotent producer; if
+// idempotency is disabled, retried chunks may be reordered, which reassembly
+// tolerates. The chunk size, plus the record key and headers, must fit within
+// BatchMaxBytes and the broker's max.message.bytes.
+func ProducerChunkBytes(n int32) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.produceChunkBytes = n }}
+}
+
+// ProducerSpillDir enables a disk backed buffer in dir for records that cannot
+// be buffered in memory or cannot be produced because brokers are
+// unavailable, overriding the default of blocking or failing such records.
+// The spill holds at most maxBytes of records, with zero meaning unlimited.
+//
+// Records are spilled when producing would otherwise block because
+// MaxBufferedRecords or MaxBufferedBytes is reached, and when buffered records
+// fail because of RecordTimeout, RecordRetries, retriable broker errors, or
+// the client closing. Once anything is spilled, every new record is spilled
+// until the spill is fully replayed, which keeps records in order; if the
+// spill is full or cannot be written while anything is spilled, producing
+// blocks until the spill is fully replayed. Spilled records are replayed in
+// the order they were spilled, and a record's promise is called only once
+// the record is produced or fails with an error that is not retriable. If a
+// replayed record fails with a retriable error, replaying stops and resumes
+// from that record, and records after it for the same topic are produced
+// again. Note that a record failing out of memory is spilled after any
+// records that were spilled while it was buffered.
+//
+// Only promises are kept in memory for spilled records. The record passed to
+// a spilled record's promise is read back from disk, and has only the topic,
+// partition, key, value, and headers of the original record.
+//
+// Spilled records survive restarts: a new client using the same directory
+// replays anything the prior client did not finish producing. When the client
+// is closed, promises for records that are still spilled are called with
+// ErrRecordSpilled. Records from a prior client have no promise; see
+// ProducerSpillRecoveredPromise. Because only acknowledged records advance
+// the persisted replay position, records can be produced twice if a process
+// stops while they are being replayed.
+//
+// If nothing is spilled and the spill is full or cannot be written, records
+// fall back to being buffered in memory. Spilled records ignore the context
+// they were produced with, and Flush does not wait for spilled records. The
+// spill is synced to disk every second rather than on every write: it
+// survives the process restarting, but the machine crashing can lose the
+// last second of spilled records. Only one client at a time may use a spill
+// directory, and spilling cannot be used with a transactional producer.
+func ProducerSpillDir(dir string, maxBytes int64) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.spillDir, cfg.spillMaxBytes = dir, maxBytes }}
+}
+
+// ProducerSpillRecoveredPromise sets a promise to call for records that were
+// spilled by a prior client and are replayed by this one; see
+// ProducerSpillDir. Without this, recovered records that fail are logged.
+func ProducerSpillRecoveredPromise(fn func(*Record, error)) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.spillRecoveredPromise = fn }}
+}
+
+// ProduceRateLimit limits how fast records can be produced across all topics,
+// in records per second and value, key, and header bytes per second,
+// overriding the default of no limit. A zero rate is unlimited.
+//
+// Limits are token buckets that allow bursting up to one second of either
+// rate. If producing a record would exceed a limit, Produce blocks until the
+// record is allowed or until the record's context is canceled or the client
+// is closed, in which case the record fails with the context's error or
+// ErrClientClosed. A single record that is larger than the bytes per second
+// rate is allowed once the bucket is full, but delays records after it.
+//
+// Rate limiting happens before records are chunked or buffered, and before
+// MaxBufferedRecords and MaxBufferedBytes are considered. Limits for
+// individual topics can be set with TopicProduceRateLimit.
+func ProduceRateLimit(recordsPerSec, bytesPerSec float64) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.produceRecordsPerSec, cfg.produceBytesPerSec = recordsPerSec, bytesPerSec }}
+}
+
 // Acks represents the number of acks a broker leader must have before
 // a produce request is considered complete.
 //
@@ -821,6 +1008,19 @@ func MaxBufferedRecords(n int) ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.maxBufferedRecords = int64(n) }}
 }
 
+// MaxBufferedBytes sets the max amount of bytes the client will buffer for
+// producing, blocking produces until records are finished if this limit is
+// reached. This overrides the unbounded default of 0.
+//
+// Bytes are counted as the size of every buffered record's key, value, and
+// headers. If nothing is buffered, a record larger than this limit is still
+// allowed so that it does not block forever. This limit applies in addition
+// to MaxBufferedRecords. The current usage can be checked with
+// BufferedProduceBytes.
+func MaxBufferedBytes(n int64) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.maxBufferedBytes = n }}
+}
+
 // RecordPartitioner uses the given partitioner to partition records, overriding
 // the default StickyKeyPartitioner.
 func RecordPartitioner(partitioner Partitioner) ProducerOpt {
@@ -1042,6 +1242,22 @@ func AllowedConcurrentFetches(n int) ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.allowedConcurrentFetches = n }}
 }
 
+// MaxBufferedFetchBytes sets the max amount of bytes the client will buffer
+// from fetching across all brokers before it stops issuing new fetches,
+// overriding the default of 0 (unbounded).
+//
+// Bytes are counted after decompression, as the size of every buffered
+// record's key, value, and headers. Once the client buffers at least this
+// many bytes, no new fetch is issued until polling drains the client below
+// this limit. The current usage can be checked with BufferedFetchBytes.
+//
+// This is a soft limit: fetches already in flight are still buffered, and
+// each fetch can bring the client over this limit by up to FetchMaxBytes.
+// For a harder bound, combine this with AllowedConcurrentFetches.
+func MaxBufferedFetchBytes(n int64) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.maxBufferedFetchBytes = n }}
+}
+
 // ConsumeResetOffset sets the offset to restart consuming from when a
 // partition has no commits (for groups) or when beginning to consume a
 // partition (for direct partition consuming), or when a fetch sees an
@@ -1081,6 +1297,35 @@ func FetchIsolationLevel(level IsolationLevel) ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.isolationLevel = level.level }}
 }
 
+// ConsumeBounded sets the client to consume only the records that exist when
+// the client starts consuming, and to then stop.
+//
+// When the client is created, it snapshots the end offset of every partition
+// of the topics and partitions it is configured to consume, with one
+// ListOffsets request, before any partition is fetched. The end offset is the
+// high watermark, or the last stable offset if using ReadCommitted. The
+// client stops fetching a partition once it reaches its snapshot, and any
+// records past the snapshot are dropped. Partitions that are not in the
+// initial snapshot, such as partitions of topics added with AddConsumeTopics,
+// partitions created later, or partitions whose end offset could not be
+// listed, are snapshotted with ListOffsets before they are first fetched.
+//
+// Snapshots are taken once per partition for the lifetime of the client; a
+// partition that is revoked and later reassigned keeps its original snapshot,
+// and a partition that reached its snapshot is not fetched again even if
+// offsets are later rewound.
+//
+// Once every partition being consumed has reached its snapshot, polling
+// returns a fake fetch that has no topic, a partition of 0, and a partition
+// error of ErrBoundedDone. The helper IsBoundedDone on Fetches can be used to
+// detect this and to break out of a poll loop.
+//
+// This option is useful for batch jobs and backfills that want to read
+// "everything that exists now".
+func ConsumeBounded() ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.bounded = true }}
+}
+
 // KeepControlRecords sets the client to keep control messages and return
 // them with fetches, overriding the default that discards them.
 //
@@ -1089,8 +1334,89 @@ func KeepControlRecords() ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.keepControl = true }}
 }
 
+// ConsumeInterceptors sets interceptors to run on every consumed record before
+// the record is buffered, overriding the default of no interceptors.
+//
+// Interceptors run in order and can rewrite a record's Key, Value, or Headers,
+// or drop the record. Interceptors run after control records and aborted
+// transactional records are discarded. See the ConsumeInterceptor
+// documentation for more details.
+func ConsumeInterceptors(interceptors ...ConsumeInterceptor) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.consumeInterceptors = append(cfg.consumeInterceptors, interceptors...) }}
+}
+
+// ConsumeChunkedRecords reassembles records that were split with
+// ProducerChunkBytes, overriding the default of returning chunks as is.
+//
+// Chunks are buffered per partition until every chunk of a split record has
+// been consumed, at which point a single record is returned with the original
+// value and headers. The reassembled record has the offset of the final chunk,
+// so committing the record commits every chunk. While a split record is
+// incomplete, commits for its partition do not advance past its first chunk.
+//
+// At most maxBytes of chunk values are buffered across all partitions. A split
+// record that would exceed this bound, or that is not complete within timeout
+// of its first consumed chunk, is dropped and logged. Timeouts are checked as
+// records are consumed, and the timeout must be positive.
+//
+// Reassembly runs before any ConsumeInterceptors.
+func ConsumeChunkedRecords(maxBytes int64, timeout time.Duration) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.chunkMaxBytes, cfg.chunkTimeout = maxBytes, timeout }}
+}
+
+// ConsumeRateLimit limits how fast records are consumed across all topics, in
+// records per second and value, key, and header bytes per second, overriding
+// the default of no limit. A zero rate is unlimited.
+//
+// Limits are token buckets that allow bursting up to one second of either
+// rate. Consumed records are counted as fetches are buffered, and once a limit
+// is exceeded, no source fetches until enough time has passed to pay for what
+// was consumed. A single fetch can exceed a limit, so FetchMaxBytes and
+// FetchMaxPartitionBytes should be reasonable relative to the bytes rate to
+// keep consuming smooth.
+//
+// This is separate from broker quotas: brokers throttle clients that exceed
+// quotas (see HookBrokerThrottle), whereas this paces the client so that
+// throttling does not happen in the first place.
+func ConsumeRateLimit(recordsPerSec, bytesPerSec float64) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.consumeRecordsPerSec, cfg.consumeBytesPerSec = recordsPerSec, bytesPerSec }}
+}
+
+// ConsumeTopicRateLimit limits how fast records are consumed from a single
+// topic, in records per second and value, key, and header bytes per second. A
+// zero rate is unlimited. This option can be used multiple times for
+// different topics; the last limit for a topic wins.
+//
+// Limits work the same as in ConsumeRateLimit, but only partitions for the
+// limited topic stop being fetched once the topic's limit is exceeded; other
+// topics on the same brokers continue to be fetched.
+func ConsumeTopicRateLimit(topic string, recordsPerSec, bytesPerSec float64) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) {
+		if cfg.consumeTopicRateLimits == nil {
+			cfg.consumeTopicRateLimits = make(map[string][2]float64)
+		}
+		cfg.consumeTopicRateLimits[topic] = [2]float64{recordsPerSec, bytesPerSec}
+	}}
+}
+
+// ConsumeOffsetStore sets the client to load starting offsets from and commit
+// offsets to the given store rather than Kafka. See the OffsetStore
+// documentation for more details.
+//
+// When consuming as a group, the client still uses Kafka for group membership
+// and assignment, but never issues OffsetFetch or OffsetCommit requests. When
+// consuming directly, new partitions begin at stored offsets, and the
+// client's Commit functions can be used to store offsets even though the
+// client is not in a group.
+func ConsumeOffsetStore(store OffsetStore) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.offsetStore = store }}
+}
+
 // ConsumeTopics adds topics to use for consuming.
 //
+// Topics can also be added or removed after the client is created with the
+// client's AddConsumeTopics and RemoveConsumeTopics methods.
+//
 // By default, consuming will start at the beginning of partitions. To change
 // this, use the ConsumeResetOffset option.
 func ConsumeTopics(topics ...string) ConsumerOpt {
@@ -1120,7 +1446,9 @@ func ConsumePartitions(partitions map[string]map[int32]Offset) ConsumerOpt {
 // When consuming via regex, every metadata request loads *all* topics, so that
 // all topics can be passed to any regular expressions. Every topic is
 // evaluated only once ever across all regular expressions; either it
-// permanently is known to match, or is permanently known to not match.
+// permanently is known to match, or is permanently known to not match. The
+// exception is if expressions are added or removed with AddConsumeTopics or
+// RemoveConsumeTopics, in which case topics are re-evaluated as necessary.
 func ConsumeRegex() ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.regex = true }}
 }
@@ -1308,6 +1636,46 @@ func DisableAutoCommit() GroupOpt {
 	return groupOpt{func(cfg *cfg) { cfg.autocommitDisable = true }}
 }
 
+// AutoCommitAcked switches uncommitted offsets from tracking what has been
+// polled to tracking what has been acked with the client's AckRecords method.
+//
+// By default, polling a record advances the offset that will be committed,
+// meaning a crash while processing polled records loses those records. With
+// this option, every polled record must be acked, and the uncommitted offset
+// for a partition only advances through the highest contiguously acked
+// record. Records can be acked in any order; for example, if offsets 0, 1, and
+// 3 are acked, the committable offset is 2 until offset 2 is acked.
+//
+// This affects everything that uses uncommitted offsets: autocommitting, the
+// default OnRevoked, CommitUncommittedOffsets, and UncommittedOffsets.
+//
+// If a partition is revoked or lost, any records awaiting acks are forgotten
+// and acks for them are ignored.
+func AutoCommitAcked() GroupOpt {
+	return groupOpt{func(cfg *cfg) { cfg.autocommitAcked = true }}
+}
+
+// AutoCommitMarks switches uncommitted offsets from tracking what has been
+// polled to tracking what has been marked with the client's MarkCommitRecords
+// method.
+//
+// By default, polling a record advances the offset that will be committed,
+// meaning autocommitting can commit records that have not yet been processed.
+// With this option, polling does not advance anything; only marking a record
+// advances the uncommitted offset for its partition to just past the record.
+// This is similar to calling CommitRecords after processing, but the commit
+// is deferred to the autocommit loop.
+//
+// This affects everything that uses uncommitted offsets: autocommitting, the
+// default OnRevoked, CommitUncommittedOffsets, and UncommittedOffsets. Thus,
+// with the default OnRevoked, revoked partitions have their marked offsets
+// committed before the partitions are given to another member.
+//
+// This option cannot be used with AutoCommitAcked.
+func AutoCommitMarks() GroupOpt {
+	return groupOpt{func(cfg *cfg) { cfg.autocommitMarks = true }}
+}
+
 // AutoCommitInterval sets how long to go between autocommits, overriding the
 // default 5s.
 func AutoCommitInterval(interval time.Duration) GroupOpt {
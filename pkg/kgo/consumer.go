@@ -2,6 +2,8 @@ package kgo
 
 import (
 	"context"
+	"fmt"
+	"regexp"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,6 +18,7 @@ type Offset struct {
 	relative     int64
 	epoch        int32
 	currentEpoch int32 // set by us when mapping offsets to brokers
+	afterMilli   bool  // if true, at is a millisecond timestamp
 }
 
 // NewOffset creates and returns an offset to use in ConsumePartitions or
@@ -33,6 +36,7 @@ func NewOffset() Offset {
 // to begin at the beginning of a partition.
 func (o Offset) AtStart() Offset {
 	o.at = -2
+	o.afterMilli = false
 	return o
 }
 
@@ -40,6 +44,7 @@ func (o Offset) AtStart() Offset {
 // begin at the end of a partition.
 func (o Offset) AtEnd() Offset {
 	o.at = -1
+	o.afterMilli = false
 	return o
 }
 
@@ -62,6 +67,30 @@ func (o Offset) WithEpoch(e int32) Offset {
 	return o
 }
 
+// AfterMilli returns an offset that consumes from the first offset at or after
+// the given millisecond timestamp. The offset is resolved by listing offsets
+// for the timestamp. If no record exists at or after the timestamp, the offset
+// falls back to the end of the partition, meaning the next record produced
+// will be the first consumed.
+//
+// This option is not compatible with At, AtStart, or AtEnd, nor with epoch
+// validation: any of those clear the timestamp and WithEpoch is ignored. A
+// Relative offset is applied to whatever offset the timestamp resolves to.
+func (o Offset) AfterMilli(millisec int64) Offset {
+	if millisec < 0 {
+		millisec = 0
+	}
+	o.at = millisec
+	o.epoch = -1
+	o.afterMilli = true
+	return o
+}
+
+// AfterTime is the same as AfterMilli, but for a time.Time.
+func (o Offset) AfterTime(t time.Time) Offset {
+	return o.AfterMilli(t.UnixNano() / int64(time.Millisecond))
+}
+
 // At returns a copy of the calling offset, changing the returned offset to
 // begin at exactly the requested offset.
 //
@@ -76,6 +105,7 @@ func (o Offset) At(at int64) Offset {
 		at = -2
 	}
 	o.at = at
+	o.afterMilli = false
 	return o
 }
 
@@ -83,6 +113,21 @@ type consumer struct {
 	cl *Client
 
 	bufferedRecords int64
+	bufferedBytes   int64
+
+	// If fetching is blocked by MaxBufferedFetchBytes, sources wait on
+	// bufferedBytesDrained, which is closed once we are below the limit.
+	bufferedBytesMu      sync.Mutex
+	bufferedBytesDrained chan struct{}
+
+	// chunkBytes is the number of chunk value bytes buffered for
+	// reassembly across all cursors.
+	chunkBytes int64
+
+	// limiter and topicLimiters, if non-nil, limit the client wide and
+	// per topic consume rates. Neither are modified after init.
+	limiter       *rateLimiter
+	topicLimiters map[string]*rateLimiter
 
 	// mu is grabbed when
 	//  - polling fetches, for quickly draining sources / updating group uncommitted
@@ -115,6 +160,18 @@ type consumer struct {
 
 	usingCursors usedCursors
 
+	// pausedMu serializes writers of paused; paused itself is loaded
+	// atomically by sources while building fetch requests.
+	pausedMu sync.Mutex
+	paused   atomic.Value // pausedTopics
+
+	bounded *boundedEnds // non-nil if consuming with ConsumeBounded
+
+	// lagMu guards lags, which tracks end offsets (updated on fetch) for
+	// ConsumerLag.
+	lagMu sync.Mutex
+	lags  map[string]map[int32]*partitionLag
+
 	sourcesReadyMu          sync.Mutex
 	sourcesReadyCond        *sync.Cond
 	sourcesReadyForDraining []*source
@@ -130,6 +187,8 @@ type consumer struct {
 // response being processed just before a call to this function. It is only
 // problematic if for you if this function is consistently returning large
 // values.
+//
+// To see the number of bytes buffered, use BufferedFetchBytes.
 func (cl *Client) BufferedFetchRecords() int64 {
 	return atomic.LoadInt64(&cl.consumer.bufferedRecords)
 }
@@ -146,12 +205,29 @@ func (u *usedCursors) use(c *cursor) {
 func (c *consumer) init(cl *Client) {
 	c.cl = cl
 	c.sourcesReadyCond = sync.NewCond(&c.sourcesReadyMu)
-
-	if len(cl.cfg.topics) == 0 && len(cl.cfg.partitions) == 0 {
-		return // not consuming
+	c.paused.Store(make(pausedTopics))
+	if cl.cfg.bounded {
+		c.bounded = newBoundedEnds()
+		partitions := make(map[string][]int32, len(cl.cfg.partitions))
+		for topic, ps := range cl.cfg.partitions {
+			for partition := range ps {
+				partitions[topic] = append(partitions[topic], partition)
+			}
+		}
+		topics := make(map[string]*regexp.Regexp, len(cl.cfg.topics))
+		for topic, re := range cl.cfg.topics {
+			topics[topic] = re
+		}
+		go c.loadBoundedEnds(cl.cfg.regex, topics, partitions)
 	}
+	c.initRateLimits()
 
-	defer cl.triggerUpdateMetadata(true) // we definitely want to trigger a metadata update
+	// We always initialize a consumer, even if no topics nor partitions
+	// are specified, because topics can be added later with
+	// AddConsumeTopics or AddConsumePartitions.
+	if len(cl.cfg.topics) > 0 || len(cl.cfg.partitions) > 0 {
+		defer cl.triggerUpdateMetadata(true) // we definitely want to trigger a metadata update
+	}
 
 	if len(cl.cfg.group) == 0 {
 		c.initDirect()
@@ -270,7 +346,10 @@ func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
 	}
 	c := &cl.consumer
 
-	var fetches Fetches
+	var (
+		fetches   Fetches
+		polledLag map[string]map[int32]PartitionLag
+	)
 	fill := func() {
 		// A group can grab the consumer lock then the group mu and
 		// assign partitions. The group mu is grabbed to update its
@@ -287,6 +366,13 @@ func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
 		c.mu.Lock()
 		defer c.mu.Unlock()
 
+		// If consuming bounded, we grab what we are consuming before
+		// the sourcesReadyMu to avoid lock inversion with the session.
+		var boundedUsing []*cursor
+		if c.bounded != nil {
+			boundedUsing = c.boundedUsing()
+		}
+
 		c.sourcesReadyMu.Lock()
 		if maxPollRecords < 0 {
 			for _, ready := range c.sourcesReadyForDraining {
@@ -310,12 +396,22 @@ func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
 		fetches = append(fetches, c.fakeReadyForDraining...)
 		c.fakeReadyForDraining = nil
 
+		if c.bounded != nil && c.boundedAllDone(boundedUsing) {
+			fetches = append(fetches, Fetch{Topics: []FetchTopic{{
+				Partitions: []FetchPartition{{
+					Err: ErrBoundedDone,
+				}},
+			}}})
+		}
+
 		c.sourcesReadyMu.Unlock()
 
 		if len(realFetches) == 0 {
 			return
 		}
 
+		polledLag = c.loadPolledLag(realFetches)
+
 		// Before returning, we want to update our uncommitted. If we
 		// updated after, then we could end up with weird interactions
 		// with group invalidations where we return a stale fetch after
@@ -332,6 +428,7 @@ func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
 
 	fill()
 	if len(fetches) > 0 || ctx == nil {
+		c.onConsumerLag(polledLag)
 		return fetches
 	}
 	select {
@@ -347,7 +444,10 @@ func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
 		defer c.sourcesReadyMu.Unlock()
 		defer close(done)
 
-		for !quit && len(c.sourcesReadyForDraining) == 0 {
+		for !quit &&
+			len(c.sourcesReadyForDraining) == 0 &&
+			len(c.fakeReadyForDraining) == 0 &&
+			!c.boundedShouldWake() {
 			c.sourcesReadyCond.Wait()
 		}
 	}()
@@ -373,9 +473,434 @@ func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
 	}
 
 	fill()
+	c.onConsumerLag(polledLag)
 	return fetches
 }
 
+// AddConsumeTopics adds new topics to be consumed. If the client is consuming
+// via regex, the input topics are parsed as regular expressions; if any
+// expression is invalid, this returns an error and nothing is added.
+//
+// For direct consumers, new topics are consumed once their metadata is loaded,
+// starting at the ConsumeResetOffset. For group consumers, adding topics
+// changes the member's subscription and causes the member to rejoin the group.
+// If the group has been left (with LeaveGroup or Close), this does nothing.
+//
+// This can be used to begin consuming on a client that was initialized
+// without any ConsumeTopics or ConsumePartitions.
+func (cl *Client) AddConsumeTopics(topics ...string) error {
+	if len(topics) == 0 {
+		return nil
+	}
+
+	var compiled map[string]*regexp.Regexp
+	if cl.cfg.regex {
+		compiled = make(map[string]*regexp.Regexp, len(topics))
+		for _, re := range topics {
+			rec, err := regexp.Compile(re)
+			if err != nil {
+				return fmt.Errorf("invalid regular expression %q: %w", re, err)
+			}
+			compiled[re] = rec
+		}
+	}
+
+	c := &cl.consumer
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// cfg.topics is only read at runtime under the consumer mu, so we can
+	// swap in a new map here.
+	newTopics := make(map[string]*regexp.Regexp, len(cl.cfg.topics)+len(topics))
+	for topic, re := range cl.cfg.topics {
+		newTopics[topic] = re
+	}
+	for _, topic := range topics {
+		newTopics[topic] = compiled[topic] // nil if not regex
+	}
+	cl.cfg.topics = newTopics
+
+	switch {
+	case c.d != nil:
+		c.d.addTopics(topics)
+	case c.g != nil:
+		c.g.addTopics(topics)
+	}
+
+	cl.triggerUpdateMetadataNow()
+	return nil
+}
+
+// RemoveConsumeTopics stops consuming the given topics. If the client is
+// consuming via regex, the input topics are the regular expressions to remove,
+// and any topic that no longer matches a remaining expression stops being
+// consumed.
+//
+// Any data buffered for the removed topics is dropped, and for direct
+// consumers, any partitions pinned for the topics with ConsumePartitions or
+// AddConsumePartitions are removed as well.
+//
+// For group consumers, removing topics changes the member's subscription and
+// causes the member to rejoin the group. The removed partitions are revoked
+// as usual, meaning OnRevoked is called (and, by default, offsets are
+// committed) before the partitions are given up.
+func (cl *Client) RemoveConsumeTopics(topics ...string) {
+	if len(topics) == 0 {
+		return
+	}
+
+	c := &cl.consumer
+	c.mu.Lock()
+
+	newTopics := make(map[string]*regexp.Regexp, len(cl.cfg.topics))
+	for topic, re := range cl.cfg.topics {
+		newTopics[topic] = re
+	}
+	for _, topic := range topics {
+		delete(newTopics, topic)
+	}
+	cl.cfg.topics = newTopics
+
+	if len(cl.cfg.partitions) > 0 {
+		newPartitions := make(map[string]map[int32]Offset, len(cl.cfg.partitions))
+		for topic, offsets := range cl.cfg.partitions {
+			newPartitions[topic] = offsets
+		}
+		for _, topic := range topics {
+			delete(newPartitions, topic)
+		}
+		cl.cfg.partitions = newPartitions
+	}
+
+	var (
+		tps     *topicsPartitions
+		removed map[string]map[int32]Offset
+	)
+	switch {
+	case c.d != nil:
+		tps = c.d.tps
+		removed = c.d.removeTopics(topics)
+	case c.g != nil:
+		tps = c.g.tps
+		removed = c.g.removeTopics(topics)
+	}
+
+	if len(removed) > 0 {
+		c.assignPartitions(removed, assignInvalidateMatching, tps)
+		if c.g != nil {
+			c.g.rejoin()
+		}
+	}
+
+	// For regex consuming, every topic is always tracked so that it can be
+	// evaluated; we only purge non-regex topics.
+	var purged []*topicPartitions
+	if !cl.cfg.regex {
+		purged = tps.purgeTopics(topics)
+	}
+
+	c.mu.Unlock()
+
+	c.removePurgedCursors(purged)
+}
+
+// AddConsumePartitions adds new partitions to be consumed at the given
+// offsets. Partitions that are already being consumed are not modified.
+//
+// This function only applies to direct, non-regex consumers; it is a no-op
+// when consuming as a group or via regex.
+func (cl *Client) AddConsumePartitions(partitions map[string]map[int32]Offset) {
+	c := &cl.consumer
+	if len(partitions) == 0 || c.d == nil || cl.cfg.regex {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// As with topics, we swap in a new map. We also avoid modifying the
+	// map that the user passed to ConsumePartitions.
+	newPartitions := make(map[string]map[int32]Offset, len(cl.cfg.partitions)+len(partitions))
+	for topic, offsets := range cl.cfg.partitions {
+		newPartitions[topic] = offsets
+	}
+	topics := make([]string, 0, len(partitions))
+	for topic, offsets := range partitions {
+		newOffsets := make(map[int32]Offset, len(newPartitions[topic])+len(offsets))
+		for partition, offset := range newPartitions[topic] {
+			newOffsets[partition] = offset
+		}
+		for partition, offset := range offsets {
+			newOffsets[partition] = offset
+		}
+		newPartitions[topic] = newOffsets
+		topics = append(topics, topic)
+	}
+	cl.cfg.partitions = newPartitions
+
+	c.d.tps.storeTopics(topics)
+	cl.triggerUpdateMetadataNow()
+}
+
+// RemoveConsumePartitions stops consuming the given partitions that were
+// added with ConsumePartitions or AddConsumePartitions. Any data buffered for
+// the removed partitions is dropped.
+//
+// Partitions that are consumed because their entire topic is consumed (with
+// ConsumeTopics or AddConsumeTopics) continue to be consumed; to stop
+// consuming those, use RemoveConsumeTopics.
+//
+// This function only applies to direct, non-regex consumers; it is a no-op
+// when consuming as a group or via regex.
+func (cl *Client) RemoveConsumePartitions(partitions map[string][]int32) {
+	c := &cl.consumer
+	if len(partitions) == 0 || c.d == nil || cl.cfg.regex {
+		return
+	}
+
+	c.mu.Lock()
+
+	newPartitions := make(map[string]map[int32]Offset, len(cl.cfg.partitions))
+	for topic, offsets := range cl.cfg.partitions {
+		newPartitions[topic] = offsets
+	}
+	var emptied []string
+	for topic, rmPartitions := range partitions {
+		offsets, exists := newPartitions[topic]
+		if !exists {
+			continue
+		}
+		newOffsets := make(map[int32]Offset, len(offsets))
+		for partition, offset := range offsets {
+			newOffsets[partition] = offset
+		}
+		for _, partition := range rmPartitions {
+			delete(newOffsets, partition)
+		}
+		if len(newOffsets) > 0 {
+			newPartitions[topic] = newOffsets
+			continue
+		}
+		delete(newPartitions, topic)
+		if _, consumingTopic := cl.cfg.topics[topic]; !consumingTopic {
+			emptied = append(emptied, topic)
+		}
+	}
+
+	removed := c.d.removePartitions(partitions)
+	cl.cfg.partitions = newPartitions
+
+	if len(removed) > 0 {
+		c.assignPartitions(removed, assignInvalidateMatching, c.d.tps)
+	}
+
+	purged := c.d.tps.purgeTopics(emptied)
+
+	c.mu.Unlock()
+
+	c.removePurgedCursors(purged)
+}
+
+// removePurgedCursors removes all cursors for topics that are no longer being
+// consumed from their sources. The cursors have already been invalidated, but
+// if we kept them, a future re-add of the topic would create new cursors that
+// duplicate the old.
+//
+// This runs in the metadata loop, which ensures that a concurrent metadata
+// update is not migrating these cursors or adding new ones for the purged
+// topics: any update running now loaded the topic before it was purged, and
+// will have finished before our function runs.
+func (c *consumer) removePurgedCursors(purged []*topicPartitions) {
+	if len(purged) == 0 {
+		return
+	}
+	c.cl.blockingMetadataFn(func() {
+		for _, tps := range purged {
+			for _, tp := range tps.load().partitions {
+				cursor := tp.cursor
+				s := cursor.source
+
+				// If the consumer was unset, sources had their
+				// cursors cleared and this cursor is already gone.
+				s.cursorsMu.Lock()
+				idx := cursor.cursorsIdx
+				present := idx >= 0 && idx < len(s.cursors) && s.cursors[idx] == cursor
+				s.cursorsMu.Unlock()
+
+				if present {
+					s.removeCursor(cursor)
+					cursor.cursorsIdx = -1
+				}
+			}
+		}
+	})
+}
+
+// reevaluateRegex, called under the consumer mu after regular expressions are
+// removed, re-evaluates every topic that previously matched and returns the
+// topics that no longer match any remaining expression.
+func reevaluateRegex(reSeen map[string]bool, res map[string]*regexp.Regexp) []string {
+	var lost []string
+	for topic, want := range reSeen {
+		if !want {
+			continue
+		}
+		var still bool
+		for _, re := range res {
+			if still = re.MatchString(topic); still {
+				break
+			}
+		}
+		if !still {
+			reSeen[topic] = false
+			lost = append(lost, topic)
+		}
+	}
+	return lost
+}
+
+// forgetUnmatchedRegex, called under the consumer mu after regular expressions
+// are added, clears every topic that was known to not match so that the topic
+// can be evaluated against the new expressions.
+func forgetUnmatchedRegex(reSeen map[string]bool) {
+	for topic, want := range reSeen {
+		if !want {
+			delete(reSeen, topic)
+		}
+	}
+}
+
+// PauseFetchTopics sets the client to no longer fetch the given topics and
+// returns all currently paused topics. Paused topics persist until resumed.
+// You can call this function with no topics to simply receive the list of
+// currently paused topics.
+//
+// Pausing topics is independent from pausing individual partitions with the
+// PauseFetchPartitions method. If you pause partitions for a topic with
+// PauseFetchPartitions, and then pause that same topic with PauseFetchTopics,
+// the individually paused partitions will not be unpaused if you only call
+// ResumeFetchTopics.
+//
+// Pausing is not tied to the client's current assignment: you can pause
+// topics that are not yet being consumed, and paused topics remain paused
+// across group rebalances. Any data that is buffered for a paused topic when
+// this function is called is dropped, and the topic will be fetched from the
+// last polled offsets once resumed.
+func (cl *Client) PauseFetchTopics(topics ...string) []string {
+	c := &cl.consumer
+	if len(topics) == 0 {
+		return c.loadPaused().pausedTopics()
+	}
+
+	c.pausedMu.Lock()
+	defer c.pausedMu.Unlock()
+
+	paused := c.clonePaused()
+	paused.addTopics(topics...)
+	c.storePausedAndBump(paused)
+	return paused.pausedTopics()
+}
+
+// PauseFetchPartitions sets the client to no longer fetch the given partitions
+// and returns all currently paused partitions. Paused partitions persist until
+// resumed. You can call this function with no partitions to simply receive the
+// list of currently paused partitions.
+//
+// Pausing individual partitions is independent from pausing topics with the
+// PauseFetchTopics method. If you pause partitions for a topic with
+// PauseFetchPartitions, and then pause that same topic with PauseFetchTopics,
+// the individually paused partitions will not be unpaused if you only call
+// ResumeFetchTopics.
+//
+// As with PauseFetchTopics, pausing partitions persists across group
+// rebalances and drops any data that is currently buffered.
+func (cl *Client) PauseFetchPartitions(topicPartitions map[string][]int32) map[string][]int32 {
+	c := &cl.consumer
+	if len(topicPartitions) == 0 {
+		return c.loadPaused().pausedPartitions()
+	}
+
+	c.pausedMu.Lock()
+	defer c.pausedMu.Unlock()
+
+	paused := c.clonePaused()
+	paused.addPartitions(topicPartitions)
+	c.storePausedAndBump(paused)
+	return paused.pausedPartitions()
+}
+
+// ResumeFetchTopics resumes fetching the input topics if they were previously
+// paused. Resuming topics that are not currently paused is a per-topic no-op.
+// See the documentation on PauseFetchTopics for more details.
+func (cl *Client) ResumeFetchTopics(topics ...string) {
+	c := &cl.consumer
+
+	c.pausedMu.Lock()
+	defer c.pausedMu.Unlock()
+
+	paused := c.clonePaused()
+	paused.delTopics(topics...)
+	c.storePausedAndResume(paused)
+}
+
+// ResumeFetchPartitions resumes fetching the input partitions if they were
+// previously paused. Resuming partitions that are not currently paused is a
+// per-partition no-op. See the documentation on PauseFetchPartitions for more
+// details.
+func (cl *Client) ResumeFetchPartitions(topicPartitions map[string][]int32) {
+	c := &cl.consumer
+
+	c.pausedMu.Lock()
+	defer c.pausedMu.Unlock()
+
+	paused := c.clonePaused()
+	paused.delPartitions(topicPartitions)
+	c.storePausedAndResume(paused)
+}
+
+func (c *consumer) loadPaused() pausedTopics  { return c.paused.Load().(pausedTopics) }
+func (c *consumer) clonePaused() pausedTopics { return c.loadPaused().clone() }
+
+// storePausedAndBump, called under the paused mu, stores the new paused state
+// and then bumps the consumer session. Bumping kills any in flight fetch and
+// drops any buffered fetch, ensuring that nothing from a newly paused
+// partition is returned from polling. The new session will not fetch paused
+// partitions.
+func (c *consumer) storePausedAndBump(paused pausedTopics) {
+	c.paused.Store(paused)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.assignPartitions(nil, assignBumpSession, nil)
+}
+
+// pauseFetchPartitionsLazily pauses partitions without bumping the consumer
+// session. New fetches skip the partitions and have the broker forget them
+// from our fetch session, but anything in flight or buffered for the
+// partitions can still be polled. This is for internal users that handle
+// polling records for partitions they just paused.
+func (c *consumer) pauseFetchPartitionsLazily(topicPartitions map[string][]int32) {
+	c.pausedMu.Lock()
+	defer c.pausedMu.Unlock()
+
+	paused := c.clonePaused()
+	paused.addPartitions(topicPartitions)
+	c.paused.Store(paused)
+}
+
+// storePausedAndResume, called under the paused mu, stores the new paused
+// state and then notifies all sources that they may be able to fetch again.
+// Resuming never needs to drop anything, so we do not kill the session.
+func (c *consumer) storePausedAndResume(paused pausedTopics) {
+	c.paused.Store(paused)
+
+	c.cl.sinksAndSourcesMu.Lock()
+	defer c.cl.sinksAndSourcesMu.Unlock()
+	for _, sns := range c.cl.sinksAndSources {
+		sns.source.maybeConsume()
+	}
+}
+
 // assignHow controls how assignPartitions operates.
 type assignHow int8
 
@@ -399,6 +924,12 @@ const (
 	// The counterpart to assignInvalidateMatching, assignSetMatching
 	// resets all matching partitions to the specified offset / epoch.
 	assignSetMatching
+
+	// This option does not assign nor invalidate any cursor; it only
+	// stops and restarts the session. This kills any active fetches and
+	// drops all buffered fetches, which will be re-fetched from the same
+	// offsets once the new session begins.
+	assignBumpSession
 )
 
 func (h assignHow) String() string {
@@ -411,6 +942,8 @@ func (h assignHow) String() string {
 		return "assign invalidate matching"
 	case assignSetMatching:
 		return "assign set matching"
+	case assignBumpSession:
+		return "assign bump session"
 	}
 	return ""
 }
@@ -439,12 +972,18 @@ func (c *consumer) assignPartitions(assignments map[string]map[int32]Offset, how
 		session.decWorker()
 	}()
 
-	if how == assignWithoutInvalidating {
+	switch how {
+	case assignWithoutInvalidating:
 		// Guarding a session change can actually create a new session
 		// if we had no session before, which is why we need to pass in
 		// our topicPartitions.
 		session = c.guardSessionChange(tps)
-	} else {
+	case assignBumpSession:
+		// Bumping keeps everything as is, including any partitions
+		// that were listing offsets or loading epochs. We restart
+		// with the same topicPartitions the prior session had.
+		loadOffsets, tps = c.stopSession()
+	default:
 		loadOffsets, _ = c.stopSession()
 
 		// First, over all cursors currently in use, we unset them or set them
@@ -473,6 +1012,8 @@ func (c *consumer) assignPartitions(assignments map[string]map[int32]Offset, how
 			}
 			if shouldKeep {
 				keep.use(usedCursor)
+			} else {
+				c.forgetLag(usedCursor)
 			}
 		}
 		c.usingCursors = keep
@@ -506,7 +1047,7 @@ func (c *consumer) assignPartitions(assignments map[string]map[int32]Offset, how
 
 	// This assignment could contain nothing (for the purposes of
 	// invalidating active fetches), so we only do this if needed.
-	if len(assignments) == 0 || how == assignInvalidateMatching || how == assignSetMatching {
+	if len(assignments) == 0 || how == assignInvalidateMatching || how == assignSetMatching || how == assignBumpSession {
 		return
 	}
 
@@ -521,6 +1062,16 @@ func (c *consumer) assignPartitions(assignments map[string]map[int32]Offset, how
 		}
 
 		for partition, offset := range partitions {
+			// A timestamp request always lists offsets; the
+			// relative portion is applied after listing.
+			if offset.afterMilli {
+				loadOffsets.addLoad(topic, partition, loadTypeList, offsetLoad{
+					replica: -1,
+					Offset:  offset,
+				})
+				continue
+			}
+
 			// First, if the request is exact, get rid of the relative
 			// portion. We are modifying a copy of the offset, i.e. we
 			// are appropriately not modfying 'assignments' itself.
@@ -595,9 +1146,20 @@ func (c *consumer) doOnMetadataUpdate() {
 
 			switch {
 			case c.d != nil:
-				if new := c.d.findNewAssignments(); len(new) > 0 {
-					c.assignPartitions(new, assignWithoutInvalidating, c.d.tps)
+				new := c.d.findNewAssignments()
+				if len(new) == 0 {
+					break
 				}
+				if c.cl.cfg.offsetStore != nil {
+					// The new partitions are already in
+					// d.using, so nothing else assigns them
+					// while we load their stored offsets in
+					// the background; the load assigns them
+					// under the mu once done.
+					go c.assignStoredOffsets(c.d, new)
+					break
+				}
+				c.assignPartitions(new, assignWithoutInvalidating, c.d.tps)
 			case c.g != nil:
 				c.g.findNewAssignments()
 			}
@@ -641,6 +1203,18 @@ type offsetLoad struct {
 
 type offsetLoadMap map[string]map[int32]offsetLoad
 
+func (o *offsetLoadMap) add(t string, p int32, load offsetLoad) {
+	if *o == nil {
+		*o = make(offsetLoadMap)
+	}
+	ps := (*o)[t]
+	if ps == nil {
+		ps = make(map[int32]offsetLoad)
+		(*o)[t] = ps
+	}
+	ps[p] = load
+}
+
 func (o offsetLoadMap) errToLoaded(err error) []loadedOffset {
 	var loaded []loadedOffset
 	for t, ps := range o {
@@ -1278,72 +1852,87 @@ func (l *loadedOffsets) addAll(as []loadedOffset) loadedOffsets {
 func (cl *Client) listOffsetsForBrokerLoad(ctx context.Context, broker *broker, load offsetLoadMap, tps *topicsPartitions, results chan<- loadedOffsets) {
 	loaded := loadedOffsets{loadType: loadTypeList}
 
-	kresp, err := broker.waitResp(ctx, load.buildListReq(cl.cfg.isolationLevel))
-	if err != nil {
-		results <- loaded.addAll(load.errToLoaded(err))
-		return
-	}
+	// If we list offsets for a timestamp and no record exists at or after
+	// that timestamp, Kafka replies with offset -1. For these, we fall
+	// back to listing the end offset, which we do in a second loop.
+	for len(load) > 0 {
+		var fallback offsetLoadMap
 
-	topics := tps.load()
-	resp := kresp.(*kmsg.ListOffsetsResponse)
-	for _, rTopic := range resp.Topics {
-		topic := rTopic.Topic
-		loadParts, ok := load[topic]
-		if !ok {
-			continue // should not happen: kafka replied with something we did not ask for
+		kresp, err := broker.waitResp(ctx, load.buildListReq(cl.cfg.isolationLevel))
+		if err != nil {
+			results <- loaded.addAll(load.errToLoaded(err))
+			return
 		}
 
-		topicPartitions := topics.loadTopic(topic) // must be non-nil at this point
-		for _, rPartition := range rTopic.Partitions {
-			partition := rPartition.Partition
-			loadPart, ok := loadParts[partition]
+		topics := tps.load()
+		resp := kresp.(*kmsg.ListOffsetsResponse)
+		for _, rTopic := range resp.Topics {
+			topic := rTopic.Topic
+			loadParts, ok := load[topic]
 			if !ok {
 				continue // should not happen: kafka replied with something we did not ask for
 			}
 
-			if err := kerr.ErrorForCode(rPartition.ErrorCode); err != nil {
-				loaded.add(loadedOffset{
-					topic:     topic,
-					partition: partition,
-					err:       err,
-					request:   loadPart,
-				})
-				continue // partition err: handled in results
-			}
+			topicPartitions := topics.loadTopic(topic) // must be non-nil at this point
+			for _, rPartition := range rTopic.Partitions {
+				partition := rPartition.Partition
+				loadPart, ok := loadParts[partition]
+				if !ok {
+					continue // should not happen: kafka replied with something we did not ask for
+				}
 
-			if partition < 0 || partition >= int32(len(topicPartitions.partitions)) {
-				continue // should not happen: we have not seen this partition from a metadata response
-			}
-			topicPartition := topicPartitions.partitions[partition]
+				if err := kerr.ErrorForCode(rPartition.ErrorCode); err != nil {
+					loaded.add(loadedOffset{
+						topic:     topic,
+						partition: partition,
+						err:       err,
+						request:   loadPart,
+					})
+					continue // partition err: handled in results
+				}
 
-			delete(loadParts, partition)
-			if len(loadParts) == 0 {
-				delete(load, topic)
-			}
+				if partition < 0 || partition >= int32(len(topicPartitions.partitions)) {
+					continue // should not happen: we have not seen this partition from a metadata response
+				}
+				topicPartition := topicPartitions.partitions[partition]
 
-			offset := rPartition.Offset + loadPart.relative
-			if len(rPartition.OldStyleOffsets) > 0 { // if we have any, we used list offsets v0
-				offset = rPartition.OldStyleOffsets[0] + loadPart.relative
-			}
-			if loadPart.at >= 0 {
-				offset = loadPart.at + loadPart.relative // we obey exact requests, even if they end up past the end
-			}
-			if offset < 0 {
-				offset = 0
-			}
+				delete(loadParts, partition)
+				if len(loadParts) == 0 {
+					delete(load, topic)
+				}
 
-			loaded.add(loadedOffset{
-				topic:       topic,
-				partition:   partition,
-				cursor:      topicPartition.cursor,
-				offset:      offset,
-				leaderEpoch: rPartition.LeaderEpoch,
-				request:     loadPart,
-			})
+				if endPart, ok := loadPart.timestampFallback(&rPartition); ok {
+					fallback.add(topic, partition, endPart)
+					continue
+				}
+
+				offset := rPartition.Offset + loadPart.relative
+				if len(rPartition.OldStyleOffsets) > 0 { // if we have any, we used list offsets v0
+					offset = rPartition.OldStyleOffsets[0] + loadPart.relative
+				}
+				if loadPart.at >= 0 && !loadPart.afterMilli {
+					offset = loadPart.at + loadPart.relative // we obey exact requests, even if they end up past the end
+				}
+				if offset < 0 {
+					offset = 0
+				}
+
+				loaded.add(loadedOffset{
+					topic:       topic,
+					partition:   partition,
+					cursor:      topicPartition.cursor,
+					offset:      offset,
+					leaderEpoch: rPartition.LeaderEpoch,
+					request:     loadPart,
+				})
+			}
 		}
+
+		loaded.addAll(load.errToLoaded(kerr.UnknownTopicOrPartition))
+		load = fallback
 	}
 
-	results <- loaded.addAll(load.errToLoaded(kerr.UnknownTopicOrPartition))
+	results <- loaded
 }
 
 func (cl *Client) loadEpochsForBrokerLoad(ctx context.Context, broker *broker, load offsetLoadMap, tps *topicsPartitions, results chan<- loadedOffsets) {
@@ -1419,6 +2008,17 @@ func (cl *Client) loadEpochsForBrokerLoad(ctx context.Context, broker *broker, l
 	results <- loaded.addAll(load.errToLoaded(kerr.UnknownTopicOrPartition))
 }
 
+// timestampFallback returns the load to use if listing offsets for a
+// timestamp found no record at or after the timestamp: we list the end offset
+// instead, keeping any relative offset.
+func (l offsetLoad) timestampFallback(rPartition *kmsg.ListOffsetsResponseTopicPartition) (offsetLoad, bool) {
+	if !l.afterMilli || rPartition.Offset != -1 || len(rPartition.OldStyleOffsets) > 0 {
+		return l, false
+	}
+	l.Offset = l.Offset.AtEnd()
+	return l, true
+}
+
 func (o offsetLoadMap) buildListReq(isolationLevel int8) *kmsg.ListOffsetsRequest {
 	req := &kmsg.ListOffsetsRequest{
 		ReplicaID:      -1,
@@ -1432,14 +2032,16 @@ func (o offsetLoadMap) buildListReq(isolationLevel int8) *kmsg.ListOffsetsReques
 			// then we are listing for a partition that was not yet
 			// loaded by the client (due to metadata). We use -1
 			// just to ensure the partition is loaded.
+			//
+			// If this is a timestamp request, at is the timestamp.
 			timestamp := offset.at
-			if timestamp >= 0 {
+			if timestamp >= 0 && !offset.afterMilli {
 				timestamp = -1
 			}
 			parts = append(parts, kmsg.ListOffsetsRequestTopicPartition{
 				Partition:          partition,
 				CurrentLeaderEpoch: offset.currentEpoch, // KIP-320
-				Timestamp:          offset.at,
+				Timestamp:          timestamp,
 				MaxNumOffsets:      1,
 			})
 		}
@@ -1472,3 +2074,115 @@ func (o offsetLoadMap) buildEpochReq() *kmsg.OffsetForLeaderEpochRequest {
 	}
 	return req
 }
+
+// pausedTopics tracks topics and partitions that are paused from fetching.
+//
+// A topic can be paused entirely, or individual partitions within a topic can
+// be paused; the two are tracked separately so that resuming one does not
+// resume the other.
+type pausedTopics map[string]pausedPartitions
+
+type pausedPartitions struct {
+	all bool
+	m   map[int32]struct{}
+}
+
+func (p pausedTopics) has(topic string, partition int32) bool {
+	pps, exists := p[topic]
+	if !exists {
+		return false
+	}
+	if pps.all {
+		return true
+	}
+	_, exists = pps.m[partition]
+	return exists
+}
+
+func (p pausedTopics) addTopics(topics ...string) {
+	for _, topic := range topics {
+		pps := p[topic]
+		pps.all = true
+		p[topic] = pps
+	}
+}
+
+func (p pausedTopics) delTopics(topics ...string) {
+	for _, topic := range topics {
+		pps, exists := p[topic]
+		if !exists {
+			continue
+		}
+		pps.all = false
+		if len(pps.m) == 0 {
+			delete(p, topic)
+		} else {
+			p[topic] = pps
+		}
+	}
+}
+
+func (p pausedTopics) addPartitions(topicPartitions map[string][]int32) {
+	for topic, partitions := range topicPartitions {
+		pps := p[topic]
+		if pps.m == nil {
+			pps.m = make(map[int32]struct{}, len(partitions))
+		}
+		for _, partition := range partitions {
+			pps.m[partition] = struct{}{}
+		}
+		p[topic] = pps
+	}
+}
+
+func (p pausedTopics) delPartitions(topicPartitions map[string][]int32) {
+	for topic, partitions := range topicPartitions {
+		pps, exists := p[topic]
+		if !exists {
+			continue
+		}
+		for _, partition := range partitions {
+			delete(pps.m, partition)
+		}
+		if !pps.all && len(pps.m) == 0 {
+			delete(p, topic)
+		}
+	}
+}
+
+func (p pausedTopics) pausedTopics() []string {
+	var r []string
+	for topic, pps := range p {
+		if pps.all {
+			r = append(r, topic)
+		}
+	}
+	return r
+}
+
+func (p pausedTopics) pausedPartitions() map[string][]int32 {
+	r := make(map[string][]int32)
+	for topic, pps := range p {
+		if len(pps.m) == 0 {
+			continue
+		}
+		ps := make([]int32, 0, len(pps.m))
+		for partition := range pps.m {
+			ps = append(ps, partition)
+		}
+		r[topic] = ps
+	}
+	return r
+}
+
+func (p pausedTopics) clone() pausedTopics {
+	dup := make(pausedTopics, len(p))
+	for topic, pps := range p {
+		dupm := make(map[int32]struct{}, len(pps.m))
+		for partition := range pps.m {
+			dupm[partition] = struct{}{}
+		}
+		dup[topic] = pausedPartitions{all: pps.all, m: dupm}
+	}
+	return dup
+}
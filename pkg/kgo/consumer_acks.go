@@ -0,0 +1,149 @@
+package kgo
+
+import "sort"
+
+// ackPending is a polled record awaiting an ack.
+type ackPending struct {
+	offset int64
+	epoch  int32
+	acked  bool
+}
+
+// partitionAcks tracks polled records in a partition that have not yet been
+// contiguously acked, in offset order.
+type partitionAcks struct {
+	pending []ackPending
+
+	// hold, if held, is the first chunk of the earliest incomplete chunked
+	// record as of the latest poll. The head never advances past it, even
+	// if later records are acked.
+	hold EpochOffset
+	held bool
+}
+
+// groupAcks tracks acks for every partition we have polled, and is guarded by
+// the group mu.
+type groupAcks map[string]map[int32]*partitionAcks
+
+// AckRecords marks records as processed when consuming with AutoCommitAcked.
+// Records can be acked in any order; uncommitted offsets only advance through
+// the highest contiguously acked record in each partition. Records that were
+// not polled in the current group session are ignored, as are records if the
+// client is not consuming with AutoCommitAcked.
+func (cl *Client) AckRecords(rs ...*Record) {
+	g := cl.consumer.g
+	if g == nil || !g.cfg.autocommitAcked {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, r := range rs {
+		pa := g.acks[r.Topic][r.Partition]
+		if pa == nil {
+			continue
+		}
+		if head, advanced := pa.ack(r.Offset); advanced {
+			g.setUncommittedHead(r.Topic, r.Partition, head)
+		}
+	}
+}
+
+// setUncommittedHead, called under the group mu, moves the uncommitted head
+// for a partition.
+func (g *groupConsumer) setUncommittedHead(topic string, partition int32, head EpochOffset) {
+	if g.uncommitted == nil {
+		g.uncommitted = make(uncommitted, 10)
+	}
+	topicOffsets := g.uncommitted[topic]
+	if topicOffsets == nil {
+		topicOffsets = make(map[int32]uncommit, 20)
+		g.uncommitted[topic] = topicOffsets
+	}
+	uncommit := topicOffsets[partition]
+	uncommit.head = head
+	topicOffsets[partition] = uncommit
+}
+
+// polledAcks, called under the group mu, tracks every record in a polled
+// partition as awaiting an ack. Records dropped by interceptors are
+// considered acked. This returns the new contiguously acked head and whether
+// it advanced.
+func (g *groupConsumer) polledAcks(topic string, p *FetchPartition) (EpochOffset, bool) {
+	if g.acks == nil {
+		g.acks = make(groupAcks)
+	}
+	tacks := g.acks[topic]
+	if tacks == nil {
+		tacks = make(map[int32]*partitionAcks)
+		g.acks[topic] = tacks
+	}
+	pa := tacks[p.Partition]
+	if pa == nil {
+		pa = new(partitionAcks)
+		tacks[p.Partition] = pa
+	}
+
+	for _, r := range p.Records {
+		pa.pending = append(pa.pending, ackPending{offset: r.Offset, epoch: r.LeaderEpoch})
+	}
+	// Dropped records are pre-acked; if they are after an incomplete
+	// chunked record, the hold keeps the head from passing the record.
+	pa.hold, pa.held = p.chunkHold, p.chunkHeld
+	if p.droppedNext.Offset != 0 {
+		pa.pending = append(pa.pending, ackPending{
+			offset: p.droppedNext.Offset - 1,
+			epoch:  p.droppedNext.Epoch,
+			acked:  true,
+		})
+	}
+	return pa.advance()
+}
+
+// forgetAcks, called under the group mu, stops tracking acks for the given
+// partitions, or for everything if partitions is nil.
+func (g *groupConsumer) forgetAcks(partitions map[string][]int32) {
+	if partitions == nil {
+		g.acks = nil
+		return
+	}
+	for topic, ps := range partitions {
+		tacks := g.acks[topic]
+		for _, p := range ps {
+			delete(tacks, p)
+		}
+		if len(tacks) == 0 {
+			delete(g.acks, topic)
+		}
+	}
+}
+
+// ack marks an offset as acked, returning the new contiguously acked head and
+// whether it advanced.
+func (pa *partitionAcks) ack(offset int64) (EpochOffset, bool) {
+	i := sort.Search(len(pa.pending), func(i int) bool { return pa.pending[i].offset >= offset })
+	if i == len(pa.pending) || pa.pending[i].offset != offset {
+		return EpochOffset{}, false
+	}
+	pa.pending[i].acked = true
+	return pa.advance()
+}
+
+// advance drops all acked records at the front of pending that are before any
+// chunk hold, returning the offset just past the last dropped record.
+func (pa *partitionAcks) advance() (EpochOffset, bool) {
+	var (
+		head     EpochOffset
+		advanced bool
+	)
+	for len(pa.pending) > 0 && pa.pending[0].acked && (!pa.held || pa.pending[0].offset < pa.hold.Offset) {
+		head = EpochOffset{pa.pending[0].epoch, pa.pending[0].offset + 1}
+		advanced = true
+		pa.pending = pa.pending[1:]
+	}
+	if len(pa.pending) == 0 {
+		pa.pending = nil // allow the backing array to be collected
+	}
+	return head, advanced
+}
@@ -0,0 +1,128 @@
+package kgo
+
+import "testing"
+
+func TestAckContiguous(t *testing.T) {
+	cfg := defaultCfg()
+	cfg.autocommitAcked = true
+	g := &groupConsumer{cfg: &cfg}
+
+	var rs []*Record
+	for i := int64(0); i < 4; i++ {
+		rs = append(rs, &Record{Topic: "foo", Offset: i, LeaderEpoch: 2})
+	}
+	g.updateUncommitted(Fetches{{Topics: []FetchTopic{{
+		Topic: "foo",
+		Partitions: []FetchPartition{{
+			Records:     rs,
+			droppedNext: EpochOffset{2, 5}, // offset 4 was dropped by an interceptor
+		}},
+	}}}})
+	if uncommitted := g.getUncommitted(); uncommitted != nil {
+		t.Fatalf("got uncommitted %v before acking, expected nil", uncommitted)
+	}
+
+	ack := func(offset int64) {
+		if pa := g.acks["foo"][0]; pa != nil {
+			if head, advanced := pa.ack(offset); advanced {
+				g.setUncommittedHead("foo", 0, head)
+			}
+		}
+	}
+	for _, test := range []struct {
+		ack int64
+		exp int64
+	}{
+		{1, -1},
+		{3, -1},
+		{0, 2},
+		{2, 5}, // contiguous through the dropped offset 4
+	} {
+		ack(test.ack)
+		got := int64(-1)
+		if head, exists := g.getUncommitted()["foo"][0]; exists {
+			got = head.Offset
+		}
+		if got != test.exp {
+			t.Errorf("after acking %d, got uncommitted %d, expected %d", test.ack, got, test.exp)
+		}
+	}
+
+	if pa := g.acks["foo"][0]; len(pa.pending) != 0 {
+		t.Errorf("got %d pending after acking everything, expected 0", len(pa.pending))
+	}
+}
+
+func TestAckChunkHold(t *testing.T) {
+	cfg := defaultCfg()
+	cfg.autocommitAcked = true
+	g := &groupConsumer{cfg: &cfg}
+
+	poll := func(p FetchPartition) {
+		g.updateUncommitted(Fetches{{Topics: []FetchTopic{{Topic: "foo", Partitions: []FetchPartition{p}}}}})
+	}
+	head := func() int64 {
+		if head, exists := g.getUncommitted()["foo"][0]; exists {
+			return head.Offset
+		}
+		return -1
+	}
+	ack := func(offsets ...int64) {
+		for _, offset := range offsets {
+			if head, advanced := g.acks["foo"][0].ack(offset); advanced {
+				g.setUncommittedHead("foo", 0, head)
+			}
+		}
+	}
+
+	// Offset 1 is the first chunk of an incomplete split record; acking
+	// the later offsets 0 and 2 only advances us to the chunk.
+	poll(FetchPartition{
+		Records:   []*Record{{Topic: "foo", Offset: 0}, {Topic: "foo", Offset: 2}},
+		chunkHold: EpochOffset{-1, 1},
+		chunkHeld: true,
+	})
+	ack(0, 2)
+	if got := head(); got != 1 {
+		t.Errorf("got uncommitted %d with a chunk held at 1, expected 1", got)
+	}
+
+	// Once the split record completes at offset 4, acking it advances us
+	// past everything.
+	poll(FetchPartition{Records: []*Record{{Topic: "foo", Offset: 4}}})
+	if got := head(); got != 3 {
+		t.Errorf("got uncommitted %d after the hold was released, expected 3", got)
+	}
+	ack(4)
+	if got := head(); got != 5 {
+		t.Errorf("got uncommitted %d after acking the reassembled record, expected 5", got)
+	}
+}
+
+func TestMarkCommitRecords(t *testing.T) {
+	cfg := defaultCfg()
+	cfg.autocommitMarks = true
+	cl := &Client{cfg: cfg}
+	g := &groupConsumer{cl: cl, cfg: &cl.cfg}
+	cl.consumer.g = g
+
+	rs := []*Record{
+		{Topic: "foo", Offset: 0, LeaderEpoch: -1},
+		{Topic: "foo", Offset: 1, LeaderEpoch: -1},
+	}
+	g.updateUncommitted(Fetches{{Topics: []FetchTopic{{
+		Topic:      "foo",
+		Partitions: []FetchPartition{{Records: rs}},
+	}}}})
+	if uncommitted := cl.UncommittedOffsets(); uncommitted != nil {
+		t.Fatalf("got uncommitted %v before marking, expected nil", uncommitted)
+	}
+
+	cl.MarkCommitRecords(rs[1])
+	cl.MarkCommitRecords(rs[0]) // does not rewind
+	cl.MarkCommitRecords(&Record{Topic: "bar", Offset: 3})
+	exp := map[string]map[int32]EpochOffset{"foo": {0: {-1, 2}}}
+	if got := cl.UncommittedOffsets(); len(got) != 1 || got["foo"][0] != exp["foo"][0] {
+		t.Errorf("got uncommitted %v after marking, expected %v", got, exp)
+	}
+}
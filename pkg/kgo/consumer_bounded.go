@@ -0,0 +1,450 @@
+package kgo
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// boundedEnds tracks end offset snapshots when consuming with ConsumeBounded.
+//
+// Snapshots, the wake flag, and done partitions are written under the
+// consumer's sourcesReadyMu. Snapshots and done partitions are additionally
+// stored atomically so that sources can check them while building fetch
+// requests.
+//
+// Sources do not fetch until loaded is closed, which happens once the end
+// offsets of everything we were configured to consume have been snapshotted
+// with one up front ListOffsets (or once the client is closing). A partition
+// without a snapshot is never fetched; it is queued in missing and listed.
+type boundedEnds struct {
+	ends   atomic.Value  // boundedSnapshot
+	done   atomic.Value  // boundedDone
+	wake   bool          // set when partitions become done, to wake a waiting poll
+	loaded chan struct{} // closed once the initial snapshot is loaded
+
+	missingMu sync.Mutex
+	missing   map[string]map[int32]struct{} // partitions waiting to be snapshotted
+	listing   bool                          // whether we are listing missing partitions
+}
+
+type boundedSnapshot map[string]map[int32]int64 // topic => partition => snapshotted end offset
+
+type boundedDone map[string]map[int32]struct{}
+
+func (d boundedDone) has(topic string, partition int32) bool {
+	_, exists := d[topic][partition]
+	return exists
+}
+
+func newBoundedEnds() *boundedEnds {
+	b := &boundedEnds{
+		loaded: make(chan struct{}),
+	}
+	b.ends.Store(make(boundedSnapshot))
+	b.done.Store(make(boundedDone))
+	return b
+}
+
+func (b *boundedEnds) loadEnds() boundedSnapshot {
+	return b.ends.Load().(boundedSnapshot)
+}
+
+// storeBoundedEnds, called under the sourcesReadyMu, adds listed end offsets
+// to our snapshots. A partition that already has a snapshot keeps it.
+func (c *consumer) storeBoundedEnds(listed boundedSnapshot) {
+	b := c.bounded
+	old := b.loadEnds()
+	ends := make(boundedSnapshot, len(old)+len(listed))
+	for topic, partitions := range old {
+		ends[topic] = partitions // we only add to the new map, so we can share the old partition maps if unmodified
+	}
+	for topic, lps := range listed {
+		old := ends[topic]
+		partitions := make(map[int32]int64, len(old)+len(lps))
+		for p, end := range old {
+			partitions[p] = end
+		}
+		for p, end := range lps {
+			if _, exists := partitions[p]; !exists {
+				partitions[p] = end
+			}
+		}
+		ends[topic] = partitions
+	}
+	b.ends.Store(ends)
+}
+
+// waitBoundedEnds, called before every fetch, waits until the initial end
+// offset snapshot is loaded. This returns false if ctx is done first.
+func (c *consumer) waitBoundedEnds(ctx context.Context) bool {
+	if c.bounded == nil {
+		return true
+	}
+	select {
+	case <-c.bounded.loaded:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// loadBoundedEnds, run in a goroutine when the consumer is initialized,
+// snapshots the end offsets of every partition of the topics and partitions
+// we were configured with, retrying until it succeeds or the client closes.
+//
+// Partitions that cannot be listed (or that are added later) are queued for
+// listing when a source first tries to fetch them; see boundedSnapshotted.
+func (c *consumer) loadBoundedEnds(regex bool, topics map[string]*regexp.Regexp, partitions map[string][]int32) {
+	cl := c.cl
+	b := c.bounded
+	defer close(b.loaded)
+
+	for tries := 0; ; tries++ {
+		ends, err := c.listBoundedEnds(regex, topics, partitions)
+		if err == nil {
+			c.sourcesReadyMu.Lock()
+			c.storeBoundedEnds(ends)
+			c.sourcesReadyMu.Unlock()
+			return
+		}
+
+		cl.cfg.logger.Log(LogLevelWarn, "unable to list end offsets to snapshot for bounded consuming, retrying", "err", err)
+		timer := time.NewTimer(cl.cfg.retryBackoff(tries))
+		select {
+		case <-cl.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// boundedSnapshotted, called by sources while building fetch requests,
+// returns whether a cursor's partition has an end offset snapshot when
+// consuming bounded. If not, the partition is queued to be listed and must
+// not be fetched: snapshotting from a fetch response instead would never
+// happen for a partition that an incremental fetch response leaves out.
+func (c *consumer) boundedSnapshotted(cursor *cursor) bool {
+	b := c.bounded
+	if b == nil {
+		return true
+	}
+	if _, exists := b.loadEnds()[cursor.topic][cursor.partition]; exists {
+		return true
+	}
+
+	b.missingMu.Lock()
+	defer b.missingMu.Unlock()
+	if b.missing == nil {
+		b.missing = make(map[string]map[int32]struct{})
+	}
+	partitions := b.missing[cursor.topic]
+	if partitions == nil {
+		partitions = make(map[int32]struct{})
+		b.missing[cursor.topic] = partitions
+	}
+	partitions[cursor.partition] = struct{}{}
+	if !b.listing {
+		b.listing = true
+		go c.loadMissingBoundedEnds()
+	}
+	return false
+}
+
+// loadMissingBoundedEnds, run in a goroutine while any partition is waiting
+// to be snapshotted, lists the end offsets of missing partitions, retrying
+// until every partition is snapshotted or the client closes. Sources are
+// woken to fetch the partitions once they are snapshotted.
+func (c *consumer) loadMissingBoundedEnds() {
+	cl := c.cl
+	b := c.bounded
+
+	for tries := 0; ; {
+		b.missingMu.Lock()
+		if len(b.missing) == 0 {
+			b.listing = false
+			b.missingMu.Unlock()
+			return
+		}
+		want := make(map[string][]int32, len(b.missing))
+		for topic, partitions := range b.missing {
+			for partition := range partitions {
+				want[topic] = append(want[topic], partition)
+			}
+		}
+		b.missingMu.Unlock()
+
+		ends, err := c.listBoundedEnds(false, nil, want)
+		if err == nil {
+			c.sourcesReadyMu.Lock()
+			c.storeBoundedEnds(ends)
+			c.sourcesReadyMu.Unlock()
+
+			cl.sinksAndSourcesMu.Lock()
+			for _, sns := range cl.sinksAndSources {
+				sns.source.maybeConsume()
+			}
+			cl.sinksAndSourcesMu.Unlock()
+		}
+
+		var missed bool
+		b.missingMu.Lock()
+		for topic, partitions := range want {
+			for _, partition := range partitions {
+				if _, exists := ends[topic][partition]; exists {
+					delete(b.missing[topic], partition)
+				} else {
+					missed = true
+				}
+			}
+			if len(b.missing[topic]) == 0 {
+				delete(b.missing, topic)
+			}
+		}
+		b.missingMu.Unlock()
+
+		if !missed {
+			tries = 0
+			continue
+		}
+
+		tries++
+		if err != nil {
+			cl.cfg.logger.Log(LogLevelWarn, "unable to list end offsets to snapshot for bounded consuming, retrying", "err", err)
+		}
+		timer := time.NewTimer(cl.cfg.retryBackoff(tries))
+		select {
+		case <-cl.ctx.Done():
+			timer.Stop()
+			b.missingMu.Lock()
+			b.listing = false
+			b.missingMu.Unlock()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *consumer) listBoundedEnds(regex bool, topics map[string]*regexp.Regexp, partitions map[string][]int32) (boundedSnapshot, error) {
+	cl := c.cl
+
+	want := make(map[string][]int32, len(partitions))
+	for topic, ps := range partitions {
+		want[topic] = append(want[topic], ps...)
+	}
+	if len(topics) > 0 {
+		var metaTopics []string
+		if !regex {
+			for topic := range topics {
+				metaTopics = append(metaTopics, topic)
+			}
+		}
+		_, meta, err := cl.fetchMetadataForTopics(cl.ctx, regex, metaTopics)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range meta.Topics {
+			if t.ErrorCode != 0 {
+				continue // snapshotted once fetched, if it ever exists
+			}
+			if regex {
+				if t.IsInternal {
+					continue
+				}
+				var matches bool
+				for _, re := range topics {
+					if matches = re.MatchString(t.Topic); matches {
+						break
+					}
+				}
+				if !matches {
+					continue
+				}
+			}
+			for _, p := range t.Partitions {
+				want[t.Topic] = append(want[t.Topic], p.Partition)
+			}
+		}
+	}
+
+	ends := make(boundedSnapshot, len(want))
+	if len(want) == 0 {
+		return ends, nil
+	}
+
+	req := kmsg.NewPtrListOffsetsRequest()
+	req.ReplicaID = -1
+	req.IsolationLevel = cl.cfg.isolationLevel
+	for topic, ps := range want {
+		t := kmsg.NewListOffsetsRequestTopic()
+		t.Topic = topic
+		for _, partition := range ps {
+			p := kmsg.NewListOffsetsRequestTopicPartition()
+			p.Partition = partition
+			p.Timestamp = -1 // end offset: the high watermark, or last stable offset if reading committed
+			t.Partitions = append(t.Partitions, p)
+		}
+		req.Topics = append(req.Topics, t)
+	}
+
+	for _, shard := range cl.RequestSharded(cl.ctx, req) {
+		if shard.Err != nil {
+			return nil, shard.Err
+		}
+		resp := shard.Resp.(*kmsg.ListOffsetsResponse)
+		for _, t := range resp.Topics {
+			for _, p := range t.Partitions {
+				if p.ErrorCode != 0 {
+					continue // retried once fetched
+				}
+				end := p.Offset
+				if resp.Version == 0 {
+					if len(p.OldStyleOffsets) == 0 {
+						continue
+					}
+					end = p.OldStyleOffsets[0]
+				}
+				tends := ends[t.Topic]
+				if tends == nil {
+					tends = make(map[int32]int64)
+					ends[t.Topic] = tends
+				}
+				tends[p.Partition] = end
+			}
+		}
+	}
+	return ends, nil
+}
+
+// loadDone returns the partitions that have reached their snapshot, or nil
+// if we are not consuming bounded.
+func (b *boundedEnds) loadDone() boundedDone {
+	if b == nil {
+		return nil
+	}
+	return b.done.Load().(boundedDone)
+}
+
+// trimBounded is called in a fetch after processing a response but before
+// buffering the fetch. This drops any records at or past each partition's
+// snapshot and returns the used offsets that have reached their snapshot.
+// Sources only fetch partitions that have a snapshot.
+//
+// The returned offsets must be marked done with markBoundedDone only *after*
+// the fetch is buffered, so that polling can never see a partition as done
+// before its final records are available to be drained.
+func (c *consumer) trimBounded(f *Fetch, used usedOffsets) []*cursorOffsetNext {
+	ends := c.bounded.loadEnds()
+
+	for i := range f.Topics {
+		t := &f.Topics[i]
+		for j := range t.Partitions {
+			p := &t.Partitions[j]
+
+			end, exists := ends[t.Topic][p.Partition]
+			if !exists {
+				continue // unreachable; we do not fetch partitions without a snapshot
+			}
+
+			// Records are in offset order; we drop everything at
+			// or past our snapshot.
+			for k, r := range p.Records {
+				if r.Offset >= end {
+					p.Records = p.Records[:k]
+					break
+				}
+			}
+			if p.droppedNext.Offset > end {
+				p.droppedNext = EpochOffset{}
+			}
+		}
+	}
+
+	var done []*cursorOffsetNext
+	used.eachOffset(func(o *cursorOffsetNext) {
+		if end, exists := ends[o.from.topic][o.from.partition]; exists && o.offset >= end {
+			done = append(done, o)
+		}
+	})
+	return done
+}
+
+// markBoundedDone stops the given partitions from being fetched again and
+// wakes any poll that is waiting so that the poll can check if everything is
+// done.
+func (c *consumer) markBoundedDone(done []*cursorOffsetNext) {
+	b := c.bounded
+
+	c.sourcesReadyMu.Lock()
+	old := b.loadDone()
+	newDone := make(boundedDone, len(old))
+	for topic, partitions := range old {
+		newDone[topic] = partitions // we only add to the new map, so we can share the old partition maps if unmodified
+	}
+	for _, o := range done {
+		topic, partition := o.from.topic, o.from.partition
+		if newDone.has(topic, partition) {
+			continue
+		}
+		old := newDone[topic]
+		partitions := make(map[int32]struct{}, len(old)+1)
+		for p := range old {
+			partitions[p] = struct{}{}
+		}
+		partitions[partition] = struct{}{}
+		newDone[topic] = partitions
+	}
+	b.done.Store(newDone)
+	b.wake = true
+	c.sourcesReadyMu.Unlock()
+
+	c.sourcesReadyCond.Broadcast()
+}
+
+// boundedUsing, called in polling under the consumer mu, returns all cursors
+// currently being consumed. If nothing is being consumed, or if any partition
+// is still listing offsets or loading epochs, this returns nil: we cannot be
+// done if we do not yet know where we are consuming from.
+func (c *consumer) boundedUsing() []*cursor {
+	session := c.loadSession()
+	session.listOrEpochMu.Lock()
+	defer session.listOrEpochMu.Unlock()
+
+	if !session.listOrEpochLoadsWaiting.isEmpty() || !session.listOrEpochLoadsLoading.isEmpty() {
+		return nil
+	}
+	using := make([]*cursor, 0, len(c.usingCursors))
+	for cursor := range c.usingCursors {
+		using = append(using, cursor)
+	}
+	return using
+}
+
+// boundedAllDone, called in polling under the sourcesReadyMu, returns whether
+// every cursor being consumed has reached its snapshot. This also clears the
+// wake flag, since the poll that was woken is now checking.
+func (c *consumer) boundedAllDone(using []*cursor) bool {
+	b := c.bounded
+	b.wake = false
+	if len(using) == 0 || len(c.sourcesReadyForDraining) > 0 {
+		return false
+	}
+	done := b.loadDone()
+	for _, cursor := range using {
+		if !done.has(cursor.topic, cursor.partition) {
+			return false
+		}
+	}
+	return true
+}
+
+// boundedShouldWake, called under the sourcesReadyMu, returns whether a
+// waiting poll should wake up to check if everything is done.
+func (c *consumer) boundedShouldWake() bool {
+	return c.bounded != nil && c.bounded.wake
+}
@@ -0,0 +1,127 @@
+package kgo
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestTrimBounded(t *testing.T) {
+	cl := &Client{cfg: defaultCfg()}
+	c := &cl.consumer
+	c.cl = cl
+	c.sourcesReadyCond = sync.NewCond(&c.sourcesReadyMu)
+	c.bounded = newBoundedEnds()
+	c.storeBoundedEnds(boundedSnapshot{"foo": {0: 2}})
+
+	cur := &cursor{topic: "foo", partition: 0}
+	used := usedOffsets{"foo": {0: &cursorOffsetNext{
+		cursorOffset: cursorOffset{offset: 3},
+		from:         cur,
+	}}}
+	fetch := Fetch{Topics: []FetchTopic{{
+		Topic: "foo",
+		Partitions: []FetchPartition{{
+			Partition:     0,
+			HighWatermark: 2,
+			Records:       []*Record{{Offset: 0}, {Offset: 1}, {Offset: 2}},
+		}},
+	}}}
+
+	done := c.trimBounded(&fetch, used)
+	if got := len(fetch.Topics[0].Partitions[0].Records); got != 2 {
+		t.Errorf("got %d records after trimming, expected 2", got)
+	}
+	if len(done) != 1 || done[0].from != cur {
+		t.Fatalf("expected the cursor to be done, got %v", done)
+	}
+
+	// A fetch with a higher watermark does not move our snapshot.
+	fetch.Topics[0].Partitions[0].HighWatermark = 10
+	fetch.Topics[0].Partitions[0].Records = []*Record{{Offset: 1}, {Offset: 5}}
+	c.trimBounded(&fetch, used)
+	if got := len(fetch.Topics[0].Partitions[0].Records); got != 1 {
+		t.Errorf("got %d records after trimming with a new watermark, expected 1", got)
+	}
+
+	if c.boundedAllDone([]*cursor{cur}) {
+		t.Error("unexpectedly all done before marking")
+	}
+	c.markBoundedDone(done)
+	if !c.boundedShouldWake() {
+		t.Error("expected marking done to wake polling")
+	}
+	if !c.boundedAllDone([]*cursor{cur}) {
+		t.Error("expected all done after marking")
+	}
+	if c.boundedShouldWake() {
+		t.Error("expected checking done to clear the wake")
+	}
+}
+
+func TestBoundedCreateReq(t *testing.T) {
+	cl, _ := NewClient(ConsumeBounded())
+	defer cl.Close()
+	c := &cl.consumer
+	<-c.bounded.loaded
+
+	c.sourcesReadyMu.Lock()
+	c.storeBoundedEnds(boundedSnapshot{"foo": {0: 10, 1: 10}})
+	c.sourcesReadyMu.Unlock()
+
+	// Partition 0 is done, 1 is fetchable, and 2 has no snapshot. Both 0
+	// and 1 are in our fetch session.
+	s := cl.newSource(0)
+	var cursors []*cursor
+	for p := int32(0); p < 3; p++ {
+		cur := &cursor{topic: "foo", partition: p, source: s, useState: 1, cursorsIdx: int(p)}
+		cur.leaderEpoch = -1
+		cur.cursorOffset = cursorOffset{offset: 5, lastConsumedEpoch: -1}
+		cursors = append(cursors, cur)
+	}
+	s.cursors = cursors
+	s.session = fetchSession{id: 1, epoch: 2, used: map[string]map[int32]fetchSessionOffsetEpoch{
+		"foo": {0: {5, -1}, 1: {5, -1}},
+	}}
+	c.markBoundedDone([]*cursorOffsetNext{{from: cursors[0]}})
+
+	req := s.createReq()
+	if _, exists := req.usedOffsets["foo"][1]; !exists || req.numOffsets != 1 {
+		t.Errorf("got used offsets %v, expected only partition 1", req.usedOffsets)
+	}
+	if forgotten := req.forgotten["foo"]; len(forgotten) != 1 || forgotten[0] != 0 {
+		t.Errorf("got forgotten %v, expected the done partition 0", req.forgotten)
+	}
+
+	c.bounded.missingMu.Lock()
+	_, missing := c.bounded.missing["foo"][2]
+	c.bounded.missingMu.Unlock()
+	if !missing {
+		t.Error("partition 2 without a snapshot was not queued to be listed")
+	}
+
+	req.SetVersion(12)
+	var sent kmsg.FetchRequest
+	sent.Version = 12
+	if err := sent.ReadFrom(req.AppendTo(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent.Topics) != 0 {
+		t.Errorf("got fetched topics %v, expected none since partition 1 is unchanged in the session", sent.Topics)
+	}
+	if len(sent.ForgottenTopics) != 1 || sent.ForgottenTopics[0].Topic != "foo" || len(sent.ForgottenTopics[0].Partitions) != 1 || sent.ForgottenTopics[0].Partitions[0] != 0 {
+		t.Errorf("got forgotten topics %v, expected foo partition 0", sent.ForgottenTopics)
+	}
+
+	// Once forgotten, the done partition is not in the session, so it is
+	// neither fetched nor forgotten again.
+	req.usedOffsets.finishUsingAll()
+	req = s.createReq()
+	if len(req.forgotten) != 0 {
+		t.Errorf("got forgotten %v, expected nothing new to forget", req.forgotten)
+	}
+	if _, exists := req.usedOffsets["foo"][0]; exists {
+		t.Error("done partition 0 was fetched again")
+	}
+}
@@ -0,0 +1,78 @@
+package kgo
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// recordBufferedBytes returns the number of bytes a record takes up in our
+// fetch buffer, which is the size of its key, value, and headers.
+func recordBufferedBytes(r *Record) int64 {
+	n := len(r.Key) + len(r.Value)
+	for _, h := range r.Headers {
+		n += len(h.Key) + len(h.Value)
+	}
+	return int64(n)
+}
+
+// BufferedFetchBytes returns the number of bytes currently buffered from
+// fetching within the client. This is the sum of all keys, values, and
+// headers of records buffered, and is the number that is compared against
+// MaxBufferedFetchBytes.
+//
+// This is similar to BufferedFetchRecords, and the same caveats about spikes
+// apply.
+func (cl *Client) BufferedFetchBytes() int64 {
+	return atomic.LoadInt64(&cl.consumer.bufferedBytes)
+}
+
+// addBufferedBytes tracks bytes being buffered (positive) or unbuffered
+// (negative). If we are unbuffering and now are below our limit, we wake any
+// source waiting to fetch.
+func (c *consumer) addBufferedBytes(n int64) {
+	now := atomic.AddInt64(&c.bufferedBytes, n)
+	max := c.cl.cfg.maxBufferedFetchBytes
+	if n >= 0 || max == 0 || now >= max {
+		return
+	}
+
+	c.bufferedBytesMu.Lock()
+	defer c.bufferedBytesMu.Unlock()
+	if c.bufferedBytesDrained != nil {
+		close(c.bufferedBytesDrained)
+		c.bufferedBytesDrained = nil
+	}
+}
+
+// waitBufferedBytes waits until we are buffering fewer bytes than our limit,
+// returning false if the context is canceled first.
+//
+// This is a soft limit: once we are under the limit, any number of sources can
+// issue a fetch, and each fetch can bring us over the limit by up to
+// FetchMaxBytes.
+func (c *consumer) waitBufferedBytes(ctx context.Context) bool {
+	max := c.cl.cfg.maxBufferedFetchBytes
+	if max == 0 {
+		return true
+	}
+	for {
+		// We check under the mu to ensure we do not miss a close
+		// between our check and our wait.
+		c.bufferedBytesMu.Lock()
+		if atomic.LoadInt64(&c.bufferedBytes) < max {
+			c.bufferedBytesMu.Unlock()
+			return true
+		}
+		if c.bufferedBytesDrained == nil {
+			c.bufferedBytesDrained = make(chan struct{})
+		}
+		drained := c.bufferedBytesDrained
+		c.bufferedBytesMu.Unlock()
+
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package kgo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWaitBufferedBytes(t *testing.T) {
+	cfg := defaultCfg()
+	cfg.maxBufferedFetchBytes = 10
+	cl := &Client{cfg: cfg}
+	c := &cl.consumer
+	c.cl = cl
+
+	ctx := context.Background()
+	if !c.waitBufferedBytes(ctx) {
+		t.Fatal("unexpected wait failure while under the limit")
+	}
+
+	c.addBufferedBytes(recordBufferedBytes(&Record{
+		Key:     []byte("key"),
+		Value:   []byte("value"),
+		Headers: []RecordHeader{{Key: "h", Value: []byte("v")}},
+	}))
+	if got := cl.BufferedFetchBytes(); got != 10 {
+		t.Fatalf("got %d buffered bytes, expected 10", got)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if c.waitBufferedBytes(canceled) {
+		t.Error("unexpected wait success while at the limit with a canceled context")
+	}
+
+	done := make(chan bool)
+	go func() { done <- c.waitBufferedBytes(ctx) }()
+	time.Sleep(10 * time.Millisecond)
+	c.addBufferedBytes(-1)
+
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Error("unexpected wait failure after draining")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("wait was not woken after draining below the limit")
+	}
+}
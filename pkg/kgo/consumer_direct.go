@@ -4,7 +4,7 @@ type directConsumer struct {
 	cfg    *cfg
 	tps    *topicsPartitions             // data for topics that the user assigned
 	reSeen map[string]bool               // topics we evaluated against regex, and whether we want them or not
-	using  map[string]map[int32]struct{} // topics we are currently using (this only grows)
+	using  map[string]map[int32]struct{} // topics we are currently using (this only shrinks when removing topics or partitions)
 }
 
 func (c *consumer) initDirect() {
@@ -117,3 +117,135 @@ func (d *directConsumer) findNewAssignments() map[string]map[int32]Offset {
 
 	return toUse
 }
+
+// addTopics, called under the consumer mu, begins tracking topics that were
+// added with AddConsumeTopics. The next metadata update will assign them.
+func (d *directConsumer) addTopics(topics []string) {
+	if d.cfg.regex {
+		forgetUnmatchedRegex(d.reSeen)
+		return
+	}
+	d.tps.storeTopics(topics)
+}
+
+// removeTopics, called under the consumer mu after cfg.topics has been
+// updated, stops using the given topics and returns all partitions that must
+// be invalidated.
+func (d *directConsumer) removeTopics(topics []string) map[string]map[int32]Offset {
+	if d.cfg.regex {
+		topics = reevaluateRegex(d.reSeen, d.cfg.topics)
+	}
+
+	removed := make(map[string]map[int32]Offset, len(topics))
+	for _, topic := range topics {
+		partitions, exists := d.using[topic]
+		if !exists {
+			continue
+		}
+		delete(d.using, topic)
+
+		removedPartitions := make(map[int32]Offset, len(partitions))
+		for partition := range partitions {
+			removedPartitions[partition] = Offset{}
+		}
+		removed[topic] = removedPartitions
+	}
+	return removed
+}
+
+// removePartitions, called under the consumer mu before cfg.partitions is
+// updated, stops using the given partitions and returns all partitions that
+// must be invalidated.
+//
+// We only remove partitions that were directly consumed; if the partition's
+// entire topic is being consumed, we keep it.
+func (d *directConsumer) removePartitions(partitions map[string][]int32) map[string]map[int32]Offset {
+	removed := make(map[string]map[int32]Offset, len(partitions))
+	for topic, rmPartitions := range partitions {
+		if _, consumingTopic := d.cfg.topics[topic]; consumingTopic {
+			continue
+		}
+		pinned := d.cfg.partitions[topic]
+		using := d.using[topic]
+
+		removedPartitions := make(map[int32]Offset, len(rmPartitions))
+		for _, partition := range rmPartitions {
+			if _, exists := pinned[partition]; !exists {
+				continue
+			}
+			if _, exists := using[partition]; !exists {
+				continue
+			}
+			delete(using, partition)
+			removedPartitions[partition] = Offset{}
+		}
+		if len(using) == 0 {
+			delete(d.using, topic)
+		}
+		if len(removedPartitions) > 0 {
+			removed[topic] = removedPartitions
+		}
+	}
+	return removed
+}
+
+// SeekPartitions, for direct (non-group) consumers, resets the given
+// partitions to consume from the given offsets. Each offset can be exact
+// (NewOffset().At), relative (Relative), or the start or end of a partition
+// (AtStart, AtEnd). Offsets that specify an epoch go through truncation
+// detection as usual.
+//
+// Only partitions that are currently being consumed are seeked; anything else
+// is skipped. Seeking invalidates any in flight fetch and drops all buffered
+// fetches, so polling after this function returns never returns records from
+// before the seek for the seeked partitions.
+//
+// This function does nothing if the client is consuming as a group; for
+// groups, use SetOffsets.
+func (cl *Client) SeekPartitions(seeks map[string]map[int32]Offset) {
+	if len(seeks) == 0 {
+		return
+	}
+
+	c := &cl.consumer
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d := c.d
+	if d == nil {
+		return
+	}
+
+	var assigns map[string]map[int32]Offset
+	for topic, partitions := range seeks {
+		using := d.using[topic]
+		var topicAssigns map[int32]Offset
+		for partition, offset := range partitions {
+			if _, exists := using[partition]; !exists {
+				continue
+			}
+			if topicAssigns == nil {
+				topicAssigns = make(map[int32]Offset, len(partitions))
+			}
+			topicAssigns[partition] = offset
+		}
+		if len(topicAssigns) > 0 {
+			if assigns == nil {
+				assigns = make(map[string]map[int32]Offset, len(seeks))
+			}
+			assigns[topic] = topicAssigns
+		}
+	}
+
+	if len(assigns) == 0 {
+		return
+	}
+
+	// We first invalidate what we are seeking, which stops the session,
+	// drops buffered fetches, and drops any list or epoch loads that are
+	// in progress for these partitions. We then assign the partitions
+	// anew, which either sets exact offsets directly or begins listing /
+	// epoch loading.
+	c.assignPartitions(assigns, assignInvalidateMatching, d.tps)
+	c.assignPartitions(assigns, assignWithoutInvalidating, d.tps)
+}
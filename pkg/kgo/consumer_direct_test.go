@@ -0,0 +1,44 @@
+package kgo
+
+import "testing"
+
+func TestSeekPartitions(t *testing.T) {
+	cl, _ := NewClient()
+	defer cl.Close()
+
+	s := cl.newSource(0)
+	tp := newTopicPartitions()
+	data := new(topicPartitionsData)
+	for p := int32(0); p < 3; p++ {
+		cur := &cursor{topic: "foo", partition: p, source: s, cursorsIdx: int(p)}
+		cur.setOffset(cursorOffset{offset: 20, lastConsumedEpoch: -1})
+		s.cursors = append(s.cursors, cur)
+		data.partitions = append(data.partitions, &topicPartition{
+			topicPartitionData: topicPartitionData{leaderEpoch: 1},
+			cursor:             cur,
+		})
+	}
+	tp.v.Store(data)
+
+	c := &cl.consumer
+	c.mu.Lock()
+	c.d.tps.storeData(topicsPartitionsData{"foo": tp})
+	c.d.using = map[string]map[int32]struct{}{"foo": {0: {}, 1: {}}}
+	c.mu.Unlock()
+
+	// Relative offsets apply to exact offsets and are clamped at the
+	// start; partitions we are not consuming are skipped.
+	cl.SeekPartitions(map[string]map[int32]Offset{"foo": {
+		0: NewOffset().At(10).Relative(-3),
+		1: NewOffset().At(2).Relative(-5),
+		2: NewOffset().At(10),
+	}})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for p, exp := range []int64{7, 0, 20} {
+		if got := data.partitions[p].cursor.offset; got != exp {
+			t.Errorf("got foo p%d at offset %d after seeking, expected %d", p, got, exp)
+		}
+	}
+}
@@ -73,9 +73,14 @@ type groupConsumer struct {
 	// partitions). Only the leader can trigger a new group session if there
 	// are simply more partitions for existing topics.
 	//
-	// This is read when joining a group or leaving a group.
+	// This is read when joining a group or leaving a group. Topics are
+	// removed from this if the user stops consuming them.
 	using map[string]int // topics *we* are currently using => # partitions known in that topic
 
+	// managing is set once the manage goroutine is started. We cannot
+	// rely on using being non-empty, since a user can remove all topics.
+	managing bool
+
 	// uncommitted is read and updated all over:
 	// - updated before PollFetches returns
 	// - updated when directly setting offsets (to rewind, for transactions)
@@ -86,6 +91,10 @@ type groupConsumer struct {
 	// - read when getting uncommitted or committed
 	uncommitted uncommitted
 
+	// acks tracks records awaiting acks if using AutoCommitAcked. This is
+	// cleared and pruned alongside uncommitted.
+	acks groupAcks
+
 	// memberID and generation are written to in the join and sync loop,
 	// and mostly read within that loop. The reason these two are under the
 	// mutex is because they are read during commits, which can happen at
@@ -251,6 +260,7 @@ func (g *groupConsumer) manage() {
 			g.mu.Lock()     // before allowing poll to touch uncommitted, lock the group
 			g.c.mu.Unlock() // now part of poll can continue
 			g.uncommitted = nil
+			g.forgetAcks(nil)
 			g.mu.Unlock()
 
 			g.nowAssigned = nil
@@ -286,12 +296,12 @@ func (g *groupConsumer) manage() {
 }
 
 func (g *groupConsumer) leave() (wait func()) {
-	// If g.using is nonzero before this check, then a manage goroutine has
-	// started. If not, it will never start because we set dying.
+	// If g.managing is true before this check, then a manage goroutine
+	// has started. If not, it will never start because we set dying.
 	g.mu.Lock()
 	wasDead := g.dying
 	g.dying = true
-	wasManaging := len(g.using) > 0
+	wasManaging := g.managing
 	g.mu.Unlock()
 
 	done := make(chan struct{})
@@ -405,8 +415,8 @@ const (
 //     (1) if revoking lost partitions from a prior session (i.e., after sync),
 //         this revokes the passed in lost
 //     (2) if revoking at the end of a session, this revokes topics that the
-//         consumer is no longer interested in consuming (TODO, actually, only
-//         once we allow subscriptions to change without leaving the group).
+//         consumer is no longer interested in consuming (topics removed with
+//         RemoveConsumeTopics).
 //
 // Lastly, for cooperative consumers, this must selectively delete what was
 // lost from the uncommitted map.
@@ -435,6 +445,7 @@ func (g *groupConsumer) revoke(stage revokeStage, lost map[string][]int32, leavi
 		// to do that outside the context of a live group session.
 		g.mu.Lock()
 		g.uncommitted = nil
+		g.forgetAcks(nil)
 		g.mu.Unlock()
 		return
 	}
@@ -447,9 +458,20 @@ func (g *groupConsumer) revoke(stage revokeStage, lost map[string][]int32, leavi
 		// lost is nil for cooperative assigning. Instead, we determine
 		// lost by finding subscriptions we are no longer interested in.
 		//
-		// TODO only relevant when we allow reassigning with the same
-		// group to change subscriptions (also we must delete the
-		// unused partitions from nowAssigned).
+		// We also delete what we lost from nowAssigned, so that our
+		// next join does not claim we still own these partitions.
+		g.mu.Lock()
+		for topic, partitions := range g.nowAssigned {
+			if _, exists := g.using[topic]; exists {
+				continue
+			}
+			if lost == nil {
+				lost = make(map[string][]int32)
+			}
+			lost[topic] = partitions
+			delete(g.nowAssigned, topic)
+		}
+		g.mu.Unlock()
 	}
 
 	if len(lost) > 0 {
@@ -502,6 +524,7 @@ func (g *groupConsumer) revoke(stage revokeStage, lost map[string][]int32, leavi
 	// commit.
 	g.mu.Lock()
 	defer g.mu.Unlock()
+	g.forgetAcks(lost)
 	if g.uncommitted == nil {
 		return
 	}
@@ -1041,6 +1064,77 @@ func (g *groupConsumer) joinGroupProtocols() []kmsg.JoinGroupRequestProtocol {
 // fetchOffsets is issued once we join a group to see what the prior commits
 // were for the partitions we were assigned.
 func (g *groupConsumer) fetchOffsets(ctx context.Context, newAssigned map[string][]int32) error {
+	var (
+		offsets map[string]map[int32]Offset
+		err     error
+	)
+	if g.cfg.offsetStore != nil {
+		offsets, err = g.loadStoredOffsets(ctx, newAssigned)
+	} else {
+		offsets, err = g.fetchCommittedOffsets(ctx, newAssigned)
+	}
+	if err != nil {
+		return err
+	}
+
+	groupTopics := g.tps.load()
+	for fetchedTopic := range offsets {
+		if !groupTopics.hasTopic(fetchedTopic) {
+			delete(offsets, fetchedTopic)
+			g.cfg.logger.Log(LogLevelWarn, "member was assigned topic that we did not ask for in ConsumeTopics! skipping assigning this topic!", "group", g.cfg.group, "topic", fetchedTopic)
+		}
+	}
+
+	// Lock for assign and then updating uncommitted.
+	g.c.mu.Lock()
+	defer g.c.mu.Unlock()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// Eager: we already invalidated everything; nothing to re-invalidate.
+	// Cooperative: assign without invalidating what we are consuming.
+	g.c.assignPartitions(offsets, assignWithoutInvalidating, g.tps)
+
+	// We need to update the uncommited map so that SetOffsets(Committed)
+	// does not rewind before the committed offsets we just fetched.
+	if g.uncommitted == nil {
+		g.uncommitted = make(uncommitted, 10)
+	}
+	for topic, partitions := range offsets {
+		topicUncommitted := g.uncommitted[topic]
+		if topicUncommitted == nil {
+			topicUncommitted = make(map[int32]uncommit, 20)
+			g.uncommitted[topic] = topicUncommitted
+		}
+		for partition, offset := range partitions {
+			if offset.at < 0 || offset.afterMilli {
+				continue // not yet committed
+			}
+			committed := EpochOffset{
+				Epoch:  offset.epoch,
+				Offset: offset.at,
+			}
+			if tacks := g.acks[topic]; tacks != nil {
+				delete(tacks, partition)
+			}
+			topicUncommitted[partition] = uncommit{
+				head:      committed,
+				committed: committed,
+			}
+		}
+	}
+
+	if g.cfg.logger.Level() >= LogLevelDebug {
+		g.cfg.logger.Log(LogLevelDebug, "fetched committed offsets", "group", g.cfg.group, "fetched", offsets)
+	} else {
+		g.cfg.logger.Log(LogLevelInfo, "fetched committed offsets", "group", g.cfg.group)
+	}
+	return nil
+}
+
+// fetchCommittedOffsets issues an OffsetFetch for newly assigned partitions,
+// returning the offsets to begin consuming at.
+func (g *groupConsumer) fetchCommittedOffsets(ctx context.Context, newAssigned map[string][]int32) (map[string]map[int32]Offset, error) {
 	// Our client maps the v0 to v7 format to v8+ when sharding this
 	// request, if we are only requesting one group, as well as maps the
 	// response back, so we do not need to worry about v8+ here.
@@ -1070,11 +1164,11 @@ start:
 	case <-fetchDone:
 	case <-ctx.Done():
 		g.cfg.logger.Log(LogLevelError, "fetch offsets failed due to context cancelation", "group", g.cfg.group)
-		return ctx.Err()
+		return nil, ctx.Err()
 	}
 	if err != nil {
 		g.cfg.logger.Log(LogLevelError, "fetch offsets failed with non-retriable error", "group", g.cfg.group, "err", err)
-		return err
+		return nil, err
 	}
 
 	// Even if a leader epoch is returned, if brokers do not support
@@ -1103,7 +1197,7 @@ start:
 						goto start
 					}
 				}
-				return err
+				return nil, err
 			}
 			offset := Offset{
 				at:    rPartition.Offset,
@@ -1118,57 +1212,77 @@ start:
 			topicOffsets[rPartition.Partition] = offset
 		}
 	}
+	return offsets, nil
+}
+
+// loadStoredOffsets loads offsets for newly assigned partitions from the
+// offset store, returning the offsets to begin consuming at.
+func (g *groupConsumer) loadStoredOffsets(ctx context.Context, newAssigned map[string][]int32) (map[string]map[int32]Offset, error) {
+	stored, err := g.cfg.offsetStore.LoadOffsets(ctx, g.cfg.group, newAssigned)
+	if err != nil {
+		g.cfg.logger.Log(LogLevelError, "loading stored offsets failed", "group", g.cfg.group, "err", err)
+		return nil, err
+	}
 
-	groupTopics := g.tps.load()
-	for fetchedTopic := range offsets {
-		if !groupTopics.hasTopic(fetchedTopic) {
-			delete(offsets, fetchedTopic)
-			g.cfg.logger.Log(LogLevelWarn, "member was assigned topic that we did not ask for in ConsumeTopics! skipping assigning this topic!", "group", g.cfg.group, "topic", fetchedTopic)
+	offsets := make(map[string]map[int32]Offset, len(newAssigned))
+	for topic, partitions := range newAssigned {
+		topicOffsets := make(map[int32]Offset, len(partitions))
+		offsets[topic] = topicOffsets
+		for _, partition := range partitions {
+			eo, exists := stored[topic][partition]
+			if !exists || eo.Offset < 0 {
+				topicOffsets[partition] = g.cfg.resetOffset
+				continue
+			}
+			topicOffsets[partition] = Offset{
+				at:    eo.Offset,
+				epoch: eo.Epoch,
+			}
 		}
 	}
+	return offsets, nil
+}
+
+// addTopics, called under the consumer mu, begins tracking topics that were
+// added with AddConsumeTopics. The next metadata update will see the new
+// topics and rejoin the group with our new subscription.
+func (g *groupConsumer) addTopics(topics []string) {
+	if g.cfg.regex {
+		forgetUnmatchedRegex(g.reSeen)
+		return
+	}
+	g.tps.storeTopics(topics)
+}
+
+// removeTopics, called under the consumer mu after cfg.topics has been
+// updated, stops using the given topics and returns all partitions that must
+// be invalidated. The caller must rejoin the group if anything is returned.
+//
+// We keep the removed topics in uncommitted: they are committed in onRevoked
+// once the rejoin revokes them, and only after that are they deleted.
+func (g *groupConsumer) removeTopics(topics []string) map[string]map[int32]Offset {
+	if g.cfg.regex {
+		topics = reevaluateRegex(g.reSeen, g.cfg.topics)
+	}
 
-	// Lock for assign and then updating uncommitted.
-	g.c.mu.Lock()
-	defer g.c.mu.Unlock()
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	// Eager: we already invalidated everything; nothing to re-invalidate.
-	// Cooperative: assign without invalidating what we are consuming.
-	g.c.assignPartitions(offsets, assignWithoutInvalidating, g.tps)
-
-	// We need to update the uncommited map so that SetOffsets(Committed)
-	// does not rewind before the committed offsets we just fetched.
-	if g.uncommitted == nil {
-		g.uncommitted = make(uncommitted, 10)
-	}
-	for topic, partitions := range offsets {
-		topicUncommitted := g.uncommitted[topic]
-		if topicUncommitted == nil {
-			topicUncommitted = make(map[int32]uncommit, 20)
-			g.uncommitted[topic] = topicUncommitted
-		}
-		for partition, offset := range partitions {
-			if offset.at < 0 {
-				continue // not yet committed
-			}
-			committed := EpochOffset{
-				Epoch:  offset.epoch,
-				Offset: offset.at,
-			}
-			topicUncommitted[partition] = uncommit{
-				head:      committed,
-				committed: committed,
-			}
+	removed := make(map[string]map[int32]Offset, len(topics))
+	for _, topic := range topics {
+		numPartitions, exists := g.using[topic]
+		if !exists {
+			continue
 		}
-	}
+		delete(g.using, topic)
 
-	if g.cfg.logger.Level() >= LogLevelDebug {
-		g.cfg.logger.Log(LogLevelDebug, "fetched committed offsets", "group", g.cfg.group, "fetched", offsets)
-	} else {
-		g.cfg.logger.Log(LogLevelInfo, "fetched committed offsets", "group", g.cfg.group)
+		removedPartitions := make(map[int32]Offset, numPartitions)
+		for partition := int32(0); partition < int32(numPartitions); partition++ {
+			removedPartitions[partition] = Offset{}
+		}
+		removed[topic] = removedPartitions
 	}
-	return nil
+	return removed
 }
 
 // findNewAssignments updates topics the group wants to use and other metadata.
@@ -1246,12 +1360,12 @@ func (g *groupConsumer) findNewAssignments() {
 		return
 	}
 
-	wasManaging := len(g.using) != 0
 	for topic, change := range toChange {
 		g.using[topic] += change.delta
 	}
 
-	if !wasManaging {
+	if !g.managing {
+		g.managing = true
 		go g.manage()
 		return
 	}
@@ -1295,10 +1409,10 @@ func (g *groupConsumer) updateUncommitted(fetches Fetches) {
 
 			var topicOffsets map[int32]uncommit
 			for _, partition := range topic.Partitions {
-				if len(partition.Records) == 0 {
+				head, ok := partition.polledHead()
+				if !ok {
 					continue
 				}
-				final := partition.Records[len(partition.Records)-1]
 
 				if topicOffsets == nil {
 					if g.uncommitted == nil {
@@ -1311,18 +1425,31 @@ func (g *groupConsumer) updateUncommitted(fetches Fetches) {
 					}
 				}
 
-				uncommit := topicOffsets[partition.Partition]
+				uncommit, exists := topicOffsets[partition.Partition]
+
+				if g.cfg.autocommitMarks {
+					// With marks, we track the partition
+					// as live but only move our head when
+					// records are marked.
+					if !exists {
+						topicOffsets[partition.Partition] = uncommit
+					}
+					continue
+				}
+				if g.cfg.autocommitAcked {
+					// With acks, our head only moves once
+					// records are contiguously acked.
+					if head, ok = g.polledAcks(topic.Topic, &partition); !ok {
+						continue
+					}
+				}
 
 				// Our new head points just past the final consumed offset,
 				// that is, if we rejoin, this is the offset to begin at.
-				newOffset := final.Offset + 1
 				if debug {
-					fmt.Fprintf(&b, "%d{%d=>%d}, ", partition.Partition, uncommit.head.Offset, newOffset)
-				}
-				uncommit.head = EpochOffset{
-					final.LeaderEpoch, // -1 if old message / unknown
-					newOffset,
+					fmt.Fprintf(&b, "%d{%d=>%d}, ", partition.Partition, uncommit.head.Offset, head.Offset)
 				}
+				uncommit.head = head
 				topicOffsets[partition.Partition] = uncommit
 			}
 
@@ -1489,6 +1616,9 @@ func (g *groupConsumer) loopCommit() {
 // If using transactions, it is advised to just use a GroupTransactSession and
 // avoid this function entirely.
 //
+// This function does nothing if the client is not consuming as a group; for
+// direct consumers, use SeekPartitions.
+//
 // It is strongly recommended to use this function outside of the context of a
 // PollFetches loop and only when you know the group is not revoked (i.e.,
 // block any concurrent revoke while issuing this call). Any other usage is
@@ -1548,6 +1678,9 @@ func (cl *Client) SetOffsets(setOffsets map[string]map[int32]EpochOffset) {
 				at:    epochOffset.Offset,
 				epoch: epochOffset.Epoch,
 			}
+			if tacks := g.acks[topic]; tacks != nil {
+				delete(tacks, partition) // pending acks are for records before our rewind
+			}
 			topicUncommitted[partition] = uncommit{
 				head:      epochOffset,
 				committed: epochOffset,
@@ -1640,6 +1773,39 @@ func (g *groupConsumer) getUncommittedLocked(head bool) map[string]map[int32]Epo
 	return uncommitted
 }
 
+// MarkCommitRecords marks records to be committed when consuming with
+// AutoCommitMarks. For each partition, the uncommitted offset advances to just
+// past the latest marked record; marking an earlier record does not rewind.
+//
+// Records in partitions that are not assigned in the current group session
+// are ignored, as are all records if the client is not consuming with
+// AutoCommitMarks.
+func (cl *Client) MarkCommitRecords(rs ...*Record) {
+	g := cl.consumer.g
+	if g == nil || !g.cfg.autocommitMarks {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, r := range rs {
+		topicOffsets := g.uncommitted[r.Topic]
+		uncommit, exists := topicOffsets[r.Partition]
+		if !exists {
+			continue // not polled in this session
+		}
+		if r.Offset < uncommit.head.Offset {
+			continue // already marked past this record
+		}
+		uncommit.head = EpochOffset{
+			r.LeaderEpoch,
+			r.Offset + 1,
+		}
+		topicOffsets[r.Partition] = uncommit
+	}
+}
+
 // CommitRecords issues a synchronous offset commit for the offsets contained
 // within rs. Retriable errors are retried up to the configured retry limit,
 // and any unretriable error is returned.
@@ -1778,6 +1944,10 @@ func (cl *Client) CommitOffsetsSync(
 	}
 
 	g := cl.consumer.g
+	if g == nil && cl.cfg.offsetStore != nil && len(uncommitted) > 0 {
+		cl.directCommit(ctx, uncommitted, onDone)
+		return
+	}
 	if g == nil {
 		onDone(cl, new(kmsg.OffsetCommitRequest), new(kmsg.OffsetCommitResponse), errNotGroup)
 		return
@@ -1874,6 +2044,10 @@ func (cl *Client) CommitOffsets(
 	}
 
 	g := cl.consumer.g
+	if g == nil && cl.cfg.offsetStore != nil && len(uncommitted) > 0 {
+		go cl.directCommit(ctx, uncommitted, onDone)
+		return
+	}
 	if g == nil {
 		onDone(cl, new(kmsg.OffsetCommitRequest), new(kmsg.OffsetCommitResponse), errNotGroup)
 		return
@@ -1994,7 +2168,15 @@ func (g *groupConsumer) commit(
 			}
 		}
 
-		resp, err := req.RequestWith(commitCtx, g.cl)
+		var (
+			resp *kmsg.OffsetCommitResponse
+			err  error
+		)
+		if g.cfg.offsetStore != nil {
+			resp, err = storeCommit(commitCtx, g.cfg.offsetStore, g.cfg.group, req)
+		} else {
+			resp, err = req.RequestWith(commitCtx, g.cl)
+		}
 		if err != nil {
 			onDone(g.cl, req, nil, err)
 			return
@@ -0,0 +1,238 @@
+package kgo
+
+import "sync/atomic"
+
+// PartitionLag is the lag for a single partition being consumed.
+//
+// Lag is computed against the end offset of the partition, which is the high
+// watermark, or the last stable offset if consuming with ReadCommitted. The
+// end offset is updated on every fetch response for the partition.
+type PartitionLag struct {
+	Topic     string // Topic is the topic this lag is for.
+	Partition int32  // Partition is the partition this lag is for.
+
+	// End is the latest known end offset of the partition, or -1 if the
+	// partition has not yet been fetched.
+	End int64
+
+	// Consumed is the offset of the next record to be consumed in this
+	// partition: the offset consuming started at (the committed or reset
+	// offset) until records are polled, and then just past the last
+	// polled record. This is -1 if unknown.
+	Consumed int64
+
+	// Committed is the last committed offset for this partition, or -1
+	// if unknown. This is only known when consuming as a group.
+	Committed int64
+
+	// Lag is End minus Consumed, or -1 if either is unknown.
+	Lag int64
+
+	// CommittedLag is End minus Committed, or -1 if either is unknown.
+	CommittedLag int64
+}
+
+// partitionLag is what we track internally per partition; consumed offsets
+// are tracked in cursors, and committed offsets are tracked in the group's
+// uncommitted map.
+type partitionLag struct {
+	end int64
+}
+
+func newPartitionLag() *partitionLag {
+	return &partitionLag{end: -1}
+}
+
+func lagBetween(end, at int64) int64 {
+	if end < 0 || at < 0 {
+		return -1
+	}
+	if at > end {
+		return 0
+	}
+	return end - at
+}
+
+// ConsumerLag returns the current lag of every partition being consumed.
+//
+// This is computed entirely within the client from information returned in
+// fetch responses and from polling and committing; no requests are issued.
+// Partitions that are assigned but still loading their start offsets are not
+// included.
+func (cl *Client) ConsumerLag() map[string]map[int32]PartitionLag {
+	c := &cl.consumer
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lags := make(map[string]map[int32]PartitionLag)
+	for cursor := range c.usingCursors {
+		lag := c.loadLag(cursor.topic, cursor.partition, atomic.LoadInt64(&cursor.consumed))
+		tlags := lags[lag.Topic]
+		if tlags == nil {
+			tlags = make(map[int32]PartitionLag)
+			lags[lag.Topic] = tlags
+		}
+		tlags[lag.Partition] = lag
+	}
+	c.fillCommittedLag(lags)
+	return lags
+}
+
+// loadLag returns the lag for a partition consumed up to consumed, without
+// the committed offset.
+func (c *consumer) loadLag(topic string, partition int32, consumed int64) PartitionLag {
+	end := int64(-1)
+
+	c.lagMu.Lock()
+	if l := c.lags[topic][partition]; l != nil {
+		end = l.end
+	}
+	c.lagMu.Unlock()
+
+	return PartitionLag{
+		Topic:        topic,
+		Partition:    partition,
+		End:          end,
+		Consumed:     consumed,
+		Committed:    -1,
+		Lag:          lagBetween(end, consumed),
+		CommittedLag: -1,
+	}
+}
+
+// fillCommittedLag fills in committed offsets if we are consuming as a group.
+func (c *consumer) fillCommittedLag(lags map[string]map[int32]PartitionLag) {
+	g := c.g
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for topic, tlags := range lags {
+		tuncommitted := g.uncommitted[topic]
+		for partition, lag := range tlags {
+			uncommit, exists := tuncommitted[partition]
+			if !exists {
+				continue
+			}
+			lag.Committed = uncommit.committed.Offset
+			lag.CommittedLag = lagBetween(lag.End, lag.Committed)
+			tlags[partition] = lag
+		}
+	}
+}
+
+// updateLagEnds is called in a fetch after processing a response to update
+// the end offsets for every partition in the response.
+func (c *consumer) updateLagEnds(f *Fetch) {
+	readCommitted := c.cl.cfg.isolationLevel == 1
+
+	c.lagMu.Lock()
+	defer c.lagMu.Unlock()
+
+	for i := range f.Topics {
+		t := &f.Topics[i]
+		for j := range t.Partitions {
+			p := &t.Partitions[j]
+			end := p.HighWatermark
+			if readCommitted && p.LastStableOffset >= 0 {
+				end = p.LastStableOffset
+			}
+			if end < 0 {
+				continue // partition error
+			}
+			c.lagFor(t.Topic, p.Partition).end = end
+		}
+	}
+}
+
+// loadPolledLag, called under the consumer mu when polling, returns the lag
+// for every partition that had records polled.
+func (c *consumer) loadPolledLag(fetches Fetches) map[string]map[int32]PartitionLag {
+	polled := make(map[string]map[int32]PartitionLag)
+
+	c.lagMu.Lock()
+	for _, fetch := range fetches {
+		for i := range fetch.Topics {
+			t := &fetch.Topics[i]
+			for j := range t.Partitions {
+				p := &t.Partitions[j]
+				head, ok := p.polledHead()
+				if !ok {
+					continue
+				}
+				l := c.lagFor(t.Topic, p.Partition)
+
+				tpolled := polled[t.Topic]
+				if tpolled == nil {
+					tpolled = make(map[int32]PartitionLag)
+					polled[t.Topic] = tpolled
+				}
+				tpolled[p.Partition] = PartitionLag{
+					Topic:        t.Topic,
+					Partition:    p.Partition,
+					End:          l.end,
+					Consumed:     head.Offset,
+					Committed:    -1,
+					Lag:          lagBetween(l.end, head.Offset),
+					CommittedLag: -1,
+				}
+			}
+		}
+	}
+	c.lagMu.Unlock()
+
+	return polled
+}
+
+// onConsumerLag is called at the end of polling, outside of the consumer mu,
+// with the lag of the partitions that were polled. If any hook is interested
+// in lag, we fill in committed offsets and call the hooks.
+func (c *consumer) onConsumerLag(polled map[string]map[int32]PartitionLag) {
+	if len(polled) == 0 {
+		return
+	}
+
+	var filled bool
+	c.cl.cfg.hooks.each(func(h Hook) {
+		if h, ok := h.(HookConsumerLag); ok {
+			if !filled {
+				c.fillCommittedLag(polled)
+				filled = true
+			}
+			h.OnConsumerLag(polled)
+		}
+	})
+}
+
+// lagFor, called under the lagMu, returns the tracked lag for a partition,
+// creating it if necessary.
+func (c *consumer) lagFor(topic string, partition int32) *partitionLag {
+	if c.lags == nil {
+		c.lags = make(map[string]map[int32]*partitionLag)
+	}
+	tlags := c.lags[topic]
+	if tlags == nil {
+		tlags = make(map[int32]*partitionLag)
+		c.lags[topic] = tlags
+	}
+	l := tlags[partition]
+	if l == nil {
+		l = newPartitionLag()
+		tlags[partition] = l
+	}
+	return l
+}
+
+// forgetLag is called when assigning partitions for every cursor that is no
+// longer being consumed, so that a later reassignment does not report a stale
+// end offset.
+func (c *consumer) forgetLag(cursor *cursor) {
+	c.lagMu.Lock()
+	defer c.lagMu.Unlock()
+	tlags := c.lags[cursor.topic]
+	delete(tlags, cursor.partition)
+	if len(tlags) == 0 {
+		delete(c.lags, cursor.topic)
+	}
+}
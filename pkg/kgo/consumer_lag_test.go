@@ -0,0 +1,73 @@
+package kgo
+
+import "testing"
+
+func TestConsumerLagTracking(t *testing.T) {
+	cl := &Client{cfg: defaultCfg()}
+	c := &cl.consumer
+	c.cl = cl
+
+	fetch := func(partition int32, hwm int64, offsets ...int64) Fetch {
+		var rs []*Record
+		for _, offset := range offsets {
+			rs = append(rs, &Record{Offset: offset})
+		}
+		return Fetch{Topics: []FetchTopic{{
+			Topic: "foo",
+			Partitions: []FetchPartition{{
+				Partition:     partition,
+				HighWatermark: hwm,
+				Records:       rs,
+			}},
+		}}}
+	}
+
+	// Partition 0 starts consuming at its committed offset 0, and
+	// partition 1 at its committed offset 5.
+	p0 := &cursor{topic: "foo", partition: 0, consumed: -1}
+	p1 := &cursor{topic: "foo", partition: 1, consumed: -1}
+	c.usingCursors.use(p0)
+	c.usingCursors.use(p1)
+	if lag := cl.ConsumerLag()["foo"][0]; lag.End != -1 || lag.Consumed != -1 || lag.Lag != -1 {
+		t.Errorf("got %+v before loading offsets, expected all unknown", lag)
+	}
+	p0.setOffset(cursorOffset{offset: 0})
+	p1.setOffset(cursorOffset{offset: 5})
+
+	f := fetch(0, 10, 0, 1, 2)
+	c.updateLagEnds(&f)
+	if lag := cl.ConsumerLag()["foo"][0]; lag.End != 10 || lag.Consumed != 0 || lag.Lag != 10 {
+		t.Errorf("got %+v after fetching, expected end 10 with lag 10", lag)
+	}
+
+	// Polling moves the cursor and reports the polled lag.
+	polled := c.loadPolledLag(Fetches{f})
+	p0.setOffset(cursorOffset{offset: 3})
+	if lag := polled["foo"][0]; lag.Consumed != 3 || lag.Lag != 7 {
+		t.Errorf("got polled %+v, expected consumed 3 with lag 7", lag)
+	}
+	if lag := cl.ConsumerLag()["foo"][0]; lag.Consumed != 3 || lag.Lag != 7 {
+		t.Errorf("got %+v after polling, expected consumed 3 with lag 7", lag)
+	}
+
+	// A partition that never receives records has lag from where it
+	// started consuming once its end is known.
+	f = fetch(1, 8)
+	c.updateLagEnds(&f)
+	if lag := cl.ConsumerLag()["foo"][1]; lag.End != 8 || lag.Consumed != 5 || lag.Lag != 3 {
+		t.Errorf("got %+v for an idle partition, expected consumed 5 with lag 3", lag)
+	}
+
+	// A partition error does not have a high watermark and should not
+	// reset our end offset.
+	f = fetch(0, -1)
+	c.updateLagEnds(&f)
+	if lag := cl.ConsumerLag()["foo"][0]; lag.End != 10 || lag.Lag != 7 {
+		t.Errorf("got %+v after erroring, expected end 10 with lag 7", lag)
+	}
+
+	c.forgetLag(p0)
+	if lag := c.loadLag("foo", 0, -1); lag.End != -1 || lag.Consumed != -1 {
+		t.Errorf("got %+v after forgetting, expected all unknown", lag)
+	}
+}
@@ -0,0 +1,187 @@
+package kgo
+
+import (
+	"errors"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestPauseResumeFetch(t *testing.T) {
+	cl, _ := NewClient()
+	defer cl.Close()
+
+	// Pausing topics and partitions is tracked independently.
+	if paused := cl.PauseFetchTopics("foo"); len(paused) != 1 || paused[0] != "foo" {
+		t.Errorf("got paused topics %v, expected [foo]", paused)
+	}
+	cl.PauseFetchPartitions(map[string][]int32{"foo": {1}, "bar": {0}})
+
+	expPaused := func(when string, exp map[string]map[int32]bool) {
+		t.Helper()
+		paused := cl.consumer.loadPaused()
+		for topic, partitions := range exp {
+			for partition, exp := range partitions {
+				if got := paused.has(topic, partition); got != exp {
+					t.Errorf("%s: got %s p%d paused %v, expected %v", when, topic, partition, got, exp)
+				}
+			}
+		}
+	}
+	expPaused("after pausing", map[string]map[int32]bool{
+		"foo": {0: true, 1: true},
+		"bar": {0: true, 1: false},
+		"baz": {0: false},
+	})
+
+	// Resuming a topic does not resume its individually paused
+	// partitions.
+	cl.ResumeFetchTopics("foo")
+	expPaused("after resuming foo", map[string]map[int32]bool{
+		"foo": {0: false, 1: true},
+		"bar": {0: true},
+	})
+	cl.ResumeFetchPartitions(map[string][]int32{"foo": {1}})
+	if topics := cl.PauseFetchTopics(); len(topics) != 0 {
+		t.Errorf("got paused topics %v after resuming, expected none", topics)
+	}
+	if partitions := cl.PauseFetchPartitions(nil); len(partitions) != 1 || len(partitions["bar"]) != 1 || partitions["bar"][0] != 0 {
+		t.Errorf("got paused partitions %v after resuming foo p1, expected only bar p0", partitions)
+	}
+
+	// Paused partitions are not fetched, and are fetched again once
+	// resumed.
+	s := cl.newSource(0)
+	cur := &cursor{topic: "bar", source: s, useState: 1}
+	cur.leaderEpoch = -1
+	cur.cursorOffset = cursorOffset{offset: 5, lastConsumedEpoch: -1}
+	s.cursors = append(s.cursors, cur)
+
+	if req := s.createReq(); req.numOffsets != 0 {
+		t.Errorf("got %d partitions in a fetch request while paused, expected 0", req.numOffsets)
+	}
+	cl.ResumeFetchPartitions(map[string][]int32{"bar": {0}})
+	if req := s.createReq(); req.numOffsets != 1 {
+		t.Errorf("got %d partitions in a fetch request after resuming, expected 1", req.numOffsets)
+	}
+}
+
+func TestAddRemoveConsumeTopics(t *testing.T) {
+	cl, _ := NewClient()
+	defer cl.Close()
+
+	consuming := func(topic string) bool {
+		cl.consumer.mu.Lock()
+		defer cl.consumer.mu.Unlock()
+		return cl.consumer.d.tps.load().hasTopic(topic)
+	}
+
+	if err := cl.AddConsumeTopics("foo", "bar"); err != nil {
+		t.Fatalf("unexpected add err: %v", err)
+	}
+	cl.AddConsumePartitions(map[string]map[int32]Offset{"baz": {0: NewOffset().At(5)}})
+	for _, topic := range []string{"foo", "bar", "baz"} {
+		if !consuming(topic) {
+			t.Errorf("%s is not being consumed after adding it", topic)
+		}
+	}
+
+	// Removing purges the topic so that re-adding it later creates
+	// fresh cursors.
+	cl.RemoveConsumeTopics("foo")
+	if consuming("foo") {
+		t.Error("foo is still being consumed after removing it")
+	}
+	if !consuming("bar") {
+		t.Error("bar is no longer being consumed after removing foo")
+	}
+	cl.RemoveConsumePartitions(map[string][]int32{"baz": {0}})
+	if consuming("baz") {
+		t.Error("baz is still being consumed after removing its only partition")
+	}
+}
+
+func TestAddConsumeTopicsInvalidRegex(t *testing.T) {
+	cl, _ := NewClient(ConsumeRegex())
+	defer cl.Close()
+
+	err := cl.AddConsumeTopics("foo.*", "[")
+	var reErr *syntax.Error
+	if !errors.As(err, &reErr) {
+		t.Errorf("got err %v, expected a wrapped regular expression error", err)
+	}
+	if _, exists := cl.cfg.topics["foo.*"]; exists {
+		t.Error("valid expression was added alongside an invalid one")
+	}
+}
+
+func TestDirectRegexReevaluation(t *testing.T) {
+	cfg := defaultCfg()
+	cfg.regex = true
+	cfg.topics = map[string]*regexp.Regexp{"f.*": regexp.MustCompile("f.*")}
+	d := &directConsumer{
+		cfg:    &cfg,
+		reSeen: map[string]bool{"foo": true, "bar": false},
+		using:  map[string]map[int32]struct{}{"foo": {0: {}}},
+	}
+
+	// Adding an expression forgets the topics we know do not match, so
+	// that the next metadata update evaluates them again.
+	cfg.topics = map[string]*regexp.Regexp{"f.*": cfg.topics["f.*"], "b.*": regexp.MustCompile("b.*")}
+	d.addTopics([]string{"b.*"})
+	if _, seen := d.reSeen["bar"]; seen {
+		t.Error("unmatched topic was not forgotten after adding an expression")
+	}
+
+	// Removing an expression stops consuming topics that no longer match
+	// any remaining expression.
+	cfg.topics = map[string]*regexp.Regexp{"b.*": cfg.topics["b.*"]}
+	removed := d.removeTopics([]string{"f.*"})
+	if _, exists := removed["foo"][0]; !exists {
+		t.Errorf("got removed %v, expected foo p0", removed)
+	}
+	if want, seen := d.reSeen["foo"]; !seen || want {
+		t.Error("topic that no longer matches is still wanted")
+	}
+	if len(d.using) != 0 {
+		t.Errorf("got using %v after removing, expected nothing", d.using)
+	}
+}
+
+func TestBuildListReqTimestamps(t *testing.T) {
+	load := offsetLoadMap{"foo": {
+		0: {replica: -1, Offset: NewOffset().AfterMilli(1000)},
+		1: {replica: -1, Offset: NewOffset().At(5)}, // exact offsets list the end to load the partition
+		2: {replica: -1, Offset: NewOffset().AtStart()},
+		3: {replica: -1, Offset: NewOffset().AtEnd()},
+		4: {replica: -1, Offset: NewOffset().AfterMilli(1000).AtStart()},
+	}}
+	exp := map[int32]int64{0: 1000, 1: -1, 2: -2, 3: -1, 4: -2}
+
+	req := load.buildListReq(0)
+	for _, p := range req.Topics[0].Partitions {
+		if p.Timestamp != exp[p.Partition] {
+			t.Errorf("got p%d timestamp %d, expected %d", p.Partition, p.Timestamp, exp[p.Partition])
+		}
+	}
+
+	// A timestamp that no record is at or after falls back to listing
+	// the end, keeping the relative offset.
+	timestamp := offsetLoad{replica: -1, Offset: NewOffset().AfterMilli(1000).Relative(-2)}
+	end, ok := timestamp.timestampFallback(&kmsg.ListOffsetsResponseTopicPartition{Offset: -1})
+	if !ok || end.afterMilli || end.at != -1 || end.relative != -2 {
+		t.Errorf("got fallback %+v (ok %v), expected the end relative -2", end.Offset, ok)
+	}
+	fallback := offsetLoadMap{"foo": {0: end}}
+	if got := fallback.buildListReq(0).Topics[0].Partitions[0].Timestamp; got != -1 {
+		t.Errorf("got fallback timestamp %d, expected -1", got)
+	}
+	if _, ok := timestamp.timestampFallback(&kmsg.ListOffsetsResponseTopicPartition{Offset: 3}); ok {
+		t.Error("fell back even though the timestamp resolved to an offset")
+	}
+	if _, ok := (offsetLoad{replica: -1, Offset: NewOffset().AtEnd()}).timestampFallback(&kmsg.ListOffsetsResponseTopicPartition{Offset: -1}); ok {
+		t.Error("fell back for a request that was not for a timestamp")
+	}
+}
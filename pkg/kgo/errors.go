@@ -126,8 +126,8 @@ var (
 	//////////////
 
 	// ErrMaxBuffered is returned when producing with manual flushing
-	// enabled and the maximum amount of records are buffered.
-	ErrMaxBuffered = errors.New("manual flushing is enabled and the maximum amount of records are buffered, cannot buffer more")
+	// enabled and the maximum amount of records or bytes are buffered.
+	ErrMaxBuffered = errors.New("manual flushing is enabled and the maximum amount of records or bytes are buffered, cannot buffer more")
 
 	// ErrAborting is returned for all buffered records while
 	// AbortBufferedRecords is being called.
@@ -143,6 +143,20 @@ var (
 	//
 	// For any request, the request is failed with this error.
 	ErrClientClosed = errors.New("client closed")
+
+	// ErrRecordSpilled is returned for records that are persisted in the
+	// ProducerSpillDir spill when the client is closed. These records have
+	// not been produced, but will be produced by the next client that uses
+	// the same spill directory.
+	ErrRecordSpilled = errors.New("client closed with the record persisted in the spill directory; it will be produced by the next client using the directory")
+
+	// ErrBoundedDone is returned when consuming with ConsumeBounded once
+	// every partition being consumed has reached its snapshotted end
+	// offset.
+	//
+	// A fake partition is injected into a poll response that has this
+	// error.
+	ErrBoundedDone = errors.New("all partitions have been consumed to their snapshotted end offsets")
 )
 
 // ErrDataLoss is returned for Kafka >=2.1.0 when data loss is detected and the
@@ -0,0 +1,414 @@
+package kgo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// processorQueuedBatches is how many polled batches can be queued for a
+// partition worker before Run blocks dispatching to that worker.
+const processorQueuedBatches = 4
+
+// GroupProcessor processes records from a group consumer with one goroutine
+// per assigned partition, keeping records in order within each partition and
+// committing only offsets that have been processed.
+//
+// A GroupProcessor must be hooked into a client at creation with the options
+// returned from Opts, and then driven with Run:
+//
+//	p := kgo.NewGroupProcessor(func(ctx context.Context, part kgo.FetchTopicPartition) {
+//	        // process part.Records in order
+//	})
+//	cl, err := kgo.NewClient(append(opts, p.Opts()...)...)
+//	// handle err
+//	go p.Run(ctx, cl)
+//
+// Workers are started as partitions are assigned and are stopped as
+// partitions are revoked or lost. On revoke, the processor waits for any
+// batch that is actively being processed to finish and then synchronously
+// commits the processed offsets for the revoked partitions. Batches that were
+// polled but not yet being processed are dropped and will be consumed again
+// by whichever member is next assigned the partition.
+//
+// While running, processed offsets are committed with CommitOffsets every
+// AutoCommitInterval.
+type GroupProcessor struct {
+	process func(context.Context, FetchTopicPartition)
+
+	mu        sync.Mutex
+	workers   map[string]map[int32]*processWorker
+	committed map[string]map[int32]int64
+
+	// gen is bumped every time workers are stopped. Batches are tagged
+	// with the gen they were polled in, and workers with the gen they
+	// were created in, so that we can drop batches that were polled
+	// before a revoke but dispatched after the partition is reassigned.
+	gen uint64
+
+	// cancelPoll cancels the poll in progress in Run, if any. Stopping
+	// workers cancels the poll so that a poll does not span a revoke.
+	cancelPoll context.CancelFunc
+}
+
+// processWorker processes batches for a single partition.
+type processWorker struct {
+	batches chan FetchTopicPartition
+	quit    chan struct{} // closed when the partition is revoked or lost
+	done    chan struct{} // closed when the worker goroutine exits
+	gen     uint64        // the processor gen this worker was created in
+
+	// next is just past the last processed record, or -1 if nothing has
+	// been processed. This is written by the worker under the processor
+	// mu, and is used for committing and for skipping stale batches.
+	next EpochOffset
+}
+
+// NewGroupProcessor returns a new processor that calls process for every
+// polled batch of records in a partition. Calls to process are serial within
+// a partition and concurrent across partitions.
+//
+// The context passed to process is the group's context, which is canceled
+// only if the group is left or the client is closed.
+func NewGroupProcessor(process func(context.Context, FetchTopicPartition)) *GroupProcessor {
+	return &GroupProcessor{
+		process:   process,
+		workers:   make(map[string]map[int32]*processWorker),
+		committed: make(map[string]map[int32]int64),
+	}
+}
+
+// Opts returns the options that must be used when creating the client that
+// this processor runs with. The options set OnAssigned, OnRevoked, and OnLost,
+// and disable autocommitting; these options must not be overridden.
+func (p *GroupProcessor) Opts() []Opt {
+	return []Opt{
+		OnAssigned(p.assigned),
+		OnRevoked(p.revoked),
+		OnLost(p.lost),
+		DisableAutoCommit(),
+	}
+}
+
+// Run polls the client and dispatches polled records to partition workers
+// until the context is canceled or the client is closed. Fetch errors are
+// logged.
+//
+// Run should be called at most once per processor.
+func (p *GroupProcessor) Run(ctx context.Context, cl *Client) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go p.commitLoop(ctx, cl)
+
+	p.run(ctx, cl.cfg.logger, cl.PollFetches)
+}
+
+// run is Run's poll and dispatch loop, split out so that polling can be faked.
+func (p *GroupProcessor) run(ctx context.Context, logger Logger, poll func(context.Context) Fetches) {
+	for {
+		// We read our gen before polling. The client invalidates
+		// buffered fetches before calling OnRevoked, so a batch for a
+		// partition that is revoked while or after we poll was polled
+		// in this gen, and is dropped if the partition is reassigned.
+		//
+		// Stopping workers cancels the poll: a poll that spanned a
+		// revoke and reassignment could return batches for the new
+		// assignment, which would be dropped with our old gen.
+		pollCtx, cancelPoll := context.WithCancel(ctx)
+		p.mu.Lock()
+		gen := p.gen
+		p.cancelPoll = cancelPoll
+		p.mu.Unlock()
+
+		fetches := poll(pollCtx)
+		cancelPoll()
+		if fetches.IsClientClosed() || ctx.Err() != nil {
+			return
+		}
+
+		fetches.EachError(func(t string, partition int32, err error) {
+			logger.Log(LogLevelError, "group processor fetch error", "topic", t, "partition", partition, "err", err)
+		})
+		fetches.EachPartition(func(part FetchTopicPartition) {
+			if len(part.Records) > 0 {
+				p.dispatch(ctx, gen, part)
+			}
+		})
+	}
+}
+
+// dispatch sends a batch polled in gen to its partition's worker. If the
+// partition is no longer assigned, was revoked and reassigned since the batch
+// was polled, or is revoked while we wait, the batch is dropped.
+func (p *GroupProcessor) dispatch(ctx context.Context, gen uint64, part FetchTopicPartition) {
+	p.mu.Lock()
+	w := p.workers[part.Topic][part.Partition]
+	p.mu.Unlock()
+
+	if w == nil || w.gen > gen {
+		return
+	}
+	select {
+	case w.batches <- part:
+	case <-w.quit:
+	case <-ctx.Done():
+	}
+}
+
+func (p *GroupProcessor) work(ctx context.Context, w *processWorker) {
+	defer close(w.done)
+	for {
+		select {
+		case <-w.quit:
+			return
+		case part := <-w.batches:
+			// If we are revoked while a batch is queued, we do not
+			// begin new work.
+			select {
+			case <-w.quit:
+				return
+			default:
+			}
+
+			// We only ever move forward within a partition, so we
+			// skip anything we have already processed.
+			for len(part.Records) > 0 && part.Records[0].Offset < w.next.Offset {
+				part.Records = part.Records[1:]
+			}
+			if len(part.Records) == 0 {
+				continue
+			}
+
+			p.process(ctx, part)
+
+			last := part.Records[len(part.Records)-1]
+			p.mu.Lock()
+			w.next = EpochOffset{last.LeaderEpoch, last.Offset + 1}
+			p.mu.Unlock()
+		}
+	}
+}
+
+func (p *GroupProcessor) assigned(ctx context.Context, _ *Client, assigned map[string][]int32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for topic, partitions := range assigned {
+		tworkers := p.workers[topic]
+		if tworkers == nil {
+			tworkers = make(map[int32]*processWorker)
+			p.workers[topic] = tworkers
+		}
+		for _, partition := range partitions {
+			if tworkers[partition] != nil {
+				continue
+			}
+			w := &processWorker{
+				batches: make(chan FetchTopicPartition, processorQueuedBatches),
+				quit:    make(chan struct{}),
+				done:    make(chan struct{}),
+				gen:     p.gen,
+				next:    EpochOffset{-1, -1},
+			}
+			tworkers[partition] = w
+			go p.work(ctx, w)
+		}
+	}
+}
+
+func (p *GroupProcessor) revoked(_ context.Context, cl *Client, revoked map[string][]int32) {
+	processed := p.stop(revoked)
+	if len(processed) == 0 {
+		return
+	}
+
+	// Similar to the default revoke, we use the client's context because
+	// the group context is canceled if we are leaving the group.
+	cl.CommitOffsetsSync(cl.ctx, processed, func(_ *Client, _ *kmsg.OffsetCommitRequest, _ *kmsg.OffsetCommitResponse, err error) {
+		if err != nil {
+			cl.cfg.logger.Log(LogLevelError, "group processor unable to commit revoked partitions", "err", err)
+		}
+	})
+}
+
+func (p *GroupProcessor) lost(_ context.Context, _ *Client, lost map[string][]int32) {
+	p.stop(lost) // committing would fail; we drop what we processed
+}
+
+// stop stops workers for the given partitions, waits for them to finish any
+// in flight batch, and returns the processed offsets that need committing.
+func (p *GroupProcessor) stop(stopping map[string][]int32) map[string]map[int32]EpochOffset {
+	stopped := make(map[string]map[int32]*processWorker)
+
+	p.mu.Lock()
+	p.gen++
+	if p.cancelPoll != nil {
+		p.cancelPoll()
+	}
+	for topic, partitions := range stopping {
+		tworkers := p.workers[topic]
+		for _, partition := range partitions {
+			w := tworkers[partition]
+			if w == nil {
+				continue
+			}
+			close(w.quit)
+			delete(tworkers, partition)
+
+			tstopped := stopped[topic]
+			if tstopped == nil {
+				tstopped = make(map[int32]*processWorker)
+				stopped[topic] = tstopped
+			}
+			tstopped[partition] = w
+		}
+		if len(tworkers) == 0 {
+			delete(p.workers, topic)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, tstopped := range stopped {
+		for _, w := range tstopped {
+			<-w.done
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var processed map[string]map[int32]EpochOffset
+	for topic, tstopped := range stopped {
+		tcommitted := p.committed[topic]
+		for partition, w := range tstopped {
+			committed, wasCommitted := tcommitted[partition]
+			delete(tcommitted, partition)
+			if w.next.Offset < 0 || wasCommitted && w.next.Offset <= committed {
+				continue
+			}
+			if processed == nil {
+				processed = make(map[string]map[int32]EpochOffset)
+			}
+			tprocessed := processed[topic]
+			if tprocessed == nil {
+				tprocessed = make(map[int32]EpochOffset)
+				processed[topic] = tprocessed
+			}
+			tprocessed[partition] = w.next
+		}
+		if len(tcommitted) == 0 {
+			delete(p.committed, topic)
+		}
+	}
+	return processed
+}
+
+// commitLoop commits processed offsets every autocommit interval until the
+// context is canceled.
+func (p *GroupProcessor) commitLoop(ctx context.Context, cl *Client) {
+	ticker := time.NewTicker(cl.cfg.autocommitInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		processed := p.uncommitted()
+		if len(processed) == 0 {
+			continue
+		}
+		cl.CommitOffsets(ctx, processed, p.onCommitted)
+	}
+}
+
+// uncommitted returns the processed offsets that have not yet been committed.
+func (p *GroupProcessor) uncommitted() map[string]map[int32]EpochOffset {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var uncommitted map[string]map[int32]EpochOffset
+	for topic, tworkers := range p.workers {
+		tcommitted := p.committed[topic]
+		for partition, w := range tworkers {
+			if w.next.Offset < 0 {
+				continue
+			}
+			if committed, exists := tcommitted[partition]; exists && w.next.Offset <= committed {
+				continue
+			}
+			if uncommitted == nil {
+				uncommitted = make(map[string]map[int32]EpochOffset)
+			}
+			tuncommitted := uncommitted[topic]
+			if tuncommitted == nil {
+				tuncommitted = make(map[int32]EpochOffset)
+				uncommitted[topic] = tuncommitted
+			}
+			tuncommitted[partition] = w.next
+		}
+	}
+	return uncommitted
+}
+
+// onCommitted tracks what was successfully committed so that we do not
+// recommit unchanged offsets.
+func (p *GroupProcessor) onCommitted(cl *Client, req *kmsg.OffsetCommitRequest, resp *kmsg.OffsetCommitResponse, err error) {
+	if err != nil {
+		if err != context.Canceled {
+			cl.cfg.logger.Log(LogLevelError, "group processor unable to commit", "err", err)
+		}
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := range resp.Topics {
+		t := &resp.Topics[i]
+		for j := range t.Partitions {
+			rp := &t.Partitions[j]
+			if err := kerr.ErrorForCode(rp.ErrorCode); err != nil {
+				cl.cfg.logger.Log(LogLevelError, "group processor unable to commit partition", "topic", t.Topic, "partition", rp.Partition, "err", err)
+				continue
+			}
+			// We only track partitions that are still assigned.
+			if p.workers[t.Topic][rp.Partition] == nil {
+				continue
+			}
+			offset, ok := committedOffset(req, t.Topic, rp.Partition)
+			if !ok {
+				continue
+			}
+			tcommitted := p.committed[t.Topic]
+			if tcommitted == nil {
+				tcommitted = make(map[int32]int64)
+				p.committed[t.Topic] = tcommitted
+			}
+			if offset > tcommitted[rp.Partition] {
+				tcommitted[rp.Partition] = offset
+			}
+		}
+	}
+}
+
+// committedOffset returns the offset committed for a partition in a request.
+func committedOffset(req *kmsg.OffsetCommitRequest, topic string, partition int32) (int64, bool) {
+	for i := range req.Topics {
+		t := &req.Topics[i]
+		if t.Topic != topic {
+			continue
+		}
+		for j := range t.Partitions {
+			if p := &t.Partitions[j]; p.Partition == partition {
+				return p.Offset, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,159 @@
+package kgo
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGroupProcessorOrderedStop(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		got []int64
+	)
+	p := NewGroupProcessor(func(_ context.Context, part FetchTopicPartition) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, r := range part.Records {
+			got = append(got, r.Offset)
+		}
+	})
+
+	ctx := context.Background()
+	p.assigned(ctx, nil, map[string][]int32{"foo": {0}})
+
+	batch := func(offsets ...int64) FetchTopicPartition {
+		part := FetchTopicPartition{Topic: "foo", FetchPartition: FetchPartition{Partition: 0}}
+		for _, offset := range offsets {
+			part.Records = append(part.Records, &Record{Offset: offset, LeaderEpoch: 1})
+		}
+		return part
+	}
+	p.dispatch(ctx, 0, batch(0, 1))
+	p.dispatch(ctx, 0, batch(1, 2, 3)) // 1 is stale and is skipped
+	p.dispatch(ctx, 0, batch(4))
+
+	// Wait for all dispatched batches to be processed.
+	for {
+		p.mu.Lock()
+		next := p.workers["foo"][0].next
+		p.mu.Unlock()
+		if next.Offset == 5 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if uncommitted := p.uncommitted(); uncommitted["foo"][0] != (EpochOffset{1, 5}) {
+		t.Errorf("got uncommitted %v, expected foo p0 at epoch 1 offset 5", uncommitted)
+	}
+
+	processed := p.stop(map[string][]int32{"foo": {0}})
+	if processed["foo"][0] != (EpochOffset{1, 5}) {
+		t.Errorf("got processed %v on stop, expected foo p0 at epoch 1 offset 5", processed)
+	}
+	if len(p.workers) != 0 {
+		t.Errorf("got %d topics with workers after stopping, expected 0", len(p.workers))
+	}
+
+	// Dispatching to a stopped partition drops the batch.
+	p.dispatch(ctx, 0, batch(5))
+
+	mu.Lock()
+	defer mu.Unlock()
+	exp := []int64{0, 1, 2, 3, 4}
+	if len(got) != len(exp) {
+		t.Fatalf("got processed offsets %v, expected %v", got, exp)
+	}
+	for i := range exp {
+		if got[i] != exp[i] {
+			t.Fatalf("got processed offsets %v, expected %v", got, exp)
+		}
+	}
+}
+
+func TestGroupProcessorDropsPreRevokeBatch(t *testing.T) {
+	processed := make(chan []int64, 2)
+	p := NewGroupProcessor(func(_ context.Context, part FetchTopicPartition) {
+		var offsets []int64
+		for _, r := range part.Records {
+			offsets = append(offsets, r.Offset)
+		}
+		processed <- offsets
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	foo := map[string][]int32{"foo": {0}}
+	fetch := func(offsets ...int64) Fetches {
+		fp := FetchPartition{Partition: 0}
+		for _, offset := range offsets {
+			fp.Records = append(fp.Records, &Record{Topic: "foo", Offset: offset})
+		}
+		return Fetches{{Topics: []FetchTopic{{Topic: "foo", Partitions: []FetchPartition{fp}}}}}
+	}
+	expProcessed := func(exp ...int64) {
+		t.Helper()
+		select {
+		case got := <-processed:
+			if len(got) != len(exp) {
+				t.Fatalf("got processed offsets %v, expected %v", got, exp)
+			}
+			for i := range exp {
+				if got[i] != exp[i] {
+					t.Fatalf("got processed offsets %v, expected %v", got, exp)
+				}
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for offsets %v to be processed", exp)
+		}
+	}
+
+	p.assigned(ctx, nil, foo)
+	polls := []func(context.Context) Fetches{
+		// A batch is polled, and the partition is revoked and
+		// reassigned before the batch is dispatched; the new worker
+		// must drop it.
+		func(context.Context) Fetches {
+			p.stop(foo)
+			p.assigned(ctx, nil, foo)
+			return fetch(0, 1)
+		},
+
+		// Later polls are processed.
+		func(context.Context) Fetches { return fetch(5, 6) },
+
+		// A revoke while we are polling cancels the poll, so that
+		// the next poll is in the new gen.
+		func(pollCtx context.Context) Fetches {
+			expProcessed(5, 6)
+			p.stop(foo)
+			if pollCtx.Err() == nil {
+				t.Error("revoking did not cancel the in progress poll")
+			}
+			p.assigned(ctx, nil, foo)
+			return nil
+		},
+		func(context.Context) Fetches { return fetch(7) },
+
+		func(context.Context) Fetches {
+			expProcessed(7)
+			cancel()
+			return nil
+		},
+	}
+	p.run(ctx, new(nopLogger), func(pollCtx context.Context) Fetches {
+		poll := polls[0]
+		polls = polls[1:]
+		return poll(pollCtx)
+	})
+
+	p.stop(foo)
+	select {
+	case got := <-processed:
+		t.Errorf("got unexpected processed offsets %v", got)
+	default:
+	}
+}
@@ -340,6 +340,21 @@ type HookFetchRecordBuffered interface {
 	OnFetchRecordBuffered(*Record)
 }
 
+// HookConsumerLag is called after polling with the lag of every partition that
+// had records returned from the poll.
+//
+// Lag is computed against both the consumed offset (just past the last polled
+// record) and the committed offset, if consuming as a group. This hook can be
+// used to export lag metrics without periodically issuing requests; to
+// inspect the lag of all partitions at any time, use the client's ConsumerLag
+// method.
+type HookConsumerLag interface {
+	// OnConsumerLag is passed the lag of every partition that had records
+	// returned from the poll, keyed by topic and partition. The committed
+	// offset is as of the time of the poll.
+	OnConsumerLag(map[string]map[int32]PartitionLag)
+}
+
 // HookFetchRecordUnbuffered is called when a fetched record is unbuffered.
 //
 // A record can be internally discarded after being in some scenarios without
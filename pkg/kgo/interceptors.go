@@ -0,0 +1,102 @@
+package kgo
+
+// ConsumeInterceptor intercepts records after they are decoded from a fetch
+// response and before they are buffered to be polled.
+//
+// Interceptors can modify a record's Key, Value, and Headers in place (for
+// example, to decrypt or decompress an application payload), or can drop the
+// record entirely (for example, to filter poison records). Interceptors are
+// called in the order they are configured; once a record is dropped, later
+// interceptors are not called.
+//
+// Interceptors are called serially per partition, but concurrently across
+// partitions on different brokers, and must be safe for concurrent use.
+//
+// Interceptors run once a fetch response is kept to be buffered, not while the
+// response is being processed. A buffered fetch is still discarded without
+// being polled if partitions are paused, seeked, added, or removed, or if the
+// group rebalances; its records are then fetched again and interceptors run
+// again on the same records. Interceptors that count records or have other
+// side effects must tolerate seeing a record more than once.
+//
+// A dropped record is still considered consumed: the consumer advances past
+// it, and group commits will commit past it as if it had been polled. If every
+// record in a fetch is dropped, polling may return partitions with no records
+// so that commits can advance.
+type ConsumeInterceptor interface {
+	// InterceptConsume is called with each record consumed. Returning
+	// false drops the record.
+	InterceptConsume(*Record) bool
+}
+
+// ProduceInterceptor intercepts records passed to Produce before they are
+// buffered and partitioned.
+//
+// Interceptors can modify any field of a record (for example, to add tracing
+// headers or to encrypt the value), or can reject the record by returning an
+// error. Interceptors are called in the order they are configured; once a
+// record is rejected, later interceptors are not called and the record's
+// promise is called with the interceptor's error.
+//
+// Interceptors run before any HookProduceRecordBuffered hook, meaning hooks
+// observe the record after it has been intercepted. A rejected record is never
+// buffered: neither HookProduceRecordBuffered nor HookProduceRecordUnbuffered
+// are called for it.
+//
+// Interceptors are called in the goroutine that calls Produce, and must be
+// safe for concurrent use if Produce is called concurrently.
+type ProduceInterceptor interface {
+	// InterceptProduce is called with each record being produced.
+	// Returning a non-nil error rejects the record.
+	InterceptProduce(*Record) error
+}
+
+// interceptProduce runs the record through all interceptors, returning the
+// first error encountered.
+func interceptProduce(interceptors []ProduceInterceptor, r *Record) error {
+	for _, interceptor := range interceptors {
+		if err := interceptor.InterceptProduce(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// interceptFetch runs every record in a fetch that is about to be buffered
+// through its partition's consume interceptors, removing dropped records.
+func interceptFetch(f *Fetch, used usedOffsets) {
+	for i := range f.Topics {
+		t := &f.Topics[i]
+		for j := range t.Partitions {
+			p := &t.Partitions[j]
+			o := used[t.Topic][p.Partition]
+			if o == nil || len(o.from.interceptors) == 0 {
+				continue
+			}
+			kept := p.Records[:0]
+			for _, r := range p.Records {
+				if interceptConsume(o.from.interceptors, r) {
+					kept = append(kept, r)
+					p.droppedNext = EpochOffset{}
+				} else {
+					// If the interceptor dropped this record, we
+					// still want commits to advance past it. This
+					// is cleared if we keep any later record.
+					p.droppedNext = EpochOffset{r.LeaderEpoch, r.Offset + 1}
+				}
+			}
+			p.Records = kept
+		}
+	}
+}
+
+// interceptConsume runs the record through all interceptors, returning
+// whether the record should be kept.
+func interceptConsume(interceptors []ConsumeInterceptor, r *Record) bool {
+	for _, interceptor := range interceptors {
+		if !interceptor.InterceptConsume(r) {
+			return false
+		}
+	}
+	return true
+}
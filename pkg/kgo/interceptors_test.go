@@ -0,0 +1,91 @@
+package kgo
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type dropPoison struct{}
+
+func (dropPoison) InterceptConsume(r *Record) bool {
+	if bytes.Equal(r.Value, []byte("poison")) {
+		return false
+	}
+	r.Value = bytes.ToUpper(r.Value)
+	return true
+}
+
+func TestConsumeInterceptors(t *testing.T) {
+	o := &cursorOffsetNext{
+		from: &cursor{topic: "foo", interceptors: []ConsumeInterceptor{dropPoison{}}},
+	}
+	used := usedOffsets{"foo": {0: o}}
+	fetch := func(values ...string) *FetchPartition {
+		f := Fetch{Topics: []FetchTopic{{Topic: "foo", Partitions: []FetchPartition{{}}}}}
+		fp := &f.Topics[0].Partitions[0]
+		for _, v := range values {
+			o.maybeKeepRecord(fp, &Record{Offset: o.offset, Value: []byte(v)}, false)
+		}
+		interceptFetch(&f, used)
+		return fp
+	}
+
+	fp := fetch("foo", "poison", "bar", "poison")
+	if len(fp.Records) != 2 || string(fp.Records[0].Value) != "FOO" || string(fp.Records[1].Value) != "BAR" {
+		t.Fatalf("got unexpected records %v", fp.Records)
+	}
+	if o.offset != 4 {
+		t.Errorf("got cursor offset %d, expected 4", o.offset)
+	}
+	if head, ok := fp.polledHead(); !ok || head.Offset != 4 {
+		t.Errorf("got polled head %v (ok? %v), expected 4", head, ok)
+	}
+
+	// Keeping a later record clears our dropped tracking.
+	fp = fetch("poison", "baz")
+	if fp.droppedNext.Offset != 0 {
+		t.Errorf("got dropped next %d after keeping, expected 0", fp.droppedNext.Offset)
+	}
+	if head, ok := fp.polledHead(); !ok || head.Offset != 6 {
+		t.Errorf("got polled head %v (ok? %v), expected 6", head, ok)
+	}
+}
+
+type stampHeader struct{ calls *int }
+
+func (s stampHeader) InterceptProduce(r *Record) error {
+	*s.calls++
+	r.Headers = append(r.Headers, RecordHeader{Key: "trace", Value: []byte("id")})
+	return nil
+}
+
+type rejectEmpty struct{}
+
+func (rejectEmpty) InterceptProduce(r *Record) error {
+	if len(r.Value) == 0 {
+		return errors.New("empty value")
+	}
+	return nil
+}
+
+func TestProduceInterceptors(t *testing.T) {
+	var calls int
+	interceptors := []ProduceInterceptor{rejectEmpty{}, stampHeader{&calls}}
+
+	r := &Record{Value: []byte("foo")}
+	if err := interceptProduce(interceptors, r); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(r.Headers) != 1 || r.Headers[0].Key != "trace" {
+		t.Errorf("got headers %v, expected one trace header", r.Headers)
+	}
+
+	// A rejection stops later interceptors from running.
+	if err := interceptProduce(interceptors, new(Record)); err == nil {
+		t.Error("expected rejection of empty record")
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls to later interceptor, expected 1", calls)
+	}
+}
@@ -400,6 +400,7 @@ func (cl *Client) fetchTopicMetadata(all bool, reqTopics []string) (map[string]*
 					topic:     topicMeta.Topic,
 					partition: partMeta.Partition,
 
+					topicCfg:            cl.producerTopicCfg(topicMeta.Topic),
 					maxRecordBatchBytes: cl.maxRecordBatchBytesForTopic(topicMeta.Topic),
 
 					recBufsIdx: -1,
@@ -407,16 +408,19 @@ func (cl *Client) fetchTopicMetadata(all bool, reqTopics []string) (map[string]*
 				},
 
 				cursor: &cursor{
-					topic:       topicMeta.Topic,
-					topicID:     topicMeta.TopicID,
-					partition:   partMeta.Partition,
-					keepControl: cl.cfg.keepControl,
-					cursorsIdx:  -1,
+					topic:        topicMeta.Topic,
+					topicID:      topicMeta.TopicID,
+					partition:    partMeta.Partition,
+					keepControl:  cl.cfg.keepControl,
+					interceptors: cl.cfg.consumeInterceptors,
+					chunks:       newChunkReassembler(cl),
+					cursorsIdx:   -1,
 
 					cursorOffset: cursorOffset{
 						offset:            -1, // required to not consume until needed
 						lastConsumedEpoch: -1, // required sentinel
 					},
+					consumed: -1,
 				},
 			}
 
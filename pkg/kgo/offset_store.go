@@ -0,0 +1,194 @@
+package kgo
+
+import (
+	"context"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// OffsetStore loads and persists consumer offsets outside of Kafka.
+//
+// By default, group consumers load starting offsets with OffsetFetch and
+// persist progress with OffsetCommit, and direct consumers start at the
+// configured reset offset and persist nothing. With an OffsetStore, both
+// group and direct consumers load starting offsets from the store, and all
+// commits go to the store. Group consumers still use Kafka for group
+// membership and partition assignment.
+//
+// This is useful for exactly-once processing into an external system: the
+// output and offsets can be written in one transaction in that system, and
+// the store loads the offsets from the same place.
+//
+// Direct consumers do not autocommit and do not track uncommitted offsets, so
+// their progress is only stored by calling CommitRecords, CommitOffsets, or
+// CommitOffsetsSync with what to store. CommitUncommittedOffsets stores
+// nothing for a direct consumer.
+//
+// Group consumers pass their group to each method; direct consumers pass an
+// empty group.
+type OffsetStore interface {
+	// LoadOffsets returns the offsets to begin consuming the given
+	// partitions at. An offset is the next offset to consume, i.e., the
+	// last processed offset plus one. Partitions that have no stored
+	// offset can be left out, and are consumed from the configured
+	// ConsumeResetOffset.
+	//
+	// For group consumers, this is called after every assignment with
+	// the newly assigned partitions, and an error fails the assignment
+	// the same as an OffsetFetch failure. For direct consumers, this is
+	// called when new partitions are discovered, and on error the
+	// partitions are retried on the next metadata update.
+	LoadOffsets(ctx context.Context, group string, partitions map[string][]int32) (map[string]map[int32]EpochOffset, error)
+
+	// StoreOffsets persists offsets. This is called in place of an
+	// OffsetCommit request: for group consumers, from autocommitting,
+	// the default OnRevoked, and all Commit functions on the client, and
+	// for direct consumers, from CommitRecords, CommitOffsets, and
+	// CommitOffsetsSync. An error fails the entire commit.
+	StoreOffsets(ctx context.Context, group string, offsets map[string]map[int32]EpochOffset) error
+}
+
+// storeCommit persists the offsets in an OffsetCommitRequest to the store,
+// returning a response as if the request was issued to Kafka successfully.
+func storeCommit(ctx context.Context, store OffsetStore, group string, req *kmsg.OffsetCommitRequest) (*kmsg.OffsetCommitResponse, error) {
+	offsets := make(map[string]map[int32]EpochOffset, len(req.Topics))
+	resp := &kmsg.OffsetCommitResponse{
+		Version: req.Version,
+		Topics:  make([]kmsg.OffsetCommitResponseTopic, 0, len(req.Topics)),
+	}
+	for i := range req.Topics {
+		reqTopic := &req.Topics[i]
+		topicOffsets := make(map[int32]EpochOffset, len(reqTopic.Partitions))
+		offsets[reqTopic.Topic] = topicOffsets
+
+		respTopic := kmsg.OffsetCommitResponseTopic{
+			Topic:      reqTopic.Topic,
+			Partitions: make([]kmsg.OffsetCommitResponseTopicPartition, 0, len(reqTopic.Partitions)),
+		}
+		for j := range reqTopic.Partitions {
+			reqPart := &reqTopic.Partitions[j]
+			topicOffsets[reqPart.Partition] = EpochOffset{
+				Epoch:  reqPart.LeaderEpoch,
+				Offset: reqPart.Offset,
+			}
+			respTopic.Partitions = append(respTopic.Partitions, kmsg.OffsetCommitResponseTopicPartition{
+				Partition: reqPart.Partition,
+			})
+		}
+		resp.Topics = append(resp.Topics, respTopic)
+	}
+
+	if err := store.StoreOffsets(ctx, group, offsets); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// directCommit stores offsets for a direct consumer; this is what the commit
+// functions use if the client is not consuming as a group but has an offset
+// store.
+func (cl *Client) directCommit(
+	ctx context.Context,
+	uncommitted map[string]map[int32]EpochOffset,
+	onDone func(*Client, *kmsg.OffsetCommitRequest, *kmsg.OffsetCommitResponse, error),
+) {
+	req := new(kmsg.OffsetCommitRequest)
+	for topic, partitions := range uncommitted {
+		req.Topics = append(req.Topics, kmsg.OffsetCommitRequestTopic{
+			Topic: topic,
+		})
+		reqTopic := &req.Topics[len(req.Topics)-1]
+		for partition, eo := range partitions {
+			reqTopic.Partitions = append(reqTopic.Partitions, kmsg.OffsetCommitRequestTopicPartition{
+				Partition:   partition,
+				Offset:      eo.Offset,
+				LeaderEpoch: eo.Epoch,
+			})
+		}
+	}
+	resp, err := storeCommit(ctx, cl.cfg.offsetStore, "", req)
+	onDone(cl, req, resp, err)
+}
+
+// loadStoredOffsets, called without the consumer mu when a direct consumer
+// finds new partitions to consume, loads any stored offsets for the new
+// partitions. The store is user code that may be slow, so we do not want to
+// block the consumer while it runs.
+func loadStoredOffsets(ctx context.Context, store OffsetStore, toUse map[string]map[int32]Offset) (map[string]map[int32]EpochOffset, error) {
+	partitions := make(map[string][]int32, len(toUse))
+	for topic, topicOffsets := range toUse {
+		for partition := range topicOffsets {
+			partitions[topic] = append(partitions[topic], partition)
+		}
+	}
+	return store.LoadOffsets(ctx, "", partitions)
+}
+
+// assignStoredOffsets loads stored offsets for partitions a direct consumer
+// just found and assigns the partitions, under the consumer mu, if we are
+// still consuming with d. If loading fails, we immediately trigger a metadata
+// update to find the partitions again.
+func (c *consumer) assignStoredOffsets(d *directConsumer, new map[string]map[int32]Offset) {
+	stored, err := loadStoredOffsets(c.cl.ctx, c.cl.cfg.offsetStore, new)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.d != d {
+		return // we stopped consuming while loading
+	}
+	if new = d.applyStoredOffsets(new, stored, err); len(new) > 0 {
+		c.assignPartitions(new, assignWithoutInvalidating, d.tps)
+	}
+	if err != nil {
+		c.cl.triggerUpdateMetadataNow()
+	}
+}
+
+// applyStoredOffsets, called under the consumer mu after loadStoredOffsets,
+// overrides the default starting offsets with any stored offsets. Partitions
+// that were removed while we were loading are dropped.
+//
+// If loading failed, the new partitions are removed from d.using so that a
+// metadata update finds them again, and this returns nil.
+func (d *directConsumer) applyStoredOffsets(toUse map[string]map[int32]Offset, stored map[string]map[int32]EpochOffset, err error) map[string]map[int32]Offset {
+	if err != nil {
+		d.cfg.logger.Log(LogLevelError, "unable to load stored offsets for new partitions, retrying after a metadata update", "err", err)
+		for topic, topicOffsets := range toUse {
+			topicUsing := d.using[topic]
+			for partition := range topicOffsets {
+				delete(topicUsing, partition)
+			}
+			if len(topicUsing) == 0 {
+				delete(d.using, topic)
+			}
+		}
+		return nil
+	}
+
+	for topic, topicOffsets := range toUse {
+		topicUsing := d.using[topic]
+		for partition := range topicOffsets {
+			if _, exists := topicUsing[partition]; !exists {
+				delete(topicOffsets, partition)
+			}
+		}
+		if len(topicOffsets) == 0 {
+			delete(toUse, topic)
+		}
+	}
+
+	for topic, topicStored := range stored {
+		topicOffsets := toUse[topic]
+		for partition, eo := range topicStored {
+			if _, exists := topicOffsets[partition]; !exists {
+				continue // the store returned something we did not ask for
+			}
+			topicOffsets[partition] = Offset{
+				at:    eo.Offset,
+				epoch: eo.Epoch,
+			}
+		}
+	}
+	return toUse
+}
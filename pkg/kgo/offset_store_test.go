@@ -0,0 +1,108 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+type memOffsetStore struct {
+	offsets map[string]map[int32]EpochOffset
+	err     error
+}
+
+func (s *memOffsetStore) LoadOffsets(_ context.Context, _ string, partitions map[string][]int32) (map[string]map[int32]EpochOffset, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.offsets, nil
+}
+
+func (s *memOffsetStore) StoreOffsets(_ context.Context, _ string, offsets map[string]map[int32]EpochOffset) error {
+	if s.err != nil {
+		return s.err
+	}
+	for topic, partitions := range offsets {
+		if s.offsets[topic] == nil {
+			s.offsets[topic] = make(map[int32]EpochOffset)
+		}
+		for partition, eo := range partitions {
+			s.offsets[topic][partition] = eo
+		}
+	}
+	return nil
+}
+
+func TestOffsetStoreDirect(t *testing.T) {
+	store := &memOffsetStore{offsets: make(map[string]map[int32]EpochOffset)}
+	cfg := defaultCfg()
+	cfg.offsetStore = store
+	cl := &Client{cfg: cfg}
+
+	// Committing as a direct consumer goes to the store.
+	var resp *kmsg.OffsetCommitResponse
+	cl.CommitOffsetsSync(context.Background(), map[string]map[int32]EpochOffset{
+		"foo": {0: {1, 10}},
+	}, func(_ *Client, _ *kmsg.OffsetCommitRequest, r *kmsg.OffsetCommitResponse, err error) {
+		if err != nil {
+			t.Errorf("unexpected commit err: %v", err)
+		}
+		resp = r
+	})
+	if store.offsets["foo"][0] != (EpochOffset{1, 10}) {
+		t.Errorf("got stored %v, expected foo p0 at epoch 1 offset 10", store.offsets)
+	}
+	if resp == nil || len(resp.Topics) != 1 || len(resp.Topics[0].Partitions) != 1 || resp.Topics[0].Partitions[0].ErrorCode != 0 {
+		t.Errorf("got unexpected synthesized response %v", resp)
+	}
+
+	// New partitions begin at stored offsets, or the default if unstored.
+	d := &directConsumer{cfg: &cl.cfg, using: map[string]map[int32]struct{}{"foo": {0: {}, 1: {}}}}
+	load := func() map[string]map[int32]Offset {
+		toUse := map[string]map[int32]Offset{
+			"foo": {0: NewOffset(), 1: NewOffset()},
+		}
+		stored, err := loadStoredOffsets(context.Background(), store, toUse)
+		return d.applyStoredOffsets(toUse, stored, err)
+	}
+	toUse := load()
+	if got := toUse["foo"][0]; got.at != 10 || got.epoch != 1 {
+		t.Errorf("got foo p0 %v, expected stored offset 10 at epoch 1", got)
+	}
+	if got := toUse["foo"][1]; got != NewOffset() {
+		t.Errorf("got foo p1 %v, expected the default offset", got)
+	}
+
+	// Partitions removed while loading are not consumed.
+	delete(d.using["foo"], 1)
+	if toUse := load(); len(toUse["foo"]) != 1 {
+		t.Errorf("got %v after removing foo p1 while loading, expected only foo p0", toUse)
+	}
+
+	// On error, we forget the partitions so that they are retried.
+	store.err = errors.New("down")
+	if toUse := load(); toUse != nil {
+		t.Errorf("got %v on load error, expected nil", toUse)
+	}
+	if len(d.using) != 0 {
+		t.Errorf("got using %v after load error, expected empty", d.using)
+	}
+
+	// A failed background load immediately triggers a metadata update so
+	// that the partitions are found again.
+	cl.updateMetadataNowCh = make(chan struct{}, 1)
+	cl.consumer.cl = cl
+	cl.consumer.d = d
+	d.using["foo"] = map[int32]struct{}{0: {}}
+	cl.consumer.assignStoredOffsets(d, map[string]map[int32]Offset{"foo": {0: NewOffset()}})
+	if len(d.using) != 0 {
+		t.Errorf("got using %v after background load error, expected empty", d.using)
+	}
+	select {
+	case <-cl.updateMetadataNowCh:
+	default:
+		t.Error("background load error did not trigger a metadata update")
+	}
+}
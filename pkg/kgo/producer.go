@@ -31,6 +31,19 @@ type producer struct {
 	unknownTopics   map[string]*unknownTopicProduces
 
 	bufferedRecords int64
+	bufferedBytes   int64
+
+	// If producing is blocked by MaxBufferedBytes, produces wait on
+	// bufferedBytesDrained, which is closed whenever bytes are unbuffered.
+	bufferedBytesMu      sync.Mutex
+	bufferedBytesDrained chan struct{}
+
+	// limiter, if non-nil, limits the client wide produce rate.
+	limiter *rateLimiter
+
+	// spill, if non-nil, persists records to disk that cannot be buffered
+	// or produced; see ProducerSpillDir.
+	spill *spillBuffer
 
 	id           atomic.Value
 	producingTxn uint32 // 1 if in txn
@@ -40,6 +53,12 @@ type producer struct {
 	// recBuf could be created and records sent to while we are flushing.
 	flushing int32 // >0 if flushing, can Flush many times concurrently
 
+	// flushingTargets is >0 while FlushTopics or FlushPartitions is
+	// waiting. While so, finishing records bumps notifyGen (under
+	// notifyMu) and broadcasts on notifyCond.
+	flushingTargets int32
+	notifyGen       uint64
+
 	aborting int32 // >0 if aborting, can abort many times concurrently
 
 	idMu       sync.Mutex
@@ -60,6 +79,8 @@ type producer struct {
 // This can be used as a gauge to determine how far behind the client is for
 // flushing records produced by your client (which can help determine network /
 // cluster health).
+//
+// To see the number of bytes buffered, use BufferedProduceBytes.
 func (cl *Client) BufferedProduceRecords() int64 {
 	return atomic.LoadInt64(&cl.producer.bufferedRecords)
 }
@@ -80,6 +101,7 @@ func (p *producer) init(cl *Client) {
 		err:   errReloadProducerID,
 	})
 	p.notifyCond = sync.NewCond(&p.notifyMu)
+	p.limiter = newRateLimiter(cl.cfg.produceRecordsPerSec, cl.cfg.produceBytesPerSec)
 
 	inithooks := func() {
 		if p.hooks == nil {
@@ -252,10 +274,19 @@ func (f *FirstErrPromise) Err() error {
 // request, the promise will be called with kerr.MessageTooLarge and there will
 // be no attempt to produce the record.
 //
+// If the client is configured with ProduceInterceptors, the interceptors are
+// run on the record before it is buffered. If an interceptor rejects the
+// record, the promise is called with the interceptor's error.
+//
+// If the client is configured with ProducerChunkBytes and the record's value
+// is larger than the chunk size, the record is split into chunks, each of
+// which is buffered as its own record. The promise is called once, after
+// every chunk is finished.
+//
 // The context is used if the client currently has the max amount of buffered
-// records. If so, the client waits for some records to complete or for the
-// context or client to quit. If the context / client quits, the promise is
-// called with ctx.Err().
+// records or bytes. If so, the client waits for some records to complete or
+// for the context or client to quit. If the context / client quits, the
+// promise is called with ctx.Err().
 //
 // The context is also used on a per-partition basis to abort buffered records.
 // If the context is done for the first record buffered in a partition, and if
@@ -278,6 +309,16 @@ func (f *FirstErrPromise) Err() error {
 // If the client is transactional and a transaction has not been begun, the
 // promise is immediately called with an error corresponding to not being in
 // a transaction.
+//
+// If ProduceRateLimit or TopicProduceRateLimit is used and the record would
+// exceed a limit, this blocks until the record is allowed. If the context is
+// canceled or the client is closed while waiting, the promise is called with
+// the context's error or ErrClientClosed.
+//
+// If ProducerSpillDir is used, records that cannot be buffered in memory are
+// persisted to disk rather than blocking, and records that fail because
+// brokers are unavailable are persisted and retried rather than failed. See
+// ProducerSpillDir for more details.
 func (cl *Client) Produce(
 	ctx context.Context,
 	r *Record,
@@ -303,6 +344,55 @@ func (cl *Client) Produce(
 		return
 	}
 
+	// Interceptors run before we consider the record buffered, so that
+	// buffered hooks see the intercepted record. An interceptor could
+	// clear the topic, in which case we fail the record the same as above.
+	if len(cl.cfg.produceInterceptors) > 0 {
+		if err := interceptProduce(cl.cfg.produceInterceptors, r); err != nil {
+			go promise(r, err)
+			return
+		}
+		if r.Topic == "" {
+			go promise(r, errors.New("cannot produce to a record that does not have a topic set"))
+			return
+		}
+	}
+
+	if err := cl.waitProduceRateLimit(ctx, r); err != nil {
+		go promise(r, err)
+		return
+	}
+
+	if s := p.spill; s != nil {
+		if s.maybeSpill(ctx, r, promise) {
+			return
+		}
+		promise = s.spillOnFailure(promise)
+	}
+
+	cl.produceRecord(ctx, r, promise)
+}
+
+// produceRecord produces a record that has passed the checks and interceptors
+// in Produce, splitting it into chunks if necessary.
+func (cl *Client) produceRecord(ctx context.Context, r *Record, promise func(*Record, error)) {
+	if cl.cfg.produceChunkBytes > 0 && len(r.Value) > int(cl.cfg.produceChunkBytes) {
+		cl.produceChunks(ctx, r, promise)
+		return
+	}
+
+	cl.produce(promisedRec{ctx, promise, r, nil, 0})
+}
+
+// produce buffers and partitions a record that has passed the checks and
+// interceptors in Produce.
+func (cl *Client) produce(pr promisedRec) {
+	var (
+		p   = &cl.producer
+		ctx = pr.ctx
+		r   = pr.Record
+	)
+
 	// Our record is now "buffered", and past this point will fall into
 	// finishRecordPromise, where we track it is finished.
 	if p.hooks != nil {
@@ -325,9 +415,13 @@ func (cl *Client) Produce(
 		// could be consuming from a channel that is sent to in the
 		// promise only *after* Produce returns; not executing the
 		// promise in a goroutine would lead to a deadlock.
+		//
+		// The record's bytes have not been counted yet, so pr has no
+		// bufferedBytes for finishRecordPromise to un-count.
 		drainBuffered := func(err error) {
 			go func() { <-p.waitBuffer }()
-			go cl.finishRecordPromise(promisedRec{ctx, promise, r}, err)
+			pr.failChunk(err)
+			go cl.finishRecordPromise(pr, err)
 		}
 		if cl.cfg.manualFlushing {
 			drainBuffered(ErrMaxBuffered)
@@ -344,7 +438,18 @@ func (cl *Client) Produce(
 		}
 	}
 
-	cl.partitionRecord(promisedRec{ctx, promise, r})
+	// With a record slot, we now wait for space for our bytes. Bytes are
+	// counted even on error, and finishing the record un-counts them. We
+	// save the size now so that we un-count exactly what we counted, even
+	// if the record is modified in a promise.
+	pr.bufferedBytes = recordBufferedBytes(r)
+	if err := p.bufferBytes(ctx, cl, pr.bufferedBytes); err != nil {
+		pr.failChunk(err)
+		go cl.finishRecordPromise(pr, err)
+		return
+	}
+
+	cl.partitionRecord(pr)
 }
 
 func (cl *Client) finishRecordPromise(pr promisedRec, err error) {
@@ -361,6 +466,7 @@ func (cl *Client) finishRecordPromise(pr promisedRec, err error) {
 	// before Flush returns.
 	pr.promise(pr.Record, err)
 
+	p.unbufferBytes(cl, pr.bufferedBytes)
 	buffered := atomic.AddInt64(&p.bufferedRecords, -1)
 	if buffered >= cl.cfg.maxBufferedRecords {
 		go func() { p.waitBuffer <- struct{}{} }()
@@ -369,6 +475,7 @@ func (cl *Client) finishRecordPromise(pr promisedRec, err error) {
 		p.notifyMu.Unlock()
 		p.notifyCond.Broadcast()
 	}
+	p.maybeNotifyFlushTargets()
 }
 
 // partitionRecord loads the partitions for a topic and produce to them. If
@@ -392,6 +499,17 @@ func (cl *Client) doPartitionRecord(parts *topicPartitions, partsData *topicPart
 
 	parts.partsMu.Lock()
 	defer parts.partsMu.Unlock()
+
+	// If an earlier chunk of a split record failed before any chunk was
+	// partitioned, we fail this chunk rather than partitioning it on its
+	// own, which would split the record across partitions.
+	if c := pr.chunked; c != nil && c.partition < 0 {
+		if err := c.failed(); err != nil {
+			cl.finishRecordPromise(pr, fmt.Errorf("unable to produce chunk, an earlier chunk failed before it was partitioned: %w", err))
+			return
+		}
+	}
+
 	if parts.partitioner == nil {
 		parts.partitioner = cl.cfg.partitioner.ForTopic(pr.Topic)
 	}
@@ -405,6 +523,17 @@ func (cl *Client) doPartitionRecord(parts *topicPartitions, partsData *topicPart
 		return
 	}
 
+	// Every chunk of a split record goes to the partition of the first
+	// chunk, regardless of the partitioner.
+	if c := pr.chunked; c != nil && c.partition >= 0 {
+		if int(c.partition) >= len(partsData.partitions) {
+			cl.finishRecordPromise(pr, fmt.Errorf("unable to produce chunk to partition %d, which no longer exists", c.partition))
+			return
+		}
+		partsData.partitions[c.partition].records.bufferRecord(pr, false)
+		return
+	}
+
 	var pick int
 	tlp, _ := parts.partitioner.(TopicBackupPartitioner)
 	if tlp != nil {
@@ -443,6 +572,10 @@ func (cl *Client) doPartitionRecord(parts *topicPartitions, partsData *topicPart
 		partition = mapping[pick]
 		partition.records.bufferRecord(pr, false) // KIP-480
 	}
+
+	if pr.chunked != nil {
+		pr.chunked.partition = partition.records.partition
+	}
 }
 
 type producerID struct {
@@ -714,7 +847,7 @@ func (cl *Client) waitUnknownTopic(
 			}
 			cl.cfg.logger.Log(LogLevelInfo, "new topic metadata wait failed, retrying wait", "topic", topic, "err", retriableErr)
 			tries++
-			if int64(tries) >= cl.cfg.recordRetries {
+			if int64(tries) >= cl.producerTopicCfg(topic).recordRetries {
 				err = fmt.Errorf("no partitions available after attempting to refresh metadata %d times, last err: %w", tries, retriableErr)
 			}
 		}
@@ -786,7 +919,7 @@ func (cl *Client) Flush(ctx context.Context) error {
 	// linger because the producer's flushing atomic int32 is nonzero. We
 	// must wake anything that could be lingering up, after which all sinks
 	// will loop draining.
-	if cl.cfg.linger > 0 || cl.cfg.manualFlushing {
+	if cl.anyLinger() || cl.cfg.manualFlushing {
 		for _, parts := range p.topics.load() {
 			for _, part := range parts.load().partitions {
 				part.records.unlingerAndManuallyDrain()
@@ -818,6 +951,171 @@ func (cl *Client) Flush(ctx context.Context) error {
 	}
 }
 
+// FlushTopics hangs waiting for all buffered records for the given topics to
+// be flushed, stopping lingers for the topics' partitions if necessary. Unlike
+// Flush, this does not wait for records buffered for other topics.
+//
+// If a topic is not yet loaded, this waits for the topic's records to be
+// partitioned and then flushed.
+//
+// If the context finishes (Done), this returns the context's error.
+//
+// This function is safe to call multiple times concurrently, and safe to call
+// concurrent with Flush. Records produced while this is waiting are also
+// waited on.
+func (cl *Client) FlushTopics(ctx context.Context, topics ...string) error {
+	partitions := make(map[string][]int32, len(topics))
+	for _, topic := range topics {
+		partitions[topic] = nil
+	}
+	return cl.flushTargets(ctx, partitions)
+}
+
+// FlushPartitions hangs waiting for all buffered records for the given topic
+// partitions to be flushed, stopping lingers for the partitions if necessary.
+// Unlike Flush, this does not wait for records buffered for other partitions.
+//
+// If a topic is not yet loaded, this waits for the topic's records to be
+// partitioned, and then waits for any of the records that were partitioned to
+// the requested partitions.
+//
+// If the context finishes (Done), this returns the context's error.
+//
+// This function is safe to call multiple times concurrently, and safe to call
+// concurrent with Flush. Records produced while this is waiting are also
+// waited on.
+func (cl *Client) FlushPartitions(ctx context.Context, partitions map[string][]int32) error {
+	targets := make(map[string][]int32, len(partitions))
+	for topic, ps := range partitions {
+		if len(ps) > 0 { // nil would mean every partition
+			targets[topic] = ps
+		}
+	}
+	return cl.flushTargets(ctx, targets)
+}
+
+// flushTargets waits for the recBufs for the given topic partitions to have
+// no buffered records, where a nil partition slice means every partition in a
+// topic.
+func (cl *Client) flushTargets(ctx context.Context, targets map[string][]int32) error {
+	p := &cl.producer
+
+	atomic.AddInt32(&p.flushingTargets, 1)
+	defer atomic.AddInt32(&p.flushingTargets, -1)
+
+	cl.cfg.logger.Log(LogLevelInfo, "flushing partitions", "targets", targets)
+	defer cl.cfg.logger.Log(LogLevelDebug, "flushed partitions")
+
+	// Every recBuf we are waiting on is marked as flushing so that it
+	// will not begin lingering, and then we wake it up in case it was
+	// already lingering. We unmark everything once we are done.
+	marked := make(map[*recBuf]bool)
+	defer func() {
+		for recBuf := range marked {
+			atomic.AddInt32(&recBuf.flushing, -1)
+		}
+	}()
+
+	// done returns whether the targets are flushed, marking and waking
+	// any recBufs we have not yet seen. Partitions can be loaded while we
+	// are waiting, so we re-evaluate the targets every time.
+	//
+	// We cannot hold the notifyMu while checking unknown topics or
+	// waking recBufs, because records can be finished while holding
+	// those locks.
+	done := func() bool {
+		flushed := true
+
+		p.unknownTopicsMu.Lock()
+		for topic := range targets {
+			if unknown, exists := p.unknownTopics[topic]; exists && len(unknown.buffered) > 0 {
+				flushed = false
+			}
+		}
+		p.unknownTopicsMu.Unlock()
+
+		topics := p.topics.load()
+		for topic, partitions := range targets {
+			parts, exists := topics[topic]
+			if !exists {
+				continue
+			}
+			all := parts.load().partitions
+			check := func(recBuf *recBuf) {
+				if !marked[recBuf] {
+					marked[recBuf] = true
+					atomic.AddInt32(&recBuf.flushing, 1)
+					recBuf.unlingerAndManuallyDrain()
+				}
+				if atomic.LoadInt64(&recBuf.buffered) > 0 {
+					flushed = false
+				}
+			}
+			if partitions == nil {
+				for _, part := range all {
+					check(part.records)
+				}
+				continue
+			}
+			for _, partition := range partitions {
+				if partition >= 0 && int(partition) < len(all) {
+					check(all[partition].records)
+				}
+			}
+		}
+		return flushed
+	}
+
+	quit := false
+	waitDone := make(chan struct{})
+	go func() {
+		defer close(waitDone)
+		for {
+			p.notifyMu.Lock()
+			gen := p.notifyGen
+			p.notifyMu.Unlock()
+
+			if done() {
+				return
+			}
+
+			p.notifyMu.Lock()
+			for !quit && gen == p.notifyGen {
+				p.notifyCond.Wait()
+			}
+			stop := quit
+			p.notifyMu.Unlock()
+			if stop {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-waitDone:
+		return nil
+	case <-ctx.Done():
+		p.notifyMu.Lock()
+		quit = true
+		p.notifyMu.Unlock()
+		p.notifyCond.Broadcast()
+		<-waitDone // ensure we do not race unmarking recBufs
+		return ctx.Err()
+	}
+}
+
+// maybeNotifyFlushTargets wakes any FlushTopics or FlushPartitions waiting
+// for records to finish.
+func (p *producer) maybeNotifyFlushTargets() {
+	if atomic.LoadInt32(&p.flushingTargets) == 0 {
+		return
+	}
+	p.notifyMu.Lock()
+	p.notifyGen++
+	p.notifyMu.Unlock()
+	p.notifyCond.Broadcast()
+}
+
 // Bumps the tries for all buffered records in the client.
 //
 // This is called whenever there is a problematic error that would affect the
@@ -0,0 +1,80 @@
+package kgo
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// BufferedProduceBytes returns the number of bytes currently buffered for
+// producing within the client. This is the sum of all keys, values, and
+// headers of records buffered, and is the number that is compared against
+// MaxBufferedBytes.
+//
+// This is similar to BufferedProduceRecords.
+func (cl *Client) BufferedProduceBytes() int64 {
+	return atomic.LoadInt64(&cl.producer.bufferedBytes)
+}
+
+// unbufferBytes un-counts bytes for a finished record and wakes any produce
+// waiting for space.
+func (p *producer) unbufferBytes(cl *Client, n int64) {
+	atomic.AddInt64(&p.bufferedBytes, -n)
+	if cl.cfg.maxBufferedBytes == 0 {
+		return
+	}
+
+	// Any decrease could allow a waiting produce to fit, so we wake all
+	// waiters and let them recheck.
+	p.bufferedBytesMu.Lock()
+	defer p.bufferedBytesMu.Unlock()
+	if p.bufferedBytesDrained != nil {
+		close(p.bufferedBytesDrained)
+		p.bufferedBytesDrained = nil
+	}
+}
+
+// bufferBytes counts n bytes as buffered, waiting until the bytes fit within
+// MaxBufferedBytes. If we are buffering nothing, we always allow the bytes so
+// that a single record larger than the limit can still be produced.
+//
+// The bytes are always counted, even if this returns an error, so that the
+// subsequent finishRecordPromise can un-count them.
+func (p *producer) bufferBytes(ctx context.Context, cl *Client, n int64) error {
+	max := cl.cfg.maxBufferedBytes
+	if max == 0 {
+		atomic.AddInt64(&p.bufferedBytes, n)
+		return nil
+	}
+	for {
+		// We check and add under the mu so that concurrent produces
+		// cannot both fit into the same space.
+		p.bufferedBytesMu.Lock()
+		if now := atomic.LoadInt64(&p.bufferedBytes); now == 0 || now+n <= max {
+			atomic.AddInt64(&p.bufferedBytes, n)
+			p.bufferedBytesMu.Unlock()
+			return nil
+		}
+		if cl.cfg.manualFlushing {
+			atomic.AddInt64(&p.bufferedBytes, n)
+			p.bufferedBytesMu.Unlock()
+			return ErrMaxBuffered
+		}
+		if p.bufferedBytesDrained == nil {
+			p.bufferedBytesDrained = make(chan struct{})
+		}
+		drained := p.bufferedBytesDrained
+		p.bufferedBytesMu.Unlock()
+
+		var err error
+		select {
+		case <-drained:
+			continue
+		case <-cl.ctx.Done():
+			err = ErrClientClosed
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		atomic.AddInt64(&p.bufferedBytes, n)
+		return err
+	}
+}
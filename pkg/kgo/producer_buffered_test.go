@@ -0,0 +1,47 @@
+package kgo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBufferBytes(t *testing.T) {
+	cfg := defaultCfg()
+	cfg.maxBufferedBytes = 10
+	cl := &Client{cfg: cfg, ctx: context.Background()}
+	p := &cl.producer
+	ctx := context.Background()
+
+	// A record larger than our limit is allowed if nothing is buffered.
+	if err := p.bufferBytes(ctx, cl, 20); err != nil {
+		t.Fatalf("unexpected err buffering into empty buffer: %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := p.bufferBytes(canceled, cl, 1); err != context.Canceled {
+		t.Errorf("got err %v, expected context.Canceled", err)
+	}
+	if got := cl.BufferedProduceBytes(); got != 21 {
+		t.Errorf("got %d buffered bytes, expected 21 after failing", got)
+	}
+	p.unbufferBytes(cl, 1)
+
+	done := make(chan error)
+	go func() { done <- p.bufferBytes(ctx, cl, 5) }()
+	time.Sleep(10 * time.Millisecond)
+	p.unbufferBytes(cl, 20)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected err after unbuffering: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("produce was not woken after unbuffering")
+	}
+	if got := cl.BufferedProduceBytes(); got != 5 {
+		t.Errorf("got %d buffered bytes, expected 5", got)
+	}
+}
@@ -0,0 +1,86 @@
+package kgo
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlushTargets(t *testing.T) {
+	cl, _ := NewClient()
+	defer cl.Close()
+
+	// We fake two loaded topics with one buffered record each.
+	s := cl.newSink(0)
+	recBufs := make(map[string]*recBuf)
+	p := &cl.producer
+	p.topics.storeTopics([]string{"slow", "fast"})
+	for topic, parts := range p.topics.load() {
+		rb := &recBuf{cl: cl, topic: topic, sink: s, topicCfg: cl.defaultTopicCfg, buffered: 1}
+		recBufs[topic] = rb
+		parts.v.Store(&topicPartitionsData{partitions: []*topicPartition{{records: rb}}})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := cl.FlushTopics(ctx, "fast"); err != context.DeadlineExceeded {
+		t.Fatalf("got err %v while fast was buffered, expected deadline exceeded", err)
+	}
+	if atomic.LoadInt32(&recBufs["fast"].flushing) != 0 {
+		t.Error("recBuf was still marked flushing after the flush returned")
+	}
+
+	flushed := make(chan error, 1)
+	go func() { flushed <- cl.FlushPartitions(context.Background(), map[string][]int32{"fast": {0}}) }()
+
+	time.Sleep(10 * time.Millisecond)
+	atomic.StoreInt64(&recBufs["fast"].buffered, 0)
+	p.maybeNotifyFlushTargets()
+
+	select {
+	case err := <-flushed:
+		if err != nil {
+			t.Errorf("unexpected flush err: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("flush did not return once fast was flushed, while slow is still buffered")
+	}
+
+	// Flushing topics that we do not know of returns immediately.
+	if err := cl.FlushTopics(context.Background(), "unknown"); err != nil {
+		t.Errorf("unexpected flush err: %v", err)
+	}
+}
+
+func TestFlushTargetsManualFlushingDrainsOnlyTargets(t *testing.T) {
+	cl, _ := NewClient(ManualFlushing())
+	defer cl.Close()
+
+	s := cl.newSink(0)
+	recBufs := make(map[string]*recBuf)
+	for _, topic := range []string{"target", "other"} {
+		rb := &recBuf{cl: cl, topic: topic, sink: s, topicCfg: cl.defaultTopicCfg, buffered: 1}
+		batch := rb.newRecordBatch()
+		batch.records = append(batch.records, promisedNumberedRecord{
+			promisedRec: promisedRec{ctx: context.Background(), Record: &Record{Topic: topic}},
+		})
+		rb.batches = append(rb.batches, batch)
+		recBufs[topic] = rb
+		s.addRecBuf(rb)
+	}
+
+	// Only the target is being flushed, so only the target is drained.
+	atomic.AddInt32(&recBufs["target"].flushing, 1)
+	req, _, _ := s.createReq(-1, -1)
+
+	if _, exists := req.batches["target"]; !exists {
+		t.Error("target topic was not drained while flushing it")
+	}
+	if _, exists := req.batches["other"]; exists {
+		t.Error("non-target topic was drained while flushing only the target")
+	}
+	if recBufs["other"].batchDrainIdx != 0 {
+		t.Error("non-target topic did not stay buffered")
+	}
+}
@@ -0,0 +1,176 @@
+package kgo
+
+import (
+	"fmt"
+	"time"
+)
+
+// topicProducerOverrides are the producer settings overridden for a topic
+// with TopicProducerOverrides. Nil fields are not overridden.
+type topicProducerOverrides struct {
+	acks                *Acks
+	compression         *[]CompressionCodec
+	linger              *time.Duration
+	maxRecordBatchBytes *int32
+	recordRetries       *int64
+	rateLimit           *[2]float64 // records/sec, bytes/sec
+}
+
+// TopicProducerOpt is an option that overrides a producer setting for a
+// single topic; see TopicProducerOverrides.
+type TopicProducerOpt interface {
+	apply(*topicProducerOverrides)
+}
+
+type topicProducerOpt struct{ fn func(*topicProducerOverrides) }
+
+func (opt topicProducerOpt) apply(o *topicProducerOverrides) { opt.fn(o) }
+
+// TopicRequiredAcks overrides RequiredAcks for a topic.
+func TopicRequiredAcks(acks Acks) TopicProducerOpt {
+	return topicProducerOpt{func(o *topicProducerOverrides) { o.acks = &acks }}
+}
+
+// TopicBatchCompression overrides BatchCompression for a topic.
+func TopicBatchCompression(preference ...CompressionCodec) TopicProducerOpt {
+	return topicProducerOpt{func(o *topicProducerOverrides) { o.compression = &preference }}
+}
+
+// TopicLinger overrides Linger for a topic.
+func TopicLinger(linger time.Duration) TopicProducerOpt {
+	return topicProducerOpt{func(o *topicProducerOverrides) { o.linger = &linger }}
+}
+
+// TopicBatchMaxBytes overrides BatchMaxBytes for a topic.
+func TopicBatchMaxBytes(v int32) TopicProducerOpt {
+	return topicProducerOpt{func(o *topicProducerOverrides) { o.maxRecordBatchBytes = &v }}
+}
+
+// TopicRecordRetries overrides RecordRetries for a topic.
+func TopicRecordRetries(n int) TopicProducerOpt {
+	return topicProducerOpt{func(o *topicProducerOverrides) { n := int64(n); o.recordRetries = &n }}
+}
+
+// TopicProduceRateLimit limits how fast records can be produced to a topic, in
+// records per second and value, key, and header bytes per second. A zero rate
+// is unlimited. Unlike the other overrides, this does not replace
+// ProduceRateLimit: records must be allowed by both the client and the topic
+// limits.
+func TopicProduceRateLimit(recordsPerSec, bytesPerSec float64) TopicProducerOpt {
+	return topicProducerOpt{func(o *topicProducerOverrides) { o.rateLimit = &[2]float64{recordsPerSec, bytesPerSec} }}
+}
+
+// producerTopicCfg is the resolved producer configuration for a topic: the
+// client configuration with any topic overrides applied.
+type producerTopicCfg struct {
+	acks                Acks
+	compressor          *compressor
+	linger              time.Duration
+	maxRecordBatchBytes int32
+	recordRetries       int64
+
+	// limiter, if non-nil, limits the produce rate for this topic. This
+	// is never inherited from the default config.
+	limiter *rateLimiter
+}
+
+// validateTopicOverrides validates every topic's overrides against the same
+// limits as the client wide settings.
+func (cfg *cfg) validateTopicOverrides() error {
+	for topic, o := range cfg.topicProducerOverrides {
+		if o.acks != nil {
+			if !cfg.disableIdempotency && o.acks.val != -1 {
+				return fmt.Errorf("idempotency requires acks=all, but topic %q overrides acks to %d", topic, o.acks.val)
+			}
+			// Whether produce connections read responses at all
+			// depends on the client's acks, so we cannot mix no
+			// acks with acks.
+			if (o.acks.val == 0) != (cfg.acks.val == 0) {
+				return fmt.Errorf("topic %q acks override %d cannot mix no acks with the client acks %d", topic, o.acks.val, cfg.acks.val)
+			}
+		}
+		if o.linger != nil && *o.linger > time.Minute {
+			return fmt.Errorf("topic %q linger %v is larger than allowed %v", topic, *o.linger, time.Minute)
+		}
+		if o.maxRecordBatchBytes != nil {
+			switch v := *o.maxRecordBatchBytes; {
+			case v < 512:
+				return fmt.Errorf("topic %q max record batch bytes %v is less than allowed 512", topic, v)
+			case v > 268435454:
+				return fmt.Errorf("topic %q max record batch bytes %v is larger than allowed 268435454", topic, v)
+			case v > cfg.maxBrokerWriteBytes:
+				return fmt.Errorf("max broker write bytes %v is erroneously less than topic %q max record batch bytes %v", cfg.maxBrokerWriteBytes, topic, v)
+			}
+		}
+	}
+	return nil
+}
+
+// initProducerTopicCfgs resolves the client's default producer topic config
+// and the config for every topic with overrides.
+func (cl *Client) initProducerTopicCfgs() error {
+	cl.defaultTopicCfg = &producerTopicCfg{
+		acks:                cl.cfg.acks,
+		compressor:          cl.compressor,
+		linger:              cl.cfg.linger,
+		maxRecordBatchBytes: cl.cfg.maxRecordBatchBytes,
+		recordRetries:       cl.cfg.recordRetries,
+	}
+	if len(cl.cfg.topicProducerOverrides) == 0 {
+		return nil
+	}
+
+	cl.topicCfgs = make(map[string]*producerTopicCfg, len(cl.cfg.topicProducerOverrides))
+	for topic, o := range cl.cfg.topicProducerOverrides {
+		tcfg := *cl.defaultTopicCfg
+		if o.acks != nil {
+			tcfg.acks = *o.acks
+		}
+		if o.compression != nil {
+			c, err := newCompressor(*o.compression...)
+			if err != nil {
+				return fmt.Errorf("topic %q: %w", topic, err)
+			}
+			tcfg.compressor = c
+			if cl.topicCompressors == nil {
+				cl.topicCompressors = make(map[string]*compressor)
+			}
+			cl.topicCompressors[topic] = c
+		}
+		if o.linger != nil {
+			tcfg.linger = *o.linger
+		}
+		if o.maxRecordBatchBytes != nil {
+			tcfg.maxRecordBatchBytes = *o.maxRecordBatchBytes
+		}
+		if o.recordRetries != nil {
+			tcfg.recordRetries = *o.recordRetries
+		}
+		if o.rateLimit != nil {
+			tcfg.limiter = newRateLimiter(o.rateLimit[0], o.rateLimit[1])
+		}
+		cl.topicCfgs[topic] = &tcfg
+	}
+	return nil
+}
+
+// producerTopicCfg returns the producer configuration to use for a topic.
+func (cl *Client) producerTopicCfg(topic string) *producerTopicCfg {
+	if tcfg, exists := cl.topicCfgs[topic]; exists {
+		return tcfg
+	}
+	return cl.defaultTopicCfg
+}
+
+// anyLinger returns whether the client or any topic lingers.
+func (cl *Client) anyLinger() bool {
+	if cl.cfg.linger > 0 {
+		return true
+	}
+	for _, tcfg := range cl.topicCfgs {
+		if tcfg.linger > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package kgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTopicProducerOverrides(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		opts   []Opt
+		expErr bool
+	}{
+		{
+			name: "leader ack with idempotency",
+			opts: []Opt{TopicProducerOverrides("t", TopicRequiredAcks(LeaderAck()))},
+
+			expErr: true,
+		},
+		{
+			name: "leader ack without idempotency",
+			opts: []Opt{DisableIdempotentWrite(), TopicProducerOverrides("t", TopicRequiredAcks(LeaderAck()))},
+		},
+		{
+			name: "no ack mixed with acks",
+			opts: []Opt{DisableIdempotentWrite(), TopicProducerOverrides("t", TopicRequiredAcks(NoAck()))},
+
+			expErr: true,
+		},
+		{
+			name: "batch bytes too small",
+			opts: []Opt{TopicProducerOverrides("t", TopicBatchMaxBytes(10))},
+
+			expErr: true,
+		},
+		{
+			name: "linger too large",
+			opts: []Opt{TopicProducerOverrides("t", TopicLinger(time.Hour))},
+
+			expErr: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cl, err := NewClient(test.opts...)
+			if cl != nil {
+				cl.Close()
+			}
+			if gotErr := err != nil; gotErr != test.expErr {
+				t.Errorf("got err %v, expected err? %v", err, test.expErr)
+			}
+		})
+	}
+
+	cl, err := NewClient(
+		Linger(time.Second),
+		TopicProducerOverrides("telemetry",
+			TopicBatchCompression(ZstdCompression()),
+			TopicLinger(5*time.Second),
+		),
+		TopicProducerOverrides("telemetry", TopicRecordRetries(3)),
+		TopicProducerOverrides("events", TopicLinger(0), TopicBatchMaxBytes(512<<10)),
+	)
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer cl.Close()
+
+	if tcfg := cl.producerTopicCfg("telemetry"); tcfg.linger != 5*time.Second ||
+		tcfg.recordRetries != 3 ||
+		tcfg.compressor == nil || tcfg.compressor.options[0] != ZstdCompression().codec ||
+		tcfg.maxRecordBatchBytes != cl.cfg.maxRecordBatchBytes {
+		t.Errorf("telemetry: got unexpected resolved config %+v", tcfg)
+	}
+	if tcfg := cl.producerTopicCfg("events"); tcfg.linger != 0 ||
+		tcfg.maxRecordBatchBytes != 512<<10 ||
+		tcfg.compressor != cl.compressor ||
+		tcfg.recordRetries != cl.cfg.recordRetries {
+		t.Errorf("events: got unexpected resolved config %+v", tcfg)
+	}
+	if tcfg := cl.producerTopicCfg("other"); tcfg != cl.defaultTopicCfg {
+		t.Errorf("other: got %+v, expected the default config", tcfg)
+	}
+	if cl.topicCompressors["telemetry"] == nil || cl.topicCompressors["events"] != nil {
+		t.Error("topic compressors were not only set for the overridden topic")
+	}
+}
@@ -0,0 +1,275 @@
+package kgo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket limiter on both records per second and bytes
+// per second. A zero rate is unlimited.
+//
+// Buckets hold at most one second of tokens. Taking tokens can put a bucket in
+// debt, which allows a single record (or fetch) to be larger than the burst;
+// the debt is paid off by waiting.
+type rateLimiter struct {
+	mu      sync.Mutex
+	records tokenBucket
+	bytes   tokenBucket
+}
+
+type tokenBucket struct {
+	rate   float64 // tokens per second; 0 is unlimited
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a limiter, or nil if both rates are unlimited.
+func newRateLimiter(recordsPerSec, bytesPerSec float64) *rateLimiter {
+	if recordsPerSec <= 0 && bytesPerSec <= 0 {
+		return nil
+	}
+	now := time.Now()
+	return &rateLimiter{
+		records: tokenBucket{rate: recordsPerSec, tokens: recordsPerSec, last: now},
+		bytes:   tokenBucket{rate: bytesPerSec, tokens: bytesPerSec, last: now},
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if b.rate == 0 {
+		return
+	}
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+}
+
+// take takes n tokens and returns how long until the bucket is out of debt.
+func (b *tokenBucket) take(now time.Time, n float64) time.Duration {
+	if b.rate == 0 {
+		return 0
+	}
+	b.refill(now)
+	b.tokens -= n
+	return b.debt()
+}
+
+func (b *tokenBucket) debt() time.Duration {
+	if b.rate == 0 || b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// take takes tokens for records and bytes, returning how long to wait until
+// the taken tokens are paid for.
+func (l *rateLimiter) take(records, bytes int64) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	wait := l.records.take(now, float64(records))
+	if bwait := l.bytes.take(now, float64(bytes)); bwait > wait {
+		wait = bwait
+	}
+	return wait
+}
+
+// refund returns tokens that were taken but not used.
+func (l *rateLimiter) refund(records, bytes int64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.records.refill(now)
+	l.bytes.refill(now)
+	if l.records.rate > 0 {
+		l.records.tokens += float64(records)
+	}
+	if l.bytes.rate > 0 {
+		l.bytes.tokens += float64(bytes)
+	}
+}
+
+// delay returns how long until the limiter is out of debt.
+func (l *rateLimiter) delay() time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.records.refill(now)
+	l.bytes.refill(now)
+	wait := l.records.debt()
+	if bwait := l.bytes.debt(); bwait > wait {
+		wait = bwait
+	}
+	return wait
+}
+
+// validateRateLimits ensures no produce or consume rate is negative.
+func (cfg *cfg) validateRateLimits() error {
+	check := func(what string, recordsPerSec, bytesPerSec float64) error {
+		if recordsPerSec < 0 || bytesPerSec < 0 {
+			return fmt.Errorf("%s rate limit of %v records/s and %v bytes/s is invalid: rates cannot be negative", what, recordsPerSec, bytesPerSec)
+		}
+		return nil
+	}
+	if err := check("produce", cfg.produceRecordsPerSec, cfg.produceBytesPerSec); err != nil {
+		return err
+	}
+	if err := check("consume", cfg.consumeRecordsPerSec, cfg.consumeBytesPerSec); err != nil {
+		return err
+	}
+	for topic, o := range cfg.topicProducerOverrides {
+		if o.rateLimit != nil {
+			if err := check(fmt.Sprintf("topic %q produce", topic), o.rateLimit[0], o.rateLimit[1]); err != nil {
+				return err
+			}
+		}
+	}
+	for topic, limit := range cfg.consumeTopicRateLimits {
+		if err := check(fmt.Sprintf("topic %q consume", topic), limit[0], limit[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/////////////
+// PRODUCE //
+/////////////
+
+// waitProduceRateLimit waits until a record is allowed by the client's and
+// the record's topic's produce rate limits. If the context or client is done
+// before then, the record's tokens are refunded and this returns an error.
+func (cl *Client) waitProduceRateLimit(ctx context.Context, r *Record) error {
+	var (
+		client = cl.producer.limiter
+		topic  = cl.producerTopicCfg(r.Topic).limiter
+	)
+	if client == nil && topic == nil {
+		return nil
+	}
+
+	bytes := recordBufferedBytes(r)
+	wait := client.take(1, bytes)
+	if twait := topic.take(1, bytes); twait > wait {
+		wait = twait
+	}
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		client.refund(1, bytes)
+		topic.refund(1, bytes)
+		return ctx.Err()
+	case <-cl.ctx.Done():
+		client.refund(1, bytes)
+		topic.refund(1, bytes)
+		return ErrClientClosed
+	}
+}
+
+/////////////
+// CONSUME //
+/////////////
+
+// initRateLimits initializes the consumer's rate limiters.
+func (c *consumer) initRateLimits() {
+	cfg := &c.cl.cfg
+	c.limiter = newRateLimiter(cfg.consumeRecordsPerSec, cfg.consumeBytesPerSec)
+	for topic, limit := range cfg.consumeTopicRateLimits {
+		if l := newRateLimiter(limit[0], limit[1]); l != nil {
+			if c.topicLimiters == nil {
+				c.topicLimiters = make(map[string]*rateLimiter)
+			}
+			c.topicLimiters[topic] = l
+		}
+	}
+}
+
+// takeRateLimits charges every record in a fetch against our consume rate
+// limits. This does not wait; fetches are paced by waiting before fetching
+// again.
+func (c *consumer) takeRateLimits(f *Fetch) {
+	if c.limiter == nil && c.topicLimiters == nil {
+		return
+	}
+	for i := range f.Topics {
+		t := &f.Topics[i]
+		var records, bytes int64
+		for j := range t.Partitions {
+			for _, r := range t.Partitions[j].Records {
+				records++
+				bytes += recordBufferedBytes(r)
+			}
+		}
+		if records == 0 {
+			continue
+		}
+		c.limiter.take(records, bytes)
+		c.topicLimiters[t.Topic].take(records, bytes)
+	}
+}
+
+// waitRateLimit waits until the client wide consume rate limit is out of
+// debt, returning false if the context is canceled first.
+func (c *consumer) waitRateLimit(ctx context.Context) bool {
+	wait := c.limiter.delay()
+	if wait <= 0 {
+		return true
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// topicRateDelay returns how long until a topic's consume rate limit is out
+// of debt.
+func (c *consumer) topicRateDelay(topic string) time.Duration {
+	return c.topicLimiters[topic].delay()
+}
+
+// wakeAfterRateLimit triggers the source to consume once a rate limited
+// topic that was skipped when building a fetch request is out of debt. Only
+// the earliest wake is kept.
+func (s *source) wakeAfterRateLimit(wait time.Duration) {
+	s.rateWakeMu.Lock()
+	defer s.rateWakeMu.Unlock()
+
+	at := time.Now().Add(wait)
+	if s.rateWake != nil && !s.rateWakeAt.After(at) {
+		return
+	}
+	if s.rateWake != nil {
+		s.rateWake.Stop()
+	}
+	s.rateWakeAt = at
+	s.rateWake = time.AfterFunc(wait, func() {
+		s.rateWakeMu.Lock()
+		s.rateWake = nil
+		s.rateWakeMu.Unlock()
+		s.maybeConsume()
+	})
+}
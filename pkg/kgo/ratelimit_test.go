@@ -0,0 +1,119 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(10, 1000)
+
+	// We start with a full second of tokens.
+	if wait := l.take(10, 500); wait != 0 {
+		t.Errorf("got wait %v taking within the burst, expected 0", wait)
+	}
+
+	// Going into debt by 5 records (half a second) or 500 bytes (half a
+	// second) waits roughly half a second.
+	wait := l.take(5, 500)
+	if wait < 400*time.Millisecond || wait > 500*time.Millisecond {
+		t.Errorf("got wait %v after going into debt, expected about 500ms", wait)
+	}
+	if delay := l.delay(); delay <= 0 || delay > wait {
+		t.Errorf("got delay %v, expected in (0, %v]", delay, wait)
+	}
+
+	l.refund(5, 500)
+	if delay := l.delay(); delay != 0 {
+		t.Errorf("got delay %v after refunding, expected 0", delay)
+	}
+
+	if l := newRateLimiter(0, 0); l != nil {
+		t.Error("expected a nil limiter for unlimited rates")
+	}
+	var nilLimiter *rateLimiter
+	if wait := nilLimiter.take(1e9, 1e9); wait != 0 {
+		t.Errorf("got wait %v from an unlimited limiter, expected 0", wait)
+	}
+}
+
+func TestProduceRateLimitCanceled(t *testing.T) {
+	cl, err := NewClient(
+		DefaultProduceTopic("foo"),
+		TopicProducerOverrides("foo", TopicProduceRateLimit(1, 0)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	// Use the one record of burst, then wait for the next to be allowed
+	// with a context that is canceled quickly.
+	cl.waitProduceRateLimit(context.Background(), &Record{Topic: "foo"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	cl.Produce(ctx, &Record{}, func(_ *Record, err error) { done <- err })
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got err %v, expected context.DeadlineExceeded", err)
+	}
+
+	// The canceled record's token was refunded, taking the limiter back
+	// out of debt.
+	if delay := cl.producerTopicCfg("foo").limiter.delay(); delay != 0 {
+		t.Errorf("got delay %v, expected the canceled record to be refunded", delay)
+	}
+
+	if _, err := NewClient(ConsumeTopicRateLimit("foo", -1, 0)); err == nil {
+		t.Error("expected an error for a negative rate")
+	}
+}
+
+func TestConsumeRateLimitForgetsPartitions(t *testing.T) {
+	cl, err := NewClient(ConsumeTopicRateLimit("slow", 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	s := cl.newSource(0)
+	for i, topic := range []string{"slow", "fast"} {
+		cur := &cursor{topic: topic, source: s, useState: 1, cursorsIdx: i}
+		cur.leaderEpoch = -1
+		cur.cursorOffset = cursorOffset{offset: 5, lastConsumedEpoch: -1}
+		s.cursors = append(s.cursors, cur)
+	}
+	s.session = fetchSession{id: 1, epoch: 2, used: map[string]map[int32]fetchSessionOffsetEpoch{
+		"slow": {0: {5, -1}},
+		"fast": {0: {5, -1}},
+	}}
+
+	// We take well past the burst to put the slow topic into debt.
+	cl.consumer.topicLimiters["slow"].take(10, 0)
+
+	req := s.createReq()
+	if _, exists := req.usedOffsets["slow"]; exists {
+		t.Error("rate limited topic was fetched")
+	}
+	if _, exists := req.usedOffsets["fast"]; !exists {
+		t.Error("topic without a rate limit was not fetched")
+	}
+	if forgotten := req.forgotten["slow"]; len(forgotten) != 1 || forgotten[0] != 0 {
+		t.Errorf("got forgotten %v, expected the rate limited partition", req.forgotten)
+	}
+
+	// The next request neither fetches nor forgets the skipped partition
+	// again; the broker no longer has it in our session.
+	req.usedOffsets.finishUsingAll()
+	req = s.createReq()
+	if _, exists := req.usedOffsets["slow"]; exists {
+		t.Error("rate limited topic was fetched again")
+	}
+	if len(req.forgotten) != 0 {
+		t.Errorf("got forgotten %v, expected nothing new to forget", req.forgotten)
+	}
+}
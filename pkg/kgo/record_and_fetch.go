@@ -213,6 +213,44 @@ type FetchPartition struct {
 	LogStartOffset int64
 	// Records contains feched records for this partition.
 	Records []*Record
+
+	// droppedNext, if Offset is non-zero, is just past the last record
+	// dropped by a ConsumeInterceptor, where that record was after every
+	// record in Records. This allows commits to advance past dropped
+	// records.
+	droppedNext EpochOffset
+
+	// chunkHold, if chunkHeld, is the first chunk of the earliest
+	// incomplete split record in this partition when this fetch was
+	// processed. Commits must not advance past it.
+	chunkHold EpochOffset
+	chunkHeld bool
+}
+
+// polledHead returns the epoch and offset just past the final record consumed
+// in this partition, including records dropped by interceptors, and whether
+// anything was consumed at all. The head never passes an incomplete chunked
+// record.
+func (p *FetchPartition) polledHead() (EpochOffset, bool) {
+	var head EpochOffset
+	switch {
+	case p.droppedNext.Offset != 0:
+		head = p.droppedNext
+	case len(p.Records) > 0:
+		final := p.Records[len(p.Records)-1]
+		head = EpochOffset{
+			final.LeaderEpoch, // -1 if old message / unknown
+			final.Offset + 1,
+		}
+	case p.chunkHeld:
+		return p.chunkHold, true
+	default:
+		return EpochOffset{}, false
+	}
+	if p.chunkHeld && p.chunkHold.Offset < head.Offset {
+		head = p.chunkHold
+	}
+	return head, true
 }
 
 // FetchTopic is a response for a fetched topic from a broker.
@@ -283,7 +321,10 @@ func (f Fetch) hasErrorsOrRecords() bool {
 		t := &f.Topics[i]
 		for j := range t.Partitions {
 			p := &t.Partitions[j]
-			if p.Err != nil || len(p.Records) > 0 {
+			// If an interceptor dropped records or we consumed
+			// incomplete chunks, we still need to return this
+			// partition so that our cursor and commits advance.
+			if p.Err != nil || len(p.Records) > 0 || p.droppedNext.Offset != 0 || p.chunkHeld {
 				return true
 			}
 		}
@@ -307,6 +348,20 @@ func (fs Fetches) IsClientClosed() bool {
 	return false
 }
 
+// IsBoundedDone returns whether the fetches includes an error indicating that
+// the client, consuming with ConsumeBounded, has consumed every partition to
+// its snapshotted end offset.
+//
+// Like IsClientClosed, this function is useful to break out of a poll loop.
+func (fs Fetches) IsBoundedDone() bool {
+	for _, f := range fs {
+		if len(f.Topics) == 1 && len(f.Topics[0].Partitions) == 1 && f.Topics[0].Partitions[0].Err == ErrBoundedDone {
+			return true
+		}
+	}
+	return false
+}
+
 // EachError calls fn for every partition that had a fetch error with the
 // topic, partition, and error.
 //
@@ -0,0 +1,393 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// Headers added to records produced by a Retrier. The original topic,
+// partition, and offset are those of the record when it was first consumed,
+// and are preserved across every retry.
+const (
+	RetryHeaderOriginalTopic     = "kgo-retry-original-topic"
+	RetryHeaderOriginalPartition = "kgo-retry-original-partition"
+	RetryHeaderOriginalOffset    = "kgo-retry-original-offset"
+	RetryHeaderError             = "kgo-retry-error"
+	RetryHeaderAttempt           = "kgo-retry-attempt"
+	RetryHeaderRetryAt           = "kgo-retry-at" // unix milliseconds
+)
+
+// RetryTopic is a topic that failed records are produced to, and the delay
+// before a record in the topic is retried.
+type RetryTopic struct {
+	Topic string
+	Delay time.Duration
+}
+
+// Retrier routes failed records through retry topics with increasing delays,
+// and finally to a dead letter topic.
+//
+// A record that fails processing the first time is produced to the first
+// retry topic, a record that fails a second time is produced to the second
+// retry topic, and so on. Once a record has failed in every retry topic, it
+// is produced to the dead letter topic. Each produced record keeps the key,
+// value, and headers of the original record, and has the RetryHeader headers
+// set with the original topic, partition, and offset, the latest error, the
+// attempt count, and when the record should next be retried.
+//
+// Records in retry topics are processed with RunRetryConsumer.
+type Retrier struct {
+	cl      *Client
+	retries []RetryTopic
+	dlq     string
+
+	// The fields below are for RunRetryConsumer and the callbacks set in
+	// ConsumerOpts.
+	mu       sync.Mutex
+	assigned map[string]map[int32]struct{}
+	waiting  map[string]map[int32][]*Record
+	paused   map[string]map[int32]bool
+}
+
+// NewRetrier returns a Retrier that produces with the given client to the
+// retry topics in order, and then to the dead letter topic. Retry topics
+// should have increasing delays.
+func NewRetrier(cl *Client, dlq string, retries ...RetryTopic) *Retrier {
+	return &Retrier{
+		cl:      cl,
+		retries: retries,
+		dlq:     dlq,
+
+		assigned: make(map[string]map[int32]struct{}),
+		waiting:  make(map[string]map[int32][]*Record),
+		paused:   make(map[string]map[int32]bool),
+	}
+}
+
+// ConsumerOpts returns the options that must be used when creating the client
+// that RunRetryConsumer consumes with. The options set OnAssigned, OnRevoked,
+// and OnLost, and enable AutoCommitMarks; these options must not be
+// overridden.
+//
+// On revoke or loss, records waiting to be retried in the partitions are
+// dropped; they are consumed again by whichever member is next assigned the
+// partitions. On revoke, marked offsets are then committed, as the default
+// OnRevoked would.
+func (r *Retrier) ConsumerOpts() []Opt {
+	return []Opt{
+		OnAssigned(r.onAssigned),
+		OnRevoked(r.onRevoked),
+		OnLost(r.onLost),
+		AutoCommitMarks(),
+	}
+}
+
+func (r *Retrier) onAssigned(_ context.Context, _ *Client, assigned map[string][]int32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for topic, partitions := range assigned {
+		tassigned := r.assigned[topic]
+		if tassigned == nil {
+			tassigned = make(map[int32]struct{})
+			r.assigned[topic] = tassigned
+		}
+		for _, partition := range partitions {
+			tassigned[partition] = struct{}{}
+		}
+	}
+}
+
+func (r *Retrier) onRevoked(_ context.Context, cl *Client, revoked map[string][]int32) {
+	r.drop(cl, revoked)
+
+	// Similar to the default revoke, we use the client's context because
+	// the group context is canceled if we are leaving the group.
+	marked := cl.UncommittedOffsets()
+	if len(marked) == 0 {
+		return
+	}
+	cl.CommitOffsetsSync(cl.ctx, marked, func(_ *Client, _ *kmsg.OffsetCommitRequest, _ *kmsg.OffsetCommitResponse, err error) {
+		if err != nil {
+			cl.cfg.logger.Log(LogLevelError, "retry consumer unable to commit revoked partitions", "err", err)
+		}
+	})
+}
+
+func (r *Retrier) onLost(_ context.Context, cl *Client, lost map[string][]int32) {
+	r.drop(cl, lost) // committing would fail
+}
+
+// drop forgets partitions that are no longer assigned, dropping any records
+// waiting to be retried and resuming the partitions if we paused them. This
+// waits for any in progress processing to finish.
+func (r *Retrier) drop(cl *Client, dropping map[string][]int32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var resume map[string][]int32
+	for topic, partitions := range dropping {
+		for _, partition := range partitions {
+			delete(r.assigned[topic], partition)
+			delete(r.waiting[topic], partition)
+			if r.paused[topic][partition] {
+				delete(r.paused[topic], partition)
+				if resume == nil {
+					resume = make(map[string][]int32)
+				}
+				resume[topic] = append(resume[topic], partition)
+			}
+		}
+		if len(r.assigned[topic]) == 0 {
+			delete(r.assigned, topic)
+		}
+		if len(r.waiting[topic]) == 0 {
+			delete(r.waiting, topic)
+		}
+		if len(r.paused[topic]) == 0 {
+			delete(r.paused, topic)
+		}
+	}
+
+	// Pausing persists across assignments; if we did not resume, we would
+	// never fetch the partitions again if they are reassigned to us.
+	if len(resume) > 0 {
+		cl.ResumeFetchPartitions(resume)
+	}
+}
+
+// Fail produces a record that failed processing with err to the next retry
+// topic, or to the dead letter topic if the record has been retried in every
+// retry topic. This waits for the produce to complete and returns any produce
+// error; if this returns nil, the failed record can be committed.
+func (r *Retrier) Fail(ctx context.Context, rec *Record, err error) error {
+	return r.cl.ProduceSync(ctx, r.failed(rec, err, time.Now())).FirstErr()
+}
+
+// failed returns the record to produce for a record that failed with err.
+func (r *Retrier) failed(rec *Record, err error, now time.Time) *Record {
+	attempt := retryAttempt(rec) + 1
+
+	topic := r.dlq
+	var retryAt time.Time
+	if attempt <= len(r.retries) {
+		retry := r.retries[attempt-1]
+		topic = retry.Topic
+		retryAt = now.Add(retry.Delay)
+	}
+
+	origTopic, origPartition, origOffset := rec.Topic, strconv.Itoa(int(rec.Partition)), strconv.FormatInt(rec.Offset, 10)
+	headers := make([]RecordHeader, 0, len(rec.Headers)+6)
+	for _, h := range rec.Headers {
+		switch h.Key {
+		case RetryHeaderOriginalTopic:
+			origTopic = string(h.Value)
+		case RetryHeaderOriginalPartition:
+			origPartition = string(h.Value)
+		case RetryHeaderOriginalOffset:
+			origOffset = string(h.Value)
+		case RetryHeaderError, RetryHeaderAttempt, RetryHeaderRetryAt:
+		default:
+			headers = append(headers, h)
+		}
+	}
+	headers = append(headers,
+		RecordHeader{Key: RetryHeaderOriginalTopic, Value: []byte(origTopic)},
+		RecordHeader{Key: RetryHeaderOriginalPartition, Value: []byte(origPartition)},
+		RecordHeader{Key: RetryHeaderOriginalOffset, Value: []byte(origOffset)},
+		RecordHeader{Key: RetryHeaderError, Value: []byte(err.Error())},
+		RecordHeader{Key: RetryHeaderAttempt, Value: []byte(strconv.Itoa(attempt))},
+	)
+	if !retryAt.IsZero() {
+		headers = append(headers, RecordHeader{Key: RetryHeaderRetryAt, Value: []byte(strconv.FormatInt(retryAt.UnixNano()/1e6, 10))})
+	}
+
+	return &Record{
+		Key:     rec.Key,
+		Value:   rec.Value,
+		Headers: headers,
+		Topic:   topic,
+	}
+}
+
+// RunRetryConsumer consumes records from retry topics with the given client
+// until the context is canceled or the client is closed, calling process for
+// every record once the record is due to be retried. If process returns an
+// error, the record is failed again with Fail.
+//
+// The client must be configured to consume the retry topics as a group, and
+// must be created with the options from ConsumerOpts; if the client does not
+// use AutoCommitMarks, this returns an error immediately. Records in a
+// partition are retried in order. If the first record in a partition is not
+// yet due, the partition is paused until the record is due; no further
+// fetches are issued for the partition and this does not busy loop.
+//
+// Only once a record is processed or failed is it marked with
+// MarkCommitRecords, so records waiting to be retried are never committed.
+//
+// RunRetryConsumer should be called at most once per Retrier.
+func (r *Retrier) RunRetryConsumer(ctx context.Context, cl *Client, process func(context.Context, *Record) error) error {
+	if !cl.cfg.autocommitMarks {
+		return errors.New("retry consumer client must be created with the Retrier's ConsumerOpts, which enable AutoCommitMarks")
+	}
+
+	for {
+		r.mu.Lock()
+		next := r.processDue(ctx, cl, process)
+		r.mu.Unlock()
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		pollCtx, cancel := ctx, func() {}
+		if !next.IsZero() {
+			pollCtx, cancel = context.WithDeadline(ctx, next)
+		}
+		fetches := cl.PollFetches(pollCtx)
+		cancel()
+		if fetches.IsClientClosed() || ctx.Err() != nil {
+			return nil
+		}
+
+		fetches.EachError(func(t string, p int32, err error) {
+			if err != pollCtx.Err() {
+				cl.cfg.logger.Log(LogLevelError, "retry consumer fetch error", "topic", t, "partition", p, "err", err)
+			}
+		})
+
+		// The client invalidates buffered fetches before calling
+		// OnRevoked, so if a partition is no longer assigned, what we
+		// polled for it was polled before it was revoked.
+		r.mu.Lock()
+		fetches.EachPartition(func(p FetchTopicPartition) {
+			if len(p.Records) == 0 {
+				return
+			}
+			if _, assigned := r.assigned[p.Topic][p.Partition]; !assigned {
+				return
+			}
+			twaiting := r.waiting[p.Topic]
+			if twaiting == nil {
+				twaiting = make(map[int32][]*Record)
+				r.waiting[p.Topic] = twaiting
+			}
+			twaiting[p.Partition] = append(twaiting[p.Partition], p.Records...)
+		})
+		r.mu.Unlock()
+	}
+}
+
+// processDue, called under the mu, processes all due records, pauses
+// partitions that have records waiting, resumes partitions that no longer do,
+// and returns when the next waiting record is due (or the zero time if
+// nothing is waiting).
+func (r *Retrier) processDue(
+	ctx context.Context,
+	cl *Client,
+	process func(context.Context, *Record) error,
+) time.Time {
+	var (
+		waiting = r.waiting
+		paused  = r.paused
+
+		next     time.Time
+		toPause  map[string][]int32
+		toResume map[string][]int32
+	)
+	for topic, twaiting := range waiting {
+		for partition, rs := range twaiting {
+			for len(rs) > 0 && ctx.Err() == nil {
+				if at := retryAt(rs[0]); at.After(time.Now()) {
+					if next.IsZero() || at.Before(next) {
+						next = at
+					}
+					break
+				}
+				rec := rs[0]
+				rs = rs[1:]
+				if err := process(ctx, rec); err != nil {
+					if ferr := r.Fail(ctx, rec, err); ferr != nil {
+						cl.cfg.logger.Log(LogLevelError, "retry consumer unable to fail record, retrying", "topic", rec.Topic, "partition", rec.Partition, "offset", rec.Offset, "err", ferr)
+						rs = append([]*Record{rec}, rs...)
+						if retry := time.Now().Add(cl.cfg.retryBackoff(1)); next.IsZero() || retry.Before(next) {
+							next = retry
+						}
+						break
+					}
+				}
+				cl.MarkCommitRecords(rec)
+			}
+
+			isPaused := paused[topic][partition]
+			if len(rs) == 0 {
+				delete(twaiting, partition)
+				if isPaused {
+					delete(paused[topic], partition)
+					if toResume == nil {
+						toResume = make(map[string][]int32)
+					}
+					toResume[topic] = append(toResume[topic], partition)
+				}
+				continue
+			}
+			twaiting[partition] = rs
+			if !isPaused {
+				if paused[topic] == nil {
+					paused[topic] = make(map[int32]bool)
+				}
+				paused[topic][partition] = true
+				if toPause == nil {
+					toPause = make(map[string][]int32)
+				}
+				toPause[topic] = append(toPause[topic], partition)
+			}
+		}
+		if len(twaiting) == 0 {
+			delete(waiting, topic)
+		}
+	}
+
+	// We pause without bumping the consumer session: anything already
+	// buffered for a newly paused partition is simply added to waiting,
+	// and we do not want to kill fetches for every other partition each
+	// time a partition starts waiting. Our next fetch has the broker
+	// forget the paused partitions, so they are not fetched again.
+	if len(toPause) > 0 {
+		cl.consumer.pauseFetchPartitionsLazily(toPause)
+	}
+	if len(toResume) > 0 {
+		cl.ResumeFetchPartitions(toResume)
+	}
+	return next
+}
+
+// retryAttempt returns how many times a record has failed, per its headers.
+func retryAttempt(r *Record) int {
+	for _, h := range r.Headers {
+		if h.Key == RetryHeaderAttempt {
+			attempt, _ := strconv.Atoi(string(h.Value))
+			return attempt
+		}
+	}
+	return 0
+}
+
+// retryAt returns when a record is due to be retried, per its headers. A
+// record with no retry header is due immediately.
+func retryAt(r *Record) time.Time {
+	for _, h := range r.Headers {
+		if h.Key == RetryHeaderRetryAt {
+			millis, err := strconv.ParseInt(string(h.Value), 10, 64)
+			if err != nil {
+				return time.Time{}
+			}
+			return timeFromMillis(millis)
+		}
+	}
+	return time.Time{}
+}
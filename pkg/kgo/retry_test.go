@@ -0,0 +1,123 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetrierFailed(t *testing.T) {
+	r := NewRetrier(nil, "dlq",
+		RetryTopic{"retry-1s", time.Second},
+		RetryTopic{"retry-1m", time.Minute},
+	)
+	now := time.Unix(1000, 0)
+
+	rec := &Record{
+		Topic:     "orig",
+		Partition: 3,
+		Offset:    7,
+		Key:       []byte("k"),
+		Value:     []byte("v"),
+		Headers:   []RecordHeader{{Key: "user", Value: []byte("h")}},
+	}
+
+	for i, exp := range []struct {
+		topic   string
+		retryAt time.Time
+	}{
+		{"retry-1s", now.Add(time.Second)},
+		{"retry-1m", now.Add(time.Minute)},
+		{"dlq", time.Time{}},
+	} {
+		next := r.failed(rec, errors.New("boom"), now)
+		if next.Topic != exp.topic {
+			t.Errorf("#%d: got topic %s != exp %s", i, next.Topic, exp.topic)
+		}
+		if got := retryAttempt(next); got != i+1 {
+			t.Errorf("#%d: got attempt %d != exp %d", i, got, i+1)
+		}
+		if got := retryAt(next); !got.Equal(exp.retryAt) {
+			t.Errorf("#%d: got retry at %v != exp %v", i, got, exp.retryAt)
+		}
+		if string(next.Key) != "k" || string(next.Value) != "v" {
+			t.Errorf("#%d: key or value not preserved", i)
+		}
+
+		headers := make(map[string]string)
+		for _, h := range next.Headers {
+			if _, exists := headers[h.Key]; exists {
+				t.Errorf("#%d: duplicate header %s", i, h.Key)
+			}
+			headers[h.Key] = string(h.Value)
+		}
+		for k, v := range map[string]string{
+			"user":                       "h",
+			RetryHeaderOriginalTopic:     "orig",
+			RetryHeaderOriginalPartition: "3",
+			RetryHeaderOriginalOffset:    "7",
+			RetryHeaderError:             "boom",
+		} {
+			if headers[k] != v {
+				t.Errorf("#%d: header %s: got %q != exp %q", i, k, headers[k], v)
+			}
+		}
+
+		// The next failure is from the record as consumed from the
+		// retry topic, which has a new partition and offset.
+		next.Partition = 0
+		next.Offset = int64(100 + i)
+		rec = next
+	}
+}
+
+func TestRetrierDropsLostPartitions(t *testing.T) {
+	r := NewRetrier(nil, "dlq")
+	r.onAssigned(context.Background(), nil, map[string][]int32{"retry": {0, 1}})
+	r.waiting["retry"] = map[int32][]*Record{
+		0: {{Offset: 3}},
+		1: {{Offset: 5}},
+	}
+
+	r.onLost(context.Background(), nil, map[string][]int32{"retry": {0}})
+
+	if _, exists := r.assigned["retry"][0]; exists {
+		t.Error("lost partition is still assigned")
+	}
+	if _, exists := r.waiting["retry"][0]; exists {
+		t.Error("lost partition still has records waiting")
+	}
+	if len(r.waiting["retry"][1]) != 1 {
+		t.Errorf("got %v waiting for the kept partition, expected one record", r.waiting["retry"][1])
+	}
+}
+
+func TestPauseLazilyForgetsPartitions(t *testing.T) {
+	cl, _ := NewClient()
+	defer cl.Close()
+
+	s := cl.newSource(0)
+	for p := int32(0); p < 2; p++ {
+		cur := &cursor{topic: "retry", partition: p, source: s, useState: 1, cursorsIdx: int(p)}
+		cur.leaderEpoch = -1
+		cur.cursorOffset = cursorOffset{offset: 5, lastConsumedEpoch: -1}
+		s.cursors = append(s.cursors, cur)
+	}
+	s.session = fetchSession{id: 1, epoch: 2, used: map[string]map[int32]fetchSessionOffsetEpoch{
+		"retry": {0: {5, -1}, 1: {5, -1}},
+	}}
+
+	cl.consumer.pauseFetchPartitionsLazily(map[string][]int32{"retry": {0}})
+
+	req := s.createReq()
+	if _, exists := req.usedOffsets["retry"][0]; exists {
+		t.Error("lazily paused partition was fetched")
+	}
+	if forgotten := req.forgotten["retry"]; len(forgotten) != 1 || forgotten[0] != 0 {
+		t.Errorf("got forgotten %v, expected the paused partition", req.forgotten)
+	}
+	if _, exists := s.session.used["retry"][0]; exists {
+		t.Error("lazily paused partition is still in the fetch session")
+	}
+}
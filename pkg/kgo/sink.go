@@ -79,7 +79,8 @@ func (s *sink) createReq(id int64, epoch int16) (*produceRequest, *kmsg.AddParti
 		producerID:    id,
 		producerEpoch: epoch,
 
-		compressor: s.cl.compressor,
+		compressor:       s.cl.compressor,
+		topicCompressors: s.cl.topicCompressors,
 
 		wireLength:      s.cl.baseProduceRequestLength(), // start length with no topics
 		wireLengthLimit: s.cl.cfg.maxBrokerWriteBytes,
@@ -90,7 +91,10 @@ func (s *sink) createReq(id int64, epoch int16) (*produceRequest, *kmsg.AddParti
 		epoch: epoch,
 	}
 
-	var moreToDrain bool
+	var (
+		moreToDrain bool
+		acksSet     bool // acks is per request; we use the acks of the first partition we add
+	)
 
 	s.recBufsMu.Lock()
 	defer s.recBufsMu.Unlock()
@@ -106,12 +110,30 @@ func (s *sink) createReq(id int64, epoch int16) (*produceRequest, *kmsg.AddParti
 			continue
 		}
 
+		// If we are manually flushing, we only drain partitions that
+		// are being flushed.
+		if recBuf.manuallyHeld() {
+			recBuf.mu.Unlock()
+			continue
+		}
+
+		// Partitions whose topic requires different acks are drained
+		// in a later request.
+		acks := recBuf.topicCfg.acks.val
+		if acksSet && acks != req.acks {
+			recBuf.mu.Unlock()
+			moreToDrain = true
+			continue
+		}
+
 		batch := recBuf.batches[recBuf.batchDrainIdx]
 		if added := req.tryAddBatch(atomic.LoadInt32(&s.produceVersion), recBuf, batch); !added {
 			recBuf.mu.Unlock()
 			moreToDrain = true
 			continue
 		}
+		req.acks = acks
+		acksSet = true
 
 		recBuf.inflightOnSink = s
 		recBuf.inflight++
@@ -168,7 +190,7 @@ func (t *txnReqBuilder) add(rb *recBuf) {
 }
 
 func (s *sink) maybeDrain() {
-	if s.cl.cfg.manualFlushing && atomic.LoadInt32(&s.cl.producer.flushing) == 0 {
+	if s.cl.cfg.manualFlushing && atomic.LoadInt32(&s.cl.producer.flushing) == 0 && atomic.LoadInt32(&s.cl.producer.flushingTargets) == 0 {
 		return
 	}
 	if s.drainState.maybeBegin() {
@@ -687,7 +709,7 @@ func (s *sink) handleReqRespBatch(
 	switch {
 	case kerr.IsRetriable(err) &&
 		err != kerr.CorruptMessage &&
-		batch.tries < s.cl.cfg.recordRetries:
+		batch.tries < batch.owner.topicCfg.recordRetries:
 
 		if debug {
 			fmt.Fprintf(b, "retrying@%d,%d(%s)}, ", baseOffset, nrec, err)
@@ -799,7 +821,7 @@ func (s *sink) handleReqRespBatch(
 				"partition", partition,
 				"err", err,
 				"err_is_retriable", kerr.IsRetriable(err),
-				"max_retries_reached", batch.tries >= s.cl.cfg.recordRetries,
+				"max_retries_reached", batch.tries >= batch.owner.topicCfg.recordRetries,
 			)
 		}
 		s.cl.finishBatch(batch.recBatch, producerID, producerEpoch, partition, baseOffset, err)
@@ -835,7 +857,6 @@ func (cl *Client) finishBatch(batch *recBatch, producerID int64, producerEpoch i
 	// We remove this batch and finish all records appropriately.
 	finished := len(batch.records)
 	recBuf.batch0Seq += int32(finished)
-	atomic.AddInt64(&recBuf.buffered, -int64(finished))
 	recBuf.batches[0] = nil
 	recBuf.batches = recBuf.batches[1:]
 	recBuf.batchDrainIdx--
@@ -862,6 +883,11 @@ func (cl *Client) finishBatch(batch *recBatch, producerID int64, producerEpoch i
 		records[i] = noPNR
 	}
 	cl.pnrPool.put(records)
+
+	// We only decrement after promises are called so that targeted
+	// flushes return once promises are done, similar to Flush.
+	atomic.AddInt64(&recBuf.buffered, -int64(finished))
+	cl.producer.maybeNotifyFlushTargets()
 }
 
 // handleRetryBatches sets any first-buf-batch to failing and triggers a
@@ -950,6 +976,8 @@ type recBuf struct {
 	topic     string
 	partition int32
 
+	topicCfg *producerTopicCfg // producer settings for this topic
+
 	// The number of bytes we can buffer in a batch for this particular
 	// topic/partition. This may be less than the configured
 	// maxRecordBatchBytes because of produce request overhead.
@@ -964,10 +992,15 @@ type recBuf struct {
 	// all buffered records are flushed (if the API is used correctly).
 	addedToTxn bool
 
-	// For LoadTopicPartitioner partitioning; atomically tracks the number
-	// of records buffered in total on this recBuf.
+	// For LoadTopicPartitioner partitioning and targeted flushing;
+	// atomically tracks the number of records buffered in total on this
+	// recBuf.
 	buffered int64
 
+	// flushing is >0 if a FlushTopics or FlushPartitions is waiting on
+	// this recBuf, in which case we do not linger.
+	flushing int32
+
 	mu sync.Mutex // guards r/w access to all fields below
 
 	// sink is who is currently draining us. This can be modified
@@ -1086,7 +1119,7 @@ func (recBuf *recBuf) bufferRecord(pr promisedRec, abortOnNewBatch bool) bool {
 		recBuf.batches = append(recBuf.batches, newBatch)
 	}
 
-	if recBuf.cl.cfg.linger == 0 {
+	if recBuf.topicCfg.linger == 0 {
 		if onDrainBatch {
 			recBuf.sink.maybeDrain()
 		}
@@ -1115,19 +1148,28 @@ func (recBuf *recBuf) bufferRecord(pr promisedRec, abortOnNewBatch bool) bool {
 // lingering, then we are flushing and also indicate there is more to drain.
 func (recBuf *recBuf) tryStopLingerForDraining() bool {
 	recBuf.lockedStopLinger()
-	canLinger := recBuf.cl.cfg.linger == 0
+	canLinger := recBuf.topicCfg.linger == 0
 	moreToDrain := !canLinger && len(recBuf.batches) > recBuf.batchDrainIdx ||
 		canLinger && (len(recBuf.batches) > recBuf.batchDrainIdx+1 ||
 			len(recBuf.batches) == recBuf.batchDrainIdx+1 && !recBuf.lockedMaybeStartLinger())
 	return moreToDrain
 }
 
+// manuallyHeld returns whether a recBuf must keep its records buffered
+// because the client is manually flushing and neither Flush nor a FlushTopics
+// or FlushPartitions for this recBuf is waiting.
+func (recBuf *recBuf) manuallyHeld() bool {
+	return recBuf.cl.cfg.manualFlushing &&
+		atomic.LoadInt32(&recBuf.cl.producer.flushing) == 0 &&
+		atomic.LoadInt32(&recBuf.flushing) == 0
+}
+
 // Begins a linger timer unless the producer is being flushed.
 func (recBuf *recBuf) lockedMaybeStartLinger() bool {
-	if atomic.LoadInt32(&recBuf.cl.producer.flushing) == 1 {
+	if atomic.LoadInt32(&recBuf.cl.producer.flushing) == 1 || atomic.LoadInt32(&recBuf.flushing) > 0 {
 		return false
 	}
-	recBuf.lingering = time.AfterFunc(recBuf.cl.cfg.linger, recBuf.sink.maybeDrain)
+	recBuf.lingering = time.AfterFunc(recBuf.topicCfg.linger, recBuf.sink.maybeDrain)
 	return true
 }
 
@@ -1199,6 +1241,7 @@ func (recBuf *recBuf) failAllRecords(err error) {
 	recBuf.resetBatchDrainIdx()
 	atomic.StoreInt64(&recBuf.buffered, 0)
 	recBuf.batches = nil
+	recBuf.cl.producer.maybeNotifyFlushTargets()
 }
 
 // clearFailing clears a buffer's failing state if it is failing.
@@ -1227,6 +1270,10 @@ type promisedRec struct {
 	ctx     context.Context
 	promise func(*Record, error)
 	*Record
+
+	chunked *chunkedProduce // non-nil if this record is a chunk of a split record
+
+	bufferedBytes int64 // bytes counted against MaxBufferedBytes, un-counted when finished
 }
 
 // promisedNumberedRecord ties a promised record to its calculated numbers.
@@ -1273,7 +1320,7 @@ func (b *recBatch) maybeFailErr(cfg *cfg) error {
 	}
 	if b.isTimedOut(cfg.recordTimeout) {
 		return errRecordTimeout
-	} else if b.tries >= cfg.recordRetries {
+	} else if b.tries >= b.owner.topicCfg.recordRetries {
 		return errRecordRetries
 	} else if b.owner.cl.producer.isAborting() {
 		return ErrAborting
@@ -1411,7 +1458,8 @@ type produceRequest struct {
 	// We use this in handleReqResp for the OnProduceHook.
 	metrics produceMetrics
 
-	compressor *compressor
+	compressor       *compressor
+	topicCompressors map[string]*compressor // overrides compressor per topic
 
 	// wireLength is initially the size of sending a produce request,
 	// including the request header, with no topics. We start with the
@@ -1633,7 +1681,7 @@ func (cl *Client) maxRecordBatchBytesForTopic(topic string) int32 {
 	wireLengthLimit := cl.cfg.maxBrokerWriteBytes
 
 	recordBatchLimit := wireLengthLimit - minOnePartitionBatchLength
-	if cfgLimit := cl.cfg.maxRecordBatchBytes; cfgLimit < recordBatchLimit {
+	if cfgLimit := cl.producerTopicCfg(topic).maxRecordBatchBytes; cfgLimit < recordBatchLimit {
 		recordBatchLimit = cfgLimit
 	}
 	return recordBatchLimit
@@ -1783,6 +1831,10 @@ func (p *produceRequest) AppendTo(dst []byte) []byte {
 	}
 
 	for topic, partitions := range p.batches {
+		compressor := p.compressor
+		if c, exists := p.topicCompressors[topic]; exists {
+			compressor = c
+		}
 		if flexible {
 			dst = kbin.AppendCompactString(dst, topic)
 			dst = kbin.AppendCompactArrayLen(dst, len(partitions))
@@ -1806,9 +1858,9 @@ func (p *produceRequest) AppendTo(dst []byte) []byte {
 			}
 			var pmetrics ProduceBatchMetrics
 			if p.version < 3 {
-				dst, pmetrics = batch.appendToAsMessageSet(dst, uint8(p.version), p.compressor)
+				dst, pmetrics = batch.appendToAsMessageSet(dst, uint8(p.version), compressor)
 			} else {
-				dst, pmetrics = batch.appendTo(dst, p.version, p.producerID, p.producerEpoch, p.txnID != nil, p.compressor)
+				dst, pmetrics = batch.appendTo(dst, p.version, p.producerID, p.producerEpoch, p.txnID != nil, compressor)
 			}
 			batch.mu.Unlock()
 			tmetrics[partition] = pmetrics
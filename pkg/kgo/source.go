@@ -41,6 +41,12 @@ type source struct {
 	cursorsMu    sync.Mutex
 	cursors      []*cursor // contains all partitions being consumed on this source
 	cursorsStart int       // incremented every fetch req to ensure all partitions are fetched
+
+	// rateWake, if non-nil, triggers consuming once a rate limited topic
+	// that was skipped in the last fetch request can be fetched again.
+	rateWakeMu sync.Mutex
+	rateWake   *time.Timer
+	rateWakeAt time.Time
 }
 
 func (cl *Client) newSource(nodeID int32) *source {
@@ -93,7 +99,9 @@ type cursor struct {
 	topicID   [16]byte
 	partition int32
 
-	keepControl bool // whether to keep control records
+	keepControl  bool                 // whether to keep control records
+	interceptors []ConsumeInterceptor // interceptors to run on kept records
+	chunks       *chunkReassembler    // non-nil if reassembling chunked records
 
 	cursorsIdx int // updated under source mutex
 
@@ -128,6 +136,10 @@ type cursor struct {
 	// leader epoch (see cursorOffsetNext for why the leader epoch). When a
 	// buffered fetch is taken, we update the cursor.
 	cursorOffset
+
+	// consumed mirrors the cursor offset and is set atomically with it,
+	// so that ConsumerLag can read where we are without the source.
+	consumed int64
 }
 
 // cursorOffset tracks offsets/epochs for a cursor.
@@ -191,6 +203,7 @@ func (c *cursor) allowUsable() {
 // after.
 func (c *cursor) setOffset(o cursorOffset) {
 	c.cursorOffset = o
+	atomic.StoreInt64(&c.consumed, o.offset)
 }
 
 // cursorOffsetNext is updated while processing a fetch response.
@@ -312,17 +325,26 @@ func (s *source) hook(f *Fetch, buffered, polled bool) {
 		}
 	})
 
-	var nrecs int
+	var (
+		nrecs  int
+		nbytes int64
+	)
 	for i := range f.Topics {
 		t := &f.Topics[i]
 		for j := range t.Partitions {
-			nrecs += len(t.Partitions[j].Records)
+			p := &t.Partitions[j]
+			nrecs += len(p.Records)
+			for _, r := range p.Records {
+				nbytes += recordBufferedBytes(r)
+			}
 		}
 	}
 	if buffered {
 		atomic.AddInt64(&s.cl.consumer.bufferedRecords, int64(nrecs))
+		s.cl.consumer.addBufferedBytes(nbytes)
 	} else {
 		atomic.AddInt64(&s.cl.consumer.bufferedRecords, -int64(nrecs))
+		s.cl.consumer.addBufferedBytes(-nbytes)
 	}
 }
 
@@ -375,6 +397,9 @@ func (s *source) takeNBuffered(n int) (Fetch, int, bool) {
 
 			rp.Records = p.Records[:take]
 			p.Records = p.Records[take:]
+			if len(p.Records) > 0 {
+				rp.droppedNext = EpochOffset{} // dropped records are after what we are taking
+			}
 
 			n -= take
 			taken += take
@@ -443,9 +468,19 @@ func (s *source) createReq() *fetchRequest {
 		session: s.session,
 	}
 
+	paused := s.cl.consumer.loadPaused()
+	bounded := s.cl.consumer.bounded.loadDone()
+
 	s.cursorsMu.Lock()
 	defer s.cursorsMu.Unlock()
 
+	// Partitions that we skip while they are still in our fetch session
+	// are forgotten, otherwise the broker would keep returning them in
+	// incremental fetches only for us to drop what it returns.
+	//
+	// Topics that are over their rate limit are skipped, and we wake
+	// once the earliest skipped topic can be fetched again.
+	var rateWait time.Duration
 	cursorIdx := s.cursorsStart
 	for i := 0; i < len(s.cursors); i++ {
 		c := s.cursors[cursorIdx]
@@ -453,8 +488,22 @@ func (s *source) createReq() *fetchRequest {
 		if !c.usable() {
 			continue
 		}
+		if paused.has(c.topic, c.partition) || bounded.has(c.topic, c.partition) || !s.cl.consumer.boundedSnapshotted(c) {
+			req.forget(c)
+			continue
+		}
+		if wait := s.cl.consumer.topicRateDelay(c.topic); wait > 0 {
+			if rateWait == 0 || wait < rateWait {
+				rateWait = wait
+			}
+			req.forget(c)
+			continue
+		}
 		req.addCursor(c)
 	}
+	if rateWait > 0 {
+		s.wakeAfterRateLimit(rateWait)
+	}
 
 	// We could have lost our only record buffer just before we grabbed the
 	// source lock above.
@@ -507,6 +556,27 @@ func (s *source) loopFetch() {
 		case <-s.sem:
 		}
 
+		// If the client is buffering too many bytes, we wait before
+		// even asking to fetch.
+		if !consumer.waitBufferedBytes(session.ctx) {
+			s.fetchState.hardFinish()
+			return
+		}
+
+		// Similarly, if the client is consuming faster than its
+		// rate limit, we wait until we have paid for what we consumed.
+		if !consumer.waitRateLimit(session.ctx) {
+			s.fetchState.hardFinish()
+			return
+		}
+
+		// If consuming bounded, we do not fetch until the end offsets we
+		// are bounded by have been snapshotted.
+		if !consumer.waitBoundedEnds(session.ctx) {
+			s.fetchState.hardFinish()
+			return
+		}
+
 		select {
 		case <-session.ctx.Done():
 			s.fetchState.hardFinish()
@@ -531,11 +601,11 @@ func (s *source) loopFetch() {
 // contains a lot of the side effects of fetching and updating. The function
 // consists of two main bulks of logic:
 //
-//   * First, issue a request that can be killed if the source needs to be
-//   stopped. Processing the response modifies no state on the source.
+//   - First, issue a request that can be killed if the source needs to be
+//     stopped. Processing the response modifies no state on the source.
 //
-//   * Second, we keep the fetch response and update everything relevant
-//   (session, trigger some list or epoch updates, buffer the fetch).
+//   - Second, we keep the fetch response and update everything relevant
+//     (session, trigger some list or epoch updates, buffer the fetch).
 //
 // One small part between the first and second step is to update preferred
 // replicas. We always keep the preferred replicas from the fetch response
@@ -561,6 +631,11 @@ func (s *source) fetch(consumerSession *consumerSession, doneFetch chan<- struct
 	}()
 
 	if req.numOffsets == 0 { // cursors could have been set unusable
+		// Rather than issuing a request only to forget partitions,
+		// we reset the session; our next request starts a new one.
+		if len(req.forgotten) > 0 {
+			s.session.reset()
+		}
 		return
 	}
 
@@ -708,6 +783,28 @@ func (s *source) fetch(consumerSession *consumerSession, doneFetch chan<- struct
 		s.cl.triggerUpdateMetadataNow()
 	}
 
+	// We reassemble chunks and run interceptors only now that we are
+	// keeping the response, rather than while handling a response that
+	// could be discarded.
+	reassembleFetch(&fetch, req.usedOffsets)
+	interceptFetch(&fetch, req.usedOffsets)
+
+	s.cl.consumer.updateLagEnds(&fetch)
+
+	// If consuming bounded, we trim anything past our end snapshots before
+	// buffering, and mark partitions done only after buffering.
+	var reachedBound []*cursorOffsetNext
+	if s.cl.consumer.bounded != nil {
+		reachedBound = s.cl.consumer.trimBounded(&fetch, req.usedOffsets)
+		if len(reachedBound) > 0 {
+			defer s.cl.consumer.markBoundedDone(reachedBound)
+		}
+	}
+
+	// Rate limits are charged for everything we are about to buffer;
+	// fetching is paced before our next request.
+	s.cl.consumer.takeRateLimits(&fetch)
+
 	if fetch.hasErrorsOrRecords() {
 		buffered = true
 		s.buffered = bufferedFetch{
@@ -1346,6 +1443,9 @@ func (o *cursorOffsetNext) processV0Message(
 //
 // If the record is being aborted or the record is a control record and the
 // client does not want to keep control records, this does not keep the record.
+// Chunks are not reassembled and consume interceptors are not run here; both
+// happen once the fetch is kept to be buffered (see reassembleFetch and
+// interceptFetch).
 func (o *cursorOffsetNext) maybeKeepRecord(fp *FetchPartition, record *Record, abort bool) {
 	if record.Offset < o.offset {
 		// We asked for offset 5, but that was in the middle of a
@@ -1468,6 +1568,7 @@ type fetchRequest struct {
 
 	numOffsets  int
 	usedOffsets usedOffsets
+	forgotten   map[string][]int32 // partitions to remove from the session
 
 	topic2id map[string][16]byte
 	id2topic map[[16]byte]string
@@ -1481,20 +1582,39 @@ type fetchRequest struct {
 func (f *fetchRequest) addCursor(c *cursor) {
 	if f.usedOffsets == nil {
 		f.usedOffsets = make(usedOffsets)
-		f.id2topic = make(map[[16]byte]string)
-		f.topic2id = make(map[string][16]byte)
 	}
 	partitions := f.usedOffsets[c.topic]
 	if partitions == nil {
 		partitions = make(map[int32]*cursorOffsetNext)
 		f.usedOffsets[c.topic] = partitions
-		f.id2topic[c.topicID] = c.topic
-		f.topic2id[c.topic] = c.topicID
+		f.addTopicID(c)
 	}
 	partitions[c.partition] = c.use()
 	f.numOffsets++
 }
 
+// forget removes a skipped cursor's partition from the request's session, if
+// the session has it, so that the request tells the broker to forget it.
+func (f *fetchRequest) forget(c *cursor) {
+	if !f.session.forget(c.topic, c.partition) {
+		return
+	}
+	if f.forgotten == nil {
+		f.forgotten = make(map[string][]int32)
+	}
+	f.forgotten[c.topic] = append(f.forgotten[c.topic], c.partition)
+	f.addTopicID(c)
+}
+
+func (f *fetchRequest) addTopicID(c *cursor) {
+	if f.id2topic == nil {
+		f.id2topic = make(map[[16]byte]string)
+		f.topic2id = make(map[string][16]byte)
+	}
+	f.id2topic[c.topicID] = c.topic
+	f.topic2id[c.topic] = c.topicID
+}
+
 func (*fetchRequest) Key() int16           { return 1 }
 func (*fetchRequest) MaxVersion() int16    { return 12 }
 func (f *fetchRequest) SetVersion(v int16) { f.version = v }
@@ -1545,6 +1665,14 @@ func (f *fetchRequest) AppendTo(dst []byte) []byte {
 		}
 	}
 
+	for topic, partitions := range f.forgotten {
+		req.ForgottenTopics = append(req.ForgottenTopics, kmsg.FetchRequestForgottenTopic{
+			Topic:      topic,
+			TopicID:    f.topic2id[topic],
+			Partitions: partitions,
+		})
+	}
+
 	return req.AppendTo(dst)
 }
 
@@ -1557,8 +1685,11 @@ func (f *fetchRequest) ResponseKind() kmsg.Response {
 }
 
 // fetchSessions, introduced in KIP-227, allow us to send less information back
-// and forth to a Kafka broker. Rather than relying on forgotten topics to
-// remove partitions from a session, we just simply reset the session.
+// and forth to a Kafka broker. When partitions are removed from the client,
+// rather than relying on forgotten topics to remove them from a session, we
+// just simply reset the session. Partitions that remain assigned but that we
+// skip fetching (paused, rate limited, or done consuming bounded) are
+// forgotten with ForgottenTopics.
 type fetchSession struct {
 	id    int32
 	epoch int32
@@ -1619,6 +1750,20 @@ func (s *fetchSession) lookupTopic(topic string) fetchSessionTopic {
 	return t
 }
 
+// forget removes a partition from the session, returning whether the session
+// had the partition.
+func (s *fetchSession) forget(topic string, partition int32) bool {
+	t := s.used[topic]
+	if _, exists := t[partition]; !exists {
+		return false
+	}
+	delete(t, partition)
+	if len(t) == 0 {
+		delete(s.used, topic)
+	}
+	return true
+}
+
 type fetchSessionOffsetEpoch struct {
 	offset int64
 	epoch  int32
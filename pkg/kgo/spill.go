@@ -0,0 +1,650 @@
+package kgo
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kbin"
+)
+
+const (
+	spillLogFile = "spill.log" // spilled records, in order
+	spillPosFile = "spill.pos" // the log position that everything before has been produced
+
+	// spillReplayWindow is the most records we replay at once.
+	spillReplayWindow = 1000
+
+	// spillSyncInterval is how often we sync the log and persist our
+	// produced position.
+	spillSyncInterval = time.Second
+)
+
+var errSpillFull = errors.New("spill buffer is full")
+
+// spillBuffer persists records to disk when they cannot be buffered in memory
+// or fail to be produced because brokers are unavailable, and replays them in
+// order.
+//
+// The log is a sequence of entries, each of which is a big endian uint32
+// length, a big endian crc32c of the payload, and the payload. The pos file is
+// a single big endian int64 log position; everything before the position has
+// been produced. Once everything in the log is produced, the log is
+// truncated.
+//
+// Only promises are kept in memory for spilled records; records are read back
+// from the log when they are replayed.
+type spillBuffer struct {
+	cl       *Client
+	maxBytes int64
+
+	log *os.File
+	pos *os.File
+
+	wake chan struct{}
+	done chan struct{}
+
+	mu          sync.Mutex
+	closed      bool
+	end         int64                          // the end of the log, where we append
+	produced    int64                          // persisted: everything before has been produced
+	next        int64                          // the position of the next entry to replay
+	inflight    []*spillEntry                  // entries from produced to next, in order
+	held        map[int64]func(*Record, error) // promises by position, for records spilled by this client
+	failing     bool                           // whether a replay failed; we wait for inflight to finish, then retry
+	tries       int                            // consecutive failed replay rounds, for backoff
+	drained     chan struct{}                  // if non-nil, closed once everything spilled is produced
+	logUnsynced bool                           // whether we appended since last syncing the log
+	posDirty    bool                           // whether produced advanced since last writing it
+	posUnsynced bool                           // whether we wrote the pos since last syncing it
+}
+
+type spillEntry struct {
+	pos, end int64
+	topic    string
+	promise  func(*Record, error) // nil if the record was spilled by a prior client
+	reported bool                 // whether the record's result was reported; re-replays are not reported again
+	state    uint8
+}
+
+// spillReplay is an entry to replay and its record, read back from the log.
+type spillReplay struct {
+	e *spillEntry
+	r *Record
+}
+
+const (
+	spillPending uint8 = iota
+	spillProducing
+	spillFailed
+	spillDone
+)
+
+// openSpill opens (or creates) the spill in dir, recovering any records that
+// a prior client spilled but did not produce.
+func (cl *Client) openSpill() (*spillBuffer, error) {
+	dir := cl.cfg.spillDir
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create spill dir: %w", err)
+	}
+	log, err := os.OpenFile(filepath.Join(dir, spillLogFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open spill log: %w", err)
+	}
+	pos, err := os.OpenFile(filepath.Join(dir, spillPosFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		log.Close()
+		return nil, fmt.Errorf("unable to open spill position: %w", err)
+	}
+
+	s := &spillBuffer{
+		cl:       cl,
+		maxBytes: cl.cfg.spillMaxBytes,
+		log:      log,
+		pos:      pos,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	if err := s.recover(); err != nil {
+		log.Close()
+		pos.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// recover loads our produced position and finds the end of the log,
+// truncating any entry that was torn by a crash while appending.
+func (s *spillBuffer) recover() error {
+	var posRaw [8]byte
+	switch n, err := s.pos.ReadAt(posRaw[:], 0); {
+	case n == 8:
+		s.produced = int64(binary.BigEndian.Uint64(posRaw[:]))
+	case err != nil && err != io.EOF:
+		return fmt.Errorf("unable to read spill position: %w", err)
+	}
+
+	fi, err := s.log.Stat()
+	if err != nil {
+		return fmt.Errorf("unable to stat spill log: %w", err)
+	}
+	size := fi.Size()
+	if s.produced > size || s.produced < 0 {
+		// We truncate the log before resetting the position; if we
+		// crashed between the two, the log is empty.
+		s.produced = 0
+	}
+
+	end := s.produced
+	for end < size {
+		_, entryEnd, err := s.readEntry(end, size)
+		if err != nil {
+			s.cl.cfg.logger.Log(LogLevelWarn, "truncating torn or corrupt spilled record", "pos", end, "err", err)
+			break
+		}
+		end = entryEnd
+	}
+	if end < size {
+		if err := s.log.Truncate(end); err != nil {
+			return fmt.Errorf("unable to truncate spill log: %w", err)
+		}
+	}
+	s.end = end
+	s.next = s.produced
+	if s.end > s.produced {
+		s.cl.cfg.logger.Log(LogLevelInfo, "recovered spilled records to replay", "bytes", s.end-s.produced)
+	}
+	return nil
+}
+
+// encodeSpilled encodes a record as a log entry. Timestamps are not persisted,
+// since the producer sets them when buffering.
+func encodeSpilled(r *Record) []byte {
+	b := make([]byte, 8, 64+len(r.Key)+len(r.Value))
+	b = kbin.AppendString(b, r.Topic)
+	b = kbin.AppendInt32(b, r.Partition)
+	b = kbin.AppendNullableBytes(b, r.Key)
+	b = kbin.AppendNullableBytes(b, r.Value)
+	b = kbin.AppendArrayLen(b, len(r.Headers))
+	for _, h := range r.Headers {
+		b = kbin.AppendString(b, h.Key)
+		b = kbin.AppendNullableBytes(b, h.Value)
+	}
+	binary.BigEndian.PutUint32(b[0:4], uint32(len(b)-8))
+	binary.BigEndian.PutUint32(b[4:8], crc32.Checksum(b[8:], crc32c))
+	return b
+}
+
+// readEntry reads the entry at pos, which must end by end, returning the record
+// and the position of the following entry.
+func (s *spillBuffer) readEntry(pos, end int64) (*Record, int64, error) {
+	var hdr [8]byte
+	if _, err := s.log.ReadAt(hdr[:], pos); err != nil {
+		return nil, 0, err
+	}
+	l := int64(binary.BigEndian.Uint32(hdr[0:4]))
+	if pos+8+l > end {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	payload := make([]byte, l)
+	if _, err := s.log.ReadAt(payload, pos+8); err != nil {
+		return nil, 0, err
+	}
+	if crc32.Checksum(payload, crc32c) != binary.BigEndian.Uint32(hdr[4:8]) {
+		return nil, 0, errors.New("crc mismatch")
+	}
+
+	b := kbin.Reader{Src: payload}
+	r := &Record{
+		Topic:     b.String(),
+		Partition: b.Int32(),
+	}
+	r.Key = b.NullableBytes()
+	r.Value = b.NullableBytes()
+	if n := b.ArrayLen(); n > 0 {
+		r.Headers = make([]RecordHeader, 0, n)
+		for i := int32(0); i < n && b.Ok(); i++ {
+			r.Headers = append(r.Headers, RecordHeader{
+				Key:   b.String(),
+				Value: b.NullableBytes(),
+			})
+		}
+	}
+	if err := b.Complete(); err != nil {
+		return nil, 0, err
+	}
+	return r, pos + 8 + int64(len(payload)), nil
+}
+
+// append, called under the mu, appends a record to the log. If the client is
+// closed, the promise is called with ErrRecordSpilled once the record is
+// persisted, otherwise the promise is called once the record is replayed.
+func (s *spillBuffer) append(r *Record, promise func(*Record, error)) error {
+	entry := encodeSpilled(r)
+	if s.maxBytes > 0 && s.end+int64(len(entry)) > s.maxBytes {
+		return errSpillFull
+	}
+	if _, err := s.log.WriteAt(entry, s.end); err != nil {
+		// A partial write is overwritten by the next append, or
+		// truncated when recovering.
+		return err
+	}
+	if s.closed {
+		go promise(r, ErrRecordSpilled)
+	} else {
+		if s.held == nil {
+			s.held = make(map[int64]func(*Record, error))
+		}
+		s.held[s.end] = promise
+	}
+	s.end += int64(len(entry))
+	s.logUnsynced = true
+	s.notify()
+	return nil
+}
+
+func (s *spillBuffer) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// maybeSpill spills a record being produced if we are already spilling, which
+// keeps records in order, or if the producer is at its buffer limits. This
+// returns whether the record was spilled or otherwise finished.
+//
+// If anything spilled is not yet produced and we cannot spill, we wait for
+// the spill to be fully produced rather than buffer the record in memory
+// ahead of what is spilled.
+func (s *spillBuffer) maybeSpill(ctx context.Context, r *Record, promise func(*Record, error)) bool {
+	var (
+		p     = &s.cl.producer
+		cfg   = &s.cl.cfg
+		full  = atomic.LoadInt64(&p.bufferedRecords) >= cfg.maxBufferedRecords
+		bytes = recordBufferedBytes(r)
+	)
+	if cfg.maxBufferedBytes > 0 && atomic.LoadInt64(&p.bufferedBytes)+bytes > cfg.maxBufferedBytes {
+		full = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		spilling := s.produced < s.end
+		if s.closed || !full && !spilling {
+			return false
+		}
+		err := s.append(r, promise)
+		if err == nil {
+			return true
+		}
+		if err != errSpillFull {
+			s.cl.cfg.logger.Log(LogLevelWarn, "unable to spill record", "topic", r.Topic, "err", err)
+		}
+		if !spilling {
+			return false // nothing is spilled ahead of us, so we can buffer in memory
+		}
+
+		if s.drained == nil {
+			s.drained = make(chan struct{})
+		}
+		drained := s.drained
+		s.mu.Unlock()
+
+		var waitErr error
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			waitErr = ctx.Err()
+		case <-s.cl.ctx.Done():
+			waitErr = ErrClientClosed
+		}
+
+		s.mu.Lock()
+		if waitErr != nil {
+			go promise(r, waitErr)
+			return true
+		}
+	}
+}
+
+// spillOnFailure wraps a record's promise to spill the record, rather than
+// fail it, if it fails because brokers are unavailable.
+func (s *spillBuffer) spillOnFailure(promise func(*Record, error)) func(*Record, error) {
+	return func(r *Record, err error) {
+		if err != nil && spillableErr(err) {
+			s.mu.Lock()
+			spillErr := s.append(r, promise)
+			s.mu.Unlock()
+			if spillErr == nil {
+				return
+			}
+		}
+		promise(r, err)
+	}
+}
+
+// spillableErr returns whether a record that failed with err should be
+// spilled and retried later.
+func spillableErr(err error) bool {
+	return errors.Is(err, errRecordTimeout) ||
+		errors.Is(err, errRecordRetries) ||
+		errors.Is(err, ErrClientClosed) ||
+		isRetriableBrokerErr(err)
+}
+
+// loop replays spilled records and periodically syncs the spill until the
+// client is closed.
+func (s *spillBuffer) loop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(spillSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.wake:
+		case <-ticker.C:
+			s.sync()
+			continue
+		case <-s.cl.ctx.Done():
+			return
+		}
+		for _, replay := range s.nextReplays() {
+			if !s.startReplay(replay.e) {
+				break // a replay failed; we retry from the failure
+			}
+			s.cl.produceRecord(s.cl.ctx, replay.r, s.finishReplay(replay.e))
+		}
+	}
+}
+
+// nextReplays returns the entries to produce, in log order: any entries that
+// are pending a retry, followed by new entries read from the log. Nothing is
+// returned while a failed replay round is still finishing.
+func (s *spillBuffer) nextReplays() []spillReplay {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || s.failing {
+		return nil
+	}
+
+	var replays []spillReplay
+	for _, e := range s.inflight {
+		if e.state != spillPending {
+			continue
+		}
+		r, _, err := s.readEntry(e.pos, e.end)
+		if err != nil {
+			s.readFailed(e.pos, err)
+			return replays
+		}
+		replays = append(replays, spillReplay{e, r})
+	}
+	for len(s.inflight) < spillReplayWindow && s.next < s.end {
+		r, end, err := s.readEntry(s.next, s.end)
+		if err != nil {
+			s.readFailed(s.next, err)
+			break
+		}
+		e := &spillEntry{
+			pos:     s.next,
+			end:     end,
+			topic:   r.Topic,
+			promise: s.held[s.next],
+		}
+		delete(s.held, s.next)
+		s.inflight = append(s.inflight, e)
+		replays = append(replays, spillReplay{e, r})
+		s.next = end
+	}
+	return replays
+}
+
+// readFailed, called under the mu, stops replaying after we could not read
+// the entry at pos. We validated the log on open and wrote every entry since;
+// this is an IO error, and we try again later.
+func (s *spillBuffer) readFailed(pos int64, err error) {
+	s.cl.cfg.logger.Log(LogLevelError, "unable to read spilled record, retrying later", "pos", pos, "err", err)
+	s.failing = true
+	s.retryLater()
+}
+
+// startReplay marks an entry as producing, returning false if a replay failed
+// since nextReplays returned the entry. We stop replaying at the first
+// failure so that nothing after a failed record is produced before it is
+// retried.
+func (s *spillBuffer) startReplay(e *spillEntry) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.failing {
+		return false
+	}
+	e.state = spillProducing
+	return true
+}
+
+// finishReplay returns the promise for a replayed entry.
+func (s *spillBuffer) finishReplay(e *spillEntry) func(*Record, error) {
+	return func(r *Record, err error) {
+		s.mu.Lock()
+
+		var report bool
+		switch {
+		case err == nil:
+			e.state = spillDone
+			report = true
+			s.tries = 0
+		case s.closed || s.cl.ctx.Err() != nil:
+			// The record is still in the log past our produced
+			// position, and will be replayed by the next client.
+			// Only our own records are reported as spilled.
+			e.state = spillFailed
+			err = ErrRecordSpilled
+			report = e.promise != nil
+		case spillableErr(err):
+			e.state = spillFailed
+			s.failing = true
+		default:
+			e.state = spillDone
+			report = true
+		}
+		report = report && !e.reported
+		promise := e.promise
+		if report {
+			e.reported = true
+			e.promise = nil
+		}
+		s.advance()
+		s.mu.Unlock()
+
+		switch {
+		case !report:
+		case promise != nil:
+			promise(r, err)
+		case s.cl.cfg.spillRecoveredPromise != nil:
+			s.cl.cfg.spillRecoveredPromise(r, err)
+		case err != nil:
+			s.cl.cfg.logger.Log(LogLevelWarn, "recovered spilled record failed", "topic", r.Topic, "err", err)
+		}
+		s.notify()
+	}
+}
+
+// advance, called under the mu, moves our produced position past every
+// finished entry at the front of the inflight window, and retries from failed
+// entries once nothing is producing.
+func (s *spillBuffer) advance() {
+	var i int
+	for i < len(s.inflight) && s.inflight[i].state == spillDone {
+		s.produced = s.inflight[i].end
+		i++
+	}
+	if i > 0 {
+		s.inflight = s.inflight[i:]
+		if len(s.inflight) == 0 && s.next == s.end {
+			s.reset()
+		} else {
+			s.posDirty = true
+		}
+	}
+
+	if !s.failing || s.closed {
+		return
+	}
+	for _, e := range s.inflight {
+		if e.state == spillProducing {
+			return
+		}
+	}
+	s.rewind()
+	s.retryLater()
+}
+
+// rewind, called under the mu once a failed replay round has finished, sets
+// every failed entry, and every later entry for the same topic, back to
+// pending.
+//
+// Entries after a failure could have been produced before the failure was
+// noticed. We produce them again after the failed entry so that every
+// partition ends up with its records in order; this is at least once, as
+// replaying always is. We rewind by topic, because a record that fails before
+// being partitioned does not know its partition.
+func (s *spillBuffer) rewind() {
+	var failed map[string]bool
+	for _, e := range s.inflight {
+		switch {
+		case e.state == spillFailed:
+			if failed == nil {
+				failed = make(map[string]bool)
+			}
+			failed[e.topic] = true
+			e.state = spillPending
+		case failed[e.topic]:
+			e.state = spillPending
+		}
+	}
+}
+
+// retryLater, called under the mu, clears our failing state after backing off.
+func (s *spillBuffer) retryLater() {
+	s.tries++
+	time.AfterFunc(s.cl.cfg.retryBackoff(s.tries), func() {
+		s.mu.Lock()
+		s.failing = false
+		s.mu.Unlock()
+		s.notify()
+	})
+}
+
+// reset, called under the mu once everything spilled has been produced,
+// truncates the log and wakes anything waiting for the spill to drain.
+//
+// We persist the position immediately: if new entries were appended after
+// truncating but before persisting, a stale position could skip them on
+// restart.
+func (s *spillBuffer) reset() {
+	if s.drained != nil {
+		close(s.drained)
+		s.drained = nil
+	}
+	if err := s.log.Truncate(0); err != nil {
+		s.cl.cfg.logger.Log(LogLevelWarn, "unable to truncate fully produced spill log", "err", err)
+		s.writePos()
+		return
+	}
+	s.end, s.next, s.produced = 0, 0, 0
+	s.writePos()
+}
+
+// writePos, called under the mu, persists our produced position.
+func (s *spillBuffer) writePos() {
+	s.posDirty = false
+	var posRaw [8]byte
+	binary.BigEndian.PutUint64(posRaw[:], uint64(s.produced))
+	if _, err := s.pos.WriteAt(posRaw[:], 0); err != nil {
+		s.cl.cfg.logger.Log(LogLevelWarn, "unable to persist spill position, records may be replayed again on restart", "err", err)
+		return
+	}
+	s.posUnsynced = true
+}
+
+// sync writes our produced position if it advanced, and syncs the log and
+// position files if they changed. Syncing is slow, so we do not hold the mu
+// while syncing.
+func (s *spillBuffer) sync() {
+	s.mu.Lock()
+	if s.posDirty {
+		s.writePos()
+	}
+	syncLog, syncPos := s.logUnsynced, s.posUnsynced
+	s.logUnsynced, s.posUnsynced = false, false
+	s.mu.Unlock()
+
+	if syncLog {
+		if err := s.log.Sync(); err != nil {
+			s.cl.cfg.logger.Log(LogLevelWarn, "unable to sync spill log", "err", err)
+		}
+	}
+	if syncPos {
+		if err := s.pos.Sync(); err != nil {
+			s.cl.cfg.logger.Log(LogLevelWarn, "unable to sync spill position", "err", err)
+		}
+	}
+}
+
+// close stops replaying and calls the promise of every record that is still
+// spilled with ErrRecordSpilled. The log is left open, since records failing
+// as the client closes may still be spilled.
+func (s *spillBuffer) close() {
+	<-s.done
+
+	type spilled struct {
+		r       *Record
+		promise func(*Record, error)
+	}
+	var toFinish []spilled
+
+	s.mu.Lock()
+	s.closed = true
+	for _, e := range s.inflight {
+		if e.state != spillDone && e.state != spillProducing && e.promise != nil {
+			toFinish = append(toFinish, spilled{s.spilledRecord(e.pos), e.promise})
+			e.promise = nil
+		}
+	}
+	for pos, promise := range s.held {
+		toFinish = append(toFinish, spilled{s.spilledRecord(pos), promise})
+	}
+	s.held = nil
+	s.mu.Unlock()
+
+	s.sync()
+
+	for _, f := range toFinish {
+		f.promise(f.r, ErrRecordSpilled)
+	}
+}
+
+// spilledRecord, called under the mu, reads back the record at pos for its
+// promise. If the record cannot be read, the promise receives an empty
+// record.
+func (s *spillBuffer) spilledRecord(pos int64) *Record {
+	r, _, err := s.readEntry(pos, s.end)
+	if err != nil {
+		s.cl.cfg.logger.Log(LogLevelWarn, "unable to read back spilled record for its promise", "pos", pos, "err", err)
+		return new(Record)
+	}
+	return r
+}
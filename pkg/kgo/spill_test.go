@@ -0,0 +1,117 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSpillPersistsAcrossClients(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kgo-spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cl, err := NewClient(
+		DefaultProduceTopic("foo"),
+		MaxBufferedRecords(1),
+		ProducerSpillDir(dir, 0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// With no brokers, the first record waits in memory for metadata and
+	// the rest are spilled. Closing spills the first record as well.
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, v := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		cl.Produce(context.Background(), &Record{Key: []byte("k"), Value: []byte(v)}, func(_ *Record, err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+			wg.Done()
+		})
+	}
+	cl.Close()
+
+	done := make(chan struct{})
+	go func() { wg.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("promises were not called after closing")
+	}
+	for _, err := range errs {
+		if !errors.Is(err, ErrRecordSpilled) {
+			t.Errorf("got promise err %v, expected ErrRecordSpilled", err)
+		}
+	}
+
+	// A torn trailing write is truncated when the spill is reopened.
+	f, err := os.OpenFile(filepath.Join(dir, spillLogFile), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 1, 0, 1, 2})
+	f.Close()
+
+	s, err := cl.openSpill()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.log.Close()
+	defer s.pos.Close()
+
+	var values []string
+	for pos := s.produced; pos < s.end; {
+		r, next, err := s.readEntry(pos, s.end)
+		if err != nil {
+			t.Fatalf("unable to read recovered entry at %d: %v", pos, err)
+		}
+		if r.Topic != "foo" || string(r.Key) != "k" {
+			t.Errorf("got recovered record %v, expected topic foo key k", r)
+		}
+		values = append(values, string(r.Value))
+		pos = next
+	}
+	sort.Strings(values)
+	if len(values) != 3 || values[0] != "a" || values[1] != "b" || values[2] != "c" {
+		t.Errorf("got recovered values %v, expected a b c", values)
+	}
+}
+
+func TestSpillRewindKeepsTopicsInOrder(t *testing.T) {
+	s := &spillBuffer{cl: &Client{cfg: defaultCfg()}, failing: true}
+	s.inflight = []*spillEntry{
+		{topic: "foo", state: spillDone},   // produced before the failure
+		{topic: "foo", state: spillFailed}, // the failure
+		{topic: "bar", state: spillDone},   // another topic is unaffected
+		{topic: "foo", state: spillDone},   // produced after the failure
+		{topic: "foo", state: spillPending},
+	}
+	s.advance()
+
+	// The first entry is advanced past, and the failure and everything
+	// after it for the same topic is replayed again.
+	exp := []uint8{spillPending, spillDone, spillPending, spillPending}
+	if len(s.inflight) != len(exp) {
+		t.Fatalf("got %d inflight entries, expected %d", len(s.inflight), len(exp))
+	}
+	for i, e := range s.inflight {
+		if e.state != exp[i] {
+			t.Errorf("#%d: got state %d, expected %d", i, e.state, exp[i])
+		}
+	}
+}
@@ -86,6 +86,29 @@ func (t *topicsPartitions) ensureTopics(topics []string) topicsPartitionsData {
 	return current
 }
 
+// Removes the given topics from the stored data, returning the topic
+// partitions that were removed. Topics that do not exist are skipped.
+func (t *topicsPartitions) purgeTopics(topics []string) []*topicPartitions {
+	var purged []*topicPartitions
+	var current topicsPartitionsData
+	for _, topic := range topics {
+		if current == nil {
+			if !t.load().hasTopic(topic) {
+				continue
+			}
+			current = t.clone()
+		}
+		if parts, exists := current[topic]; exists {
+			purged = append(purged, parts)
+			delete(current, topic)
+		}
+	}
+	if current != nil {
+		t.storeData(current)
+	}
+	return purged
+}
+
 // Updates the topic partitions data atomic value.
 //
 // If this is the first time seeing partitions, we do processing of unknown